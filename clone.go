@@ -0,0 +1,66 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+)
+
+// CloneOptions configures `Node.CloneWithOptions`
+type CloneOptions struct {
+	// Deep copies the entire sub-tree, rather than just the node itself
+	Deep bool
+	// Mapping will be populated with each original node, mapped to its copy, if it is non-nil
+	Mapping map[*html.Node]*html.Node
+}
+
+// Clone returns a copy of n (and, if deep, its descendants), detached from any parent or siblings, with a `Depth`
+// of 0, and no `Match`, or an empty node if n is empty, note that the original tree is only read, so cloning may be
+// used to obtain a copy that is safe to modify, e.g. using `Sanitize`, or the mutation methods, while the original
+// is read concurrently
+func (n Node) Clone(deep bool) Node {
+	return n.CloneWithOptions(CloneOptions{Deep: deep})
+}
+
+// CloneWithOptions is like Clone, but with additional options (see `CloneOptions`)
+func (n Node) CloneWithOptions(opts CloneOptions) Node {
+	if n.Data == nil {
+		return Node{}
+	}
+	return Node{Data: cloneNode(n.Data, opts)}
+}
+
+func cloneNode(node *html.Node, opts CloneOptions) *html.Node {
+	result := &html.Node{
+		Type:      node.Type,
+		DataAtom:  node.DataAtom,
+		Data:      node.Data,
+		Namespace: node.Namespace,
+	}
+	if node.Attr != nil {
+		result.Attr = append(make([]html.Attribute, 0, len(node.Attr)), node.Attr...)
+	}
+	if opts.Mapping != nil {
+		opts.Mapping[node] = result
+	}
+	if opts.Deep {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			result.AppendChild(cloneNode(child, opts))
+		}
+	}
+	return result
+}
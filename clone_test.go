@@ -0,0 +1,73 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"testing"
+)
+
+func TestNode_Clone(t *testing.T) {
+	doc := parse(`<div id="a"><p class="x">one <b>two</b></p><!-- c --></div><span></span>`)
+	div := doc.GetNode(Tag(`div`))
+	input := doc.OuterHTML()
+
+	clone := div.Clone(true)
+	if clone.Data == div.Data || clone.Depth != 0 || clone.Match != nil || clone.Parent().Data != nil || clone.NextSibling().Data != nil {
+		t.Fatal(clone)
+	}
+	if v := clone.OuterHTML(); v != div.OuterHTML() {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	clone.GetNode(Tag(`p`)).SetAttr(``, `class`, `y`).AppendChild(Node{Data: &html.Node{Type: html.TextNode, Data: `!`}})
+	clone.SetAttr(``, `id`, `b`)
+	if v := doc.OuterHTML(); v != input {
+		t.Errorf("unexpected output:\n%q\n%q", v, input)
+	}
+	if v := clone.OuterHTML(); v != `<div id="b"><p class="y">one <b>two</b>!</p><!-- c --></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+
+	shallow := div.Clone(false)
+	if v := shallow.OuterHTML(); v != `<div id="a"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+
+	if v := (Node{}).Clone(true); v.Data != nil {
+		t.Error(v)
+	}
+}
+
+func TestNode_CloneWithOptions(t *testing.T) {
+	doc := parse(`<p>a<b>b</b></p>`)
+	p := doc.GetNode(Tag(`p`))
+	mapping := make(map[*html.Node]*html.Node)
+	clone := p.CloneWithOptions(CloneOptions{Deep: true, Mapping: mapping})
+	if len(mapping) != 4 || mapping[p.Data] != clone.Data {
+		t.Fatal(mapping)
+	}
+	b := p.GetNode(Tag(`b`))
+	if v := mapping[b.Data]; v == nil || v == b.Data || v.Parent != clone.Data || v.FirstChild != mapping[b.Data.FirstChild] {
+		t.Error(v)
+	}
+
+	mapping = make(map[*html.Node]*html.Node)
+	p.CloneWithOptions(CloneOptions{Mapping: mapping})
+	if len(mapping) != 1 {
+		t.Error(mapping)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+// And builds a filter matching nodes that match every one of the given filters (evaluated in order, short-circuiting),
+// note that nil filters are stripped, and if none remain the result will be nil (absent, and therefore stripped in
+// turn by any filter chain it is used in)
+func And(filters ...func(node Node) bool) func(node Node) bool {
+	filters = (filterConfig{Filters: filters}).filters()
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	return func(node Node) bool {
+		for _, filter := range filters {
+			if !filter(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or builds a filter matching nodes that match any of the given filters (evaluated in order, short-circuiting), note
+// that nil filters are stripped, and if none remain the result will be nil (see the `And` function)
+func Or(filters ...func(node Node) bool) func(node Node) bool {
+	filters = (filterConfig{Filters: filters}).filters()
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	return func(node Node) bool {
+		for _, filter := range filters {
+			if filter(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not builds a filter matching nodes that don't match the given filter, returning nil if filter is nil (an absent
+// filter remains absent)
+func Not(filter func(node Node) bool) func(node Node) bool {
+	if filter == nil {
+		return nil
+	}
+	return func(node Node) bool {
+		return !filter(node)
+	}
+}
+
+// AllOf builds a single filter from filter chains (e.g. as returned by `Compile`), matching nodes that would be
+// matched by every chain, relative to the node's last match (the `Match` field), or if that is nil, only the node
+// itself, note that nil filters are stripped from each chain, and chains that are then empty are treated as absent,
+// and if no chains remain the result will be nil (see the `And` function)
+func AllOf(chains ...[]func(node Node) bool) func(node Node) bool {
+	chains = filterChains(chains)
+	if len(chains) == 0 {
+		return nil
+	}
+	return func(node Node) bool {
+		for _, chain := range chains {
+			if !matchChain(node, chain) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf builds a single filter from filter chains, matching nodes that would be matched by any chain, see the
+// `AllOf` function for details
+func AnyOf(chains ...[]func(node Node) bool) func(node Node) bool {
+	chains = filterChains(chains)
+	if len(chains) == 0 {
+		return nil
+	}
+	return func(node Node) bool {
+		for _, chain := range chains {
+			if matchChain(node, chain) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,161 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"github.com/go-test/deep"
+	"strings"
+	"testing"
+)
+
+func tagFilter(tag string) func(node Node) bool {
+	return func(node Node) bool {
+		return node.Tag() == tag
+	}
+}
+
+func outerHTMLs(nodes []Node) (result []string) {
+	for _, node := range nodes {
+		result = append(result, node.OuterHTML())
+	}
+	return
+}
+
+func TestAnd(t *testing.T) {
+	if v := And(); v != nil {
+		t.Error("expected nil")
+	}
+	if v := And(nil, nil); v != nil {
+		t.Error("expected nil")
+	}
+	var calls []string
+	filter := func(name string, result bool) func(node Node) bool {
+		return func(node Node) bool {
+			calls = append(calls, name)
+			return result
+		}
+	}
+	if v := And(nil, filter(`a`, true), nil, filter(`b`, false), filter(`c`, true))(Node{}); v {
+		t.Error(v)
+	}
+	if v := And(filter(`d`, true), filter(`e`, true))(Node{}); !v {
+		t.Error(v)
+	}
+	if diff := deep.Equal(calls, []string{`a`, `b`, `d`, `e`}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestOr(t *testing.T) {
+	if v := Or(nil); v != nil {
+		t.Error("expected nil")
+	}
+	node := parse(`<div><a></a><section hidden></section><section></section><b></b></div>`)
+	if diff := deep.Equal(
+		outerHTMLs(node.FilterNodes(Or(tagFilter(`div`), nil, And(tagFilter(`section`), Not(func(node Node) bool {
+			_, ok := node.GetAttr(``, `hidden`)
+			return ok
+		}))))),
+		[]string{
+			`<div><a></a><section hidden=""></section><section></section><b></b></div>`,
+			`<section></section>`,
+		},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"output diff:"}, diff...), "    \n"))
+	}
+	if v := Or(tagFilter(`a`), tagFilter(`b`))(Node{}); v {
+		t.Error(v)
+	}
+}
+
+func TestNot(t *testing.T) {
+	if v := Not(nil); v != nil {
+		t.Error("expected nil")
+	}
+	if v := Not(tagFilter(`a`))(Node{}); !v {
+		t.Error(v)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	if v := AnyOf(nil, []func(node Node) bool{nil}); v != nil {
+		t.Error("expected nil")
+	}
+	node := parse(`<div><p><a>1</a></p><span><a>2</a><i><a>3</a></i></span></div><a>4</a>`)
+	if diff := deep.Equal(
+		outerHTMLs(node.FilterNodes(AnyOf(
+			[]func(node Node) bool{tagFilter(`p`), tagFilter(`a`)},
+			[]func(node Node) bool{
+				tagFilter(`span`),
+				func(node Node) bool {
+					return node.Tag() == `a` && node.Offset() == 1
+				},
+			},
+			nil,
+		))),
+		[]string{`<a>1</a>`, `<a>2</a>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"output diff:"}, diff...), "    \n"))
+	}
+	// chains are matched relative to the last match only
+	span := node.GetNode(tagFilter(`span`))
+	if diff := deep.Equal(
+		outerHTMLs(span.FilterNodes(AnyOf([]func(node Node) bool{tagFilter(`div`), tagFilter(`a`)}))),
+		[]string(nil),
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"scoped output diff:"}, diff...), "    \n"))
+	}
+	// chains may be used after other filters
+	if diff := deep.Equal(
+		outerHTMLs(node.FilterNodes(tagFilter(`span`), AnyOf([]func(node Node) bool{tagFilter(`span`), tagFilter(`a`)}))),
+		[]string{`<a>2</a>`, `<a>3</a>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"chained output diff:"}, diff...), "    \n"))
+	}
+	// the last match is the root of the search, or the node itself if there is none
+	if v := AnyOf([]func(node Node) bool{tagFilter(`i`), tagFilter(`a`)})(node.GetNode(tagFilter(`i`), tagFilter(`a`))); !v {
+		t.Error(v)
+	}
+	a := node.GetNode(tagFilter(`i`), tagFilter(`a`))
+	a.Match = nil
+	if v := AnyOf([]func(node Node) bool{tagFilter(`i`), tagFilter(`a`)})(a); v {
+		t.Error(v)
+	}
+	if v := AnyOf([]func(node Node) bool{tagFilter(`a`)})(a); !v {
+		t.Error(v)
+	}
+	if v := AnyOf([]func(node Node) bool{tagFilter(`a`)})(Node{}); v {
+		t.Error(v)
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	if v := AllOf(); v != nil {
+		t.Error("expected nil")
+	}
+	node := parse(`<div><p><a>1</a></p><span><a class="x">2</a><i><a class="x">3</a></i></span></div>`)
+	if diff := deep.Equal(
+		outerHTMLs(node.FilterNodes(AllOf(
+			MustCompile(`span a`),
+			MustCompile(`.x`),
+			MustCompile(`span > *`),
+		))),
+		[]string{`<a class="x">2</a>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"output diff:"}, diff...), "    \n"))
+	}
+}
@@ -0,0 +1,386 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Article is the metadata of the main content of a document, see `MainContent`, note that urls are not resolved (see
+// the `Node.Links` method)
+type Article struct {
+	// Title is the title of the article, preferring the OpenGraph or Twitter title, then the title element (without
+	// any trailing site name), then the first h1 element
+	Title string
+	// Byline is the author of the article, from the author meta element, or an element marked as the author (e.g.
+	// `rel="author"`, or a class of "byline")
+	Byline string
+	// Published is the publication date of the article, from the published time meta elements, or a time element, or
+	// the zero value, if it was not found (or could not be parsed)
+	Published time.Time
+	// Image is the url of the lead image of the article, preferring the OpenGraph or Twitter image, then the first img
+	// element in the main content
+	Image string
+}
+
+var (
+	contentUnlikely = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	contentMaybe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	contentPositive = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	contentNegative = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	contentByline   = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+
+	// contentDateLayouts are attempted, in order, when parsing the published date
+	contentDateLayouts = [...]string{
+		time.RFC3339Nano,
+		`2006-01-02T15:04:05Z0700`,
+		`2006-01-02T15:04:05`,
+		`2006-01-02T15:04`,
+		`2006-01-02 15:04:05`,
+		`2006-01-02`,
+		time.RFC1123Z,
+		time.RFC1123,
+		`January 2, 2006`,
+		`Jan 2, 2006`,
+		`2 January 2006`,
+	}
+)
+
+// contentScorer holds the scores of candidate (container) elements, in the order they were first scored
+type contentScorer struct {
+	doc    Node
+	scores map[*html.Node]float64
+	nodes  []Node
+}
+
+// MainContent finds the element containing the main content (e.g. the article) of a document, in the style of
+// readability, returning it along with the metadata of the article.
+//
+// Each paragraph (p, pre and td elements, and div elements without block children) with at least 25 characters of
+// text adds a score (based on the length of the text, and the number of commas) to its ancestors, diminishing with
+// distance. Each candidate ancestor has an initial score based on its tag, and whether its class or id is likely to
+// be content (e.g. "article") or not (e.g. "sidebar"), and the final score is reduced proportionally to the link
+// density (the fraction of text within links). Sub-trees that are unlikely to be content, such as navigation,
+// scripts, forms, hidden elements, or elements with a class or id such as "comment", are ignored.
+//
+// If there are no candidates, the body (or the receiver) will be returned, and an empty Node will be returned only if
+// doc is empty. The metadata is extracted from the whole document, see `Metadata`, and `Article`.
+func MainContent(doc Node) (Node, Article) {
+	if doc.Data == nil {
+		return Node{}, Article{}
+	}
+
+	s := contentScorer{doc: doc, scores: make(map[*html.Node]float64)}
+	s.walk(doc)
+
+	var (
+		best      Node
+		bestScore float64
+	)
+	for _, node := range s.nodes {
+		score := s.scores[node.Data] * (1 - contentLinkDensity(node))
+		if best.Data == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best.Data == nil {
+		best = doc
+		if body, ok := doc.FindNode(Tag(`body`)); ok {
+			best = body
+		}
+	}
+
+	return best, contentArticle(doc, best)
+}
+
+func (s *contentScorer) walk(node Node) {
+	for child := range node.ChildrenSeq() {
+		if child.Type() != html.ElementNode || child.Data.Namespace != `` || contentSkip(child) {
+			continue
+		}
+		if contentParagraph(child) {
+			s.paragraph(child)
+		}
+		switch child.Tag() {
+		case `p`, `pre`:
+		default:
+			s.walk(child)
+		}
+	}
+}
+
+// paragraph adds the score for a paragraph to its ancestors, up to 5 levels
+func (s *contentScorer) paragraph(node Node) {
+	text := node.OuterWords()
+	if len(text) < 25 {
+		return
+	}
+	score := 1 + float64(strings.Count(text, `,`)) + min(float64(len(text)/100), 3)
+	level := 0
+	for ancestor := node.Parent(); level < 5 && ancestor.Type() == html.ElementNode; ancestor = ancestor.Parent() {
+		if _, ok := s.scores[ancestor.Data]; !ok {
+			s.scores[ancestor.Data] = contentWeight(ancestor)
+			s.nodes = append(s.nodes, ancestor)
+		}
+		divider := 1.0
+		if level == 1 {
+			divider = 2
+		} else if level > 1 {
+			divider = float64(level * 3)
+		}
+		s.scores[ancestor.Data] += score / divider
+		if ancestor.Data == s.doc.Data {
+			break
+		}
+		level++
+	}
+}
+
+// contentSkip returns true if the sub-tree of the element is unlikely to be content
+func contentSkip(node Node) bool {
+	switch node.Tag() {
+	case `script`, `style`, `noscript`, `template`, `nav`, `aside`, `footer`, `form`, `button`, `iframe`, `select`,
+		`textarea`, `input`, `object`, `embed`:
+		return true
+	}
+	if _, ok := node.GetAttr(``, `hidden`); ok {
+		return true
+	}
+	if strings.EqualFold(strings.TrimSpace(node.GetAttrVal(``, `aria-hidden`)), `true`) {
+		return true
+	}
+	if style := strings.ToLower(node.GetAttrVal(``, `style`)); strings.Contains(strings.ReplaceAll(style, ` `, ``), `display:none`) {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, `role`))) {
+	case `menu`, `menubar`, `complementary`, `navigation`, `alert`, `alertdialog`, `dialog`:
+		return true
+	}
+	switch node.Tag() {
+	case `html`, `body`, `article`, `main`, `a`:
+		return false
+	}
+	match := node.GetAttrVal(``, `class`) + ` ` + node.GetAttrVal(``, `id`)
+	return contentUnlikely.MatchString(match) && !contentMaybe.MatchString(match)
+}
+
+// contentParagraph returns true for p, pre, and td elements, and div elements without block level children
+func contentParagraph(node Node) bool {
+	switch node.Tag() {
+	case `p`, `pre`, `td`:
+		return true
+	case `div`:
+		for child := range node.ChildrenSeq() {
+			switch child.Tag() {
+			case `a`, `blockquote`, `dl`, `div`, `img`, `ol`, `p`, `pre`, `table`, `ul`, `section`, `article`, `h1`,
+				`h2`, `h3`, `h4`, `h5`, `h6`:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// contentWeight is the initial score of a candidate element, from its tag, class and id
+func contentWeight(node Node) (weight float64) {
+	switch node.Tag() {
+	case `div`, `article`, `main`:
+		weight = 5
+	case `pre`, `td`, `blockquote`:
+		weight = 3
+	case `address`, `ol`, `ul`, `dl`, `dd`, `dt`, `li`, `form`:
+		weight = -3
+	case `h1`, `h2`, `h3`, `h4`, `h5`, `h6`, `th`:
+		weight = -5
+	}
+	for _, v := range [...]string{node.GetAttrVal(``, `class`), node.GetAttrVal(``, `id`)} {
+		if v == `` {
+			continue
+		}
+		if contentNegative.MatchString(v) {
+			weight -= 25
+		}
+		if contentPositive.MatchString(v) {
+			weight += 25
+		}
+	}
+	return
+}
+
+// contentLinkDensity returns the fraction of the text of the sub-tree that is within links
+func contentLinkDensity(node Node) float64 {
+	total := len(node.OuterWords())
+	if total == 0 {
+		return 0
+	}
+	var links int
+	for link := range node.All(Tag(`a`)) {
+		links += len(link.OuterWords())
+	}
+	return min(float64(links)/float64(total), 1)
+}
+
+func contentArticle(doc Node, content Node) (a Article) {
+	var (
+		meta  = Metadata(doc)
+		named = make(map[string]string)
+	)
+	for node := range doc.All(Tag(`meta`)) {
+		value := strings.TrimSpace(node.GetAttrVal(``, `content`))
+		if value == `` {
+			continue
+		}
+		for _, key := range [...]string{`name`, `property`, `itemprop`} {
+			if k := strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, key))); k != `` {
+				if _, ok := named[k]; !ok {
+					named[k] = value
+				}
+			}
+		}
+	}
+	first := func(values ...[]string) string {
+		for _, v := range values {
+			if len(v) != 0 && v[0] != `` {
+				return v[0]
+			}
+		}
+		return ``
+	}
+
+	// title
+	if a.Title = first(meta.OpenGraph[`og:title`], meta.Twitter[`twitter:title`]); a.Title == `` {
+		a.Title = contentTitle(meta.Title)
+	}
+	if a.Title == `` {
+		if h1, ok := doc.FindNode(Tag(`h1`)); ok {
+			a.Title = h1.OuterWords()
+		}
+	}
+
+	// byline
+	for _, key := range [...]string{`author`, `article:author`, `dc.creator`, `parsely-author`, `sailthru.author`} {
+		if v := named[key]; v != `` && !strings.Contains(v, `://`) {
+			a.Byline = v
+			break
+		}
+	}
+	if a.Byline == `` {
+		if node, ok := doc.FindNode(contentBylineNode); ok {
+			if name, ok := node.FindNode(AttrEquals(``, `itemprop`, `name`)); ok {
+				node = name
+			}
+			a.Byline = node.OuterWords()
+		}
+	}
+
+	// published
+	for _, key := range [...]string{`article:published_time`, `datepublished`, `date`, `pubdate`, `publishdate`,
+		`publish-date`, `publish_date`, `dc.date.issued`, `dc.date`, `dcterms.created`, `parsely-pub-date`,
+		`sailthru.date`} {
+		if v, ok := contentDate(named[key]); ok {
+			a.Published = v
+			break
+		}
+	}
+	if a.Published.IsZero() {
+		for _, filter := range [...]func(node Node) bool{
+			func(node Node) bool {
+				_, ok := node.GetAttr(``, `datetime`)
+				return ok && node.Tag() == `time` && strings.EqualFold(node.GetAttrVal(``, `itemprop`), `datePublished`)
+			},
+			func(node Node) bool {
+				_, ok := node.GetAttr(``, `pubdate`)
+				return ok && node.Tag() == `time`
+			},
+		} {
+			if node, ok := doc.FindNode(filter); ok {
+				if v, ok := contentDate(node.GetAttrVal(``, `datetime`)); ok {
+					a.Published = v
+					break
+				}
+			}
+		}
+	}
+	if a.Published.IsZero() {
+		if node, ok := content.FindNode(func(node Node) bool {
+			_, ok := node.GetAttr(``, `datetime`)
+			return ok && node.Tag() == `time`
+		}); ok {
+			a.Published, _ = contentDate(node.GetAttrVal(``, `datetime`))
+		}
+	}
+
+	// image
+	if a.Image = first(meta.OpenGraph[`og:image`], meta.OpenGraph[`og:image:url`], meta.Twitter[`twitter:image`], meta.Twitter[`twitter:image:src`]); a.Image == `` {
+		if node, ok := doc.FindNode(func(node Node) bool {
+			return node.Tag() == `link` && strings.EqualFold(strings.TrimSpace(node.GetAttrVal(``, `rel`)), `image_src`)
+		}); ok {
+			a.Image = strings.TrimSpace(node.GetAttrVal(``, `href`))
+		}
+	}
+	if a.Image == `` {
+		for node := range content.All(Tag(`img`)) {
+			if v := strings.TrimSpace(node.GetAttrVal(``, `src`)); v != `` {
+				a.Image = v
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// contentTitle removes a trailing site name (e.g. "Title | Site"), if the remainder has at least 3 words
+func contentTitle(title string) string {
+	for _, sep := range [...]string{` | `, ` - `, ` – `, ` — `, ` :: `, ` » `, ` / `} {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			if v := strings.TrimSpace(title[:i]); len(strings.Fields(v)) >= 3 {
+				return v
+			}
+		}
+	}
+	return title
+}
+
+func contentBylineNode(node Node) bool {
+	if node.Type() != html.ElementNode || node.Tag() == `meta` || node.Tag() == `link` {
+		return false
+	}
+	if v := node.GetAttrVal(``, `rel`); strings.EqualFold(strings.TrimSpace(v), `author`) ||
+		strings.Contains(strings.ToLower(node.GetAttrVal(``, `itemprop`)), `author`) ||
+		contentByline.MatchString(node.GetAttrVal(``, `class`)+` `+node.GetAttrVal(``, `id`)) {
+		words := node.OuterWords()
+		return words != `` && len(words) < 100
+	}
+	return false
+}
+
+func contentDate(s string) (time.Time, bool) {
+	if s = strings.TrimSpace(s); s == `` {
+		return time.Time{}, false
+	}
+	for _, layout := range contentDateLayouts {
+		if v, err := time.Parse(layout, s); err == nil {
+			return v, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,112 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMainContent(t *testing.T) {
+	const input = `<html><head>
+	<title>A Long Enough Article Title | Example Site</title>
+	<meta name="author" content="Jane Doe">
+	<meta property="article:published_time" content="2020-01-02T03:04:05Z">
+</head><body>
+<header id="header"><h1>Example Site</h1></header>
+<nav><p>Home, About, Contact, Blog, Archive, Search, Login, Register</p></nav>
+<div class="sidebar"><p>Some sidebar content, with a long enough paragraph, and commas, so it would score.</p></div>
+<div id="wrapper">
+	<div class="article-body" id="story">
+		<p>The first paragraph of the article, which is long enough to be considered content, and has commas.</p>
+		<p>The second paragraph of the article, with even more text, so that the container scores highly.</p>
+		<img src="lead.jpg">
+		<div>A div without block children, acting as a paragraph, with enough text to count.</div>
+		<p>Short.</p>
+	</div>
+	<div class="links">
+		<p><a href="/a">A link heavy paragraph, that is long enough to score</a>, <a href="/b">but is mostly links</a></p>
+	</div>
+</div>
+<div class="comments"><p>A comment, that is long enough, with many, many, many, many, many, many commas.</p></div>
+<script>var s = "A script, that is long enough, with many, many, many, many, many, many commas.";</script>
+</body></html>`
+	node, article := MainContent(parse(input))
+	if v := node.GetAttrVal(``, `id`); v != `story` {
+		t.Error(node)
+	}
+	if node.Depth != 4 {
+		t.Error(node.Depth)
+	}
+	if article != (Article{
+		Title:     `A Long Enough Article Title`,
+		Byline:    `Jane Doe`,
+		Published: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Image:     `lead.jpg`,
+	}) {
+		t.Errorf("%+v", article)
+	}
+}
+
+func TestMainContent_metadata(t *testing.T) {
+	const input = `<html><head>
+	<title>Short | Site</title>
+	<meta property="og:image" content="og.png">
+	<meta name="author" content="https://example.com/author">
+</head><body><article>
+	<h1>Heading</h1>
+	<p class="byline">By <span itemprop="author" itemscope><span itemprop="name">John Smith</span></span></p>
+	<time datetime="2021-05-06">May 6</time>
+	<p>The only paragraph of the article, which is long enough to be considered content.</p>
+	<img src="content.png">
+</article></body></html>`
+	node, article := MainContent(parse(input))
+	if node.Tag() != `article` {
+		t.Error(node)
+	}
+	if article != (Article{
+		Title:     `Short | Site`,
+		Byline:    `John Smith`,
+		Published: time.Date(2021, 5, 6, 0, 0, 0, 0, time.UTC),
+		Image:     `og.png`,
+	}) {
+		t.Errorf("%+v", article)
+	}
+
+	node, article = MainContent(parse(`<html><body><h1>Only A Heading</h1><p>short</p></body></html>`))
+	if node.Tag() != `body` || article != (Article{Title: `Only A Heading`}) {
+		t.Errorf("%s %+v", node, article)
+	}
+
+	node, article = MainContent(Node{})
+	if node.Data != nil || article != (Article{}) {
+		t.Errorf("%s %+v", node, article)
+	}
+}
+
+func TestContentTitle(t *testing.T) {
+	for input, expected := range map[string]string{
+		`One Two Three - Site`:        `One Two Three`,
+		`One Two - Site`:              `One Two - Site`,
+		`One - Two Three Four | Site`: `One - Two Three Four`,
+		`No separator`:                `No separator`,
+	} {
+		if v := contentTitle(input); v != expected {
+			t.Error(input, v)
+		}
+	}
+}
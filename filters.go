@@ -0,0 +1,139 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"errors"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+// Tag builds a filter matching element nodes with any of the given tag names (see the `Node.Tag` method), or any
+// element node if no names are provided
+func Tag(names ...string) func(node Node) bool {
+	return func(node Node) bool {
+		if node.Type() != html.ElementNode {
+			return false
+		}
+		if len(names) == 0 {
+			return true
+		}
+		tag := node.Tag()
+		for _, name := range names {
+			if name == tag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ID builds a filter matching element nodes with an `id` attribute equal to id
+func ID(id string) func(node Node) bool {
+	return func(node Node) bool {
+		attr, ok := node.GetAttr(``, `id`)
+		return ok && node.Type() == html.ElementNode && attr.Val == id
+	}
+}
+
+// Class builds a filter matching element nodes with all of the given classes (see the `Node.HasClass` method), or
+// any element node if no classes are provided
+func Class(all ...string) func(node Node) bool {
+	return func(node Node) bool {
+		if node.Type() != html.ElementNode {
+			return false
+		}
+		for _, class := range all {
+			if !node.HasClass(class) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// HasAttr builds a filter matching nodes with an attribute matched by `Node.GetAttr`
+func HasAttr(namespace string, key string) func(node Node) bool {
+	return func(node Node) bool {
+		_, ok := node.GetAttr(namespace, key)
+		return ok
+	}
+}
+
+// AttrEquals builds a filter matching nodes with an attribute matched by `Node.GetAttr`, with a value equal to val
+func AttrEquals(namespace string, key string, val string) func(node Node) bool {
+	return attrFilter(namespace, key, func(v string) bool {
+		return v == val
+	})
+}
+
+// AttrPrefix builds a filter matching nodes with an attribute matched by `Node.GetAttr`, with a value starting with
+// prefix
+func AttrPrefix(namespace string, key string, prefix string) func(node Node) bool {
+	return attrFilter(namespace, key, func(v string) bool {
+		return strings.HasPrefix(v, prefix)
+	})
+}
+
+// AttrSuffix builds a filter matching nodes with an attribute matched by `Node.GetAttr`, with a value ending with
+// suffix
+func AttrSuffix(namespace string, key string, suffix string) func(node Node) bool {
+	return attrFilter(namespace, key, func(v string) bool {
+		return strings.HasSuffix(v, suffix)
+	})
+}
+
+// AttrContains builds a filter matching nodes with an attribute matched by `Node.GetAttr`, with a value containing
+// substr
+func AttrContains(namespace string, key string, substr string) func(node Node) bool {
+	return attrFilter(namespace, key, func(v string) bool {
+		return strings.Contains(v, substr)
+	})
+}
+
+// AttrMatches builds a filter matching nodes with an attribute matched by `Node.GetAttr`, with a value matching the
+// regular expression re, note that it will panic if re is nil
+func AttrMatches(namespace string, key string, re *regexp.Regexp) func(node Node) bool {
+	if re == nil {
+		panic(errors.New("htmlutil.AttrMatches nil re"))
+	}
+	return attrFilter(namespace, key, re.MatchString)
+}
+
+// Type builds a filter matching nodes with the given type (see the `Node.Type` method)
+func Type(t html.NodeType) func(node Node) bool {
+	return func(node Node) bool {
+		return node.Type() == t
+	}
+}
+
+// TextContains builds a filter matching nodes with outer text (see the `Node.OuterText` method) containing substr,
+// note that this will also match every ancestor of such a node, so it is typically combined with other filters
+func TextContains(substr string) func(node Node) bool {
+	return func(node Node) bool {
+		return strings.Contains(node.OuterText(), substr)
+	}
+}
+
+// DirectChild builds a filter matching nodes that are direct children of the last match, i.e. with an offset of one
+// (see the `Node.Offset` method), a-la the CSS child combinator
+func DirectChild() func(node Node) bool {
+	return func(node Node) bool {
+		return node.Offset() == 1
+	}
+}
@@ -0,0 +1,150 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	const input = `<div id="main" class="a b">
+	<a class="b" href="https://example.com/one">one</a>
+	<span HREF="/two" data-x="">two</span>
+	<svg><a xlink:href="#three">three</a></svg>
+	<!--comment-->
+	<p><b>four</b></p>
+</div>`
+	type TestCase struct {
+		Filters []func(node Node) bool
+		Output  []string
+	}
+	testCases := []TestCase{
+		{
+			Filters: []func(node Node) bool{Tag(`a`, `span`, `b`)},
+			Output: []string{
+				`<a class="b" href="https://example.com/one">one</a>`,
+				`<span href="/two" data-x="">two</span>`,
+				`<a xlink:href="#three">three</a>`,
+				`<b>four</b>`,
+			},
+		},
+		{
+			Filters: []func(node Node) bool{ID(`main`), And(Tag(), DirectChild())},
+			Output: []string{
+				`<a class="b" href="https://example.com/one">one</a>`,
+				`<span href="/two" data-x="">two</span>`,
+				`<svg><a xlink:href="#three">three</a></svg>`,
+				`<p><b>four</b></p>`,
+			},
+		},
+		{
+			Filters: []func(node Node) bool{Class(`b`, `a`)},
+			Output: []string{
+				`<div id="main" class="a b">` + "\n\t" + `<a class="b" href="https://example.com/one">one</a>` + "\n\t" + `<span href="/two" data-x="">two</span>` + "\n\t" + `<svg><a xlink:href="#three">three</a></svg>` + "\n\t" + `<!--comment-->` + "\n\t" + `<p><b>four</b></p>` + "\n" + `</div>`,
+			},
+		},
+		{
+			Filters: []func(node Node) bool{Tag(`p`), Class()},
+			Output:  []string{`<b>four</b>`},
+		},
+		{
+			Filters: []func(node Node) bool{HasAttr(``, `Data-X`)},
+			Output:  []string{`<span href="/two" data-x="">two</span>`},
+		},
+		{
+			Filters: []func(node Node) bool{HasAttr(`xlink`, `href`)},
+			Output:  []string{`<a xlink:href="#three">three</a>`},
+		},
+		{
+			Filters: []func(node Node) bool{HasAttr(`xlink`, `HREF`)},
+			Output:  nil,
+		},
+		{
+			Filters: []func(node Node) bool{AttrEquals(``, `href`, `/two`)},
+			Output:  []string{`<span href="/two" data-x="">two</span>`},
+		},
+		{
+			Filters: []func(node Node) bool{AttrPrefix(``, `href`, `https:`)},
+			Output:  []string{`<a class="b" href="https://example.com/one">one</a>`},
+		},
+		{
+			Filters: []func(node Node) bool{AttrSuffix(`xlink`, `href`, `three`)},
+			Output:  []string{`<a xlink:href="#three">three</a>`},
+		},
+		{
+			Filters: []func(node Node) bool{AttrContains(``, `href`, `example`)},
+			Output:  []string{`<a class="b" href="https://example.com/one">one</a>`},
+		},
+		{
+			Filters: []func(node Node) bool{AttrMatches(``, `href`, regexp.MustCompile(`^/\w+$`))},
+			Output:  []string{`<span href="/two" data-x="">two</span>`},
+		},
+		{
+			Filters: []func(node Node) bool{Type(html.CommentNode)},
+			Output:  []string{`<!--comment-->`},
+		},
+		{
+			Filters: []func(node Node) bool{Tag(`p`), And(Tag(), TextContains(`four`))},
+			Output:  []string{`<b>four</b>`},
+		},
+		{
+			Filters: []func(node Node) bool{Tag(`svg`), And(Tag(`a`), TextContains(`three`))},
+			Output:  []string{`<a xlink:href="#three">three</a>`},
+		},
+	}
+	root := parse(input)
+	for i, testCase := range testCases {
+		name := fmt.Sprintf("Filters_#%d", i+1)
+		if diff := deep.Equal(
+			outerHTMLs(root.FilterNodes(testCase.Filters...)),
+			testCase.Output,
+		); diff != nil {
+			t.Error(strings.Join(append([]string{name + " output diff:"}, diff...), "    \n"))
+		}
+	}
+}
+
+func TestFilters_nil(t *testing.T) {
+	for i, filter := range []func(node Node) bool{
+		Tag(),
+		ID(``),
+		Class(),
+		HasAttr(``, `id`),
+		AttrEquals(``, `id`, ``),
+		Type(html.ElementNode),
+		TextContains(`x`),
+		DirectChild(),
+	} {
+		if filter(Node{}) {
+			t.Error(i)
+		}
+	}
+}
+
+func TestAttrMatches_panic(t *testing.T) {
+	defer func() {
+		if r := fmt.Sprint(recover()); r != `htmlutil.AttrMatches nil re` {
+			t.Error(r)
+		}
+	}()
+	AttrMatches(``, `id`, nil)
+}
@@ -0,0 +1,537 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Form models a html form element, and the state of its controls, see `NewForm`
+	Form struct {
+		// Node is the form element
+		Node Node
+		// Controls are the submittable elements associated with the form, in tree order
+		Controls []*FormControl
+	}
+
+	// FormControl models the state of a submittable element (button, input, select, or textarea), where the exported
+	// fields may be modified directly, or via the `Form.Set` method
+	FormControl struct {
+		// Node is the control element
+		Node Node
+		// Name is the value of the name attribute
+		Name string
+		// Type is the (lower case) type of input and button elements, defaulting to "text" and "submit" respectively,
+		// or "select-one", "select-multiple", or "textarea"
+		Type string
+		// Value is the value of the control, which is the value attribute, defaulting to "on" for checkboxes and radio
+		// buttons, or the text content of a textarea, and is unused by selects
+		Value string
+		// Checked is the checkedness of checkboxes and radio buttons
+		Checked bool
+		// Disabled is true if the control is disabled, including via a fieldset
+		Disabled bool
+		// Options are the options of a select
+		Options []*FormOption
+	}
+
+	// FormOption models an option element of a select
+	FormOption struct {
+		// Node is the option element
+		Node Node
+		// Value is the value attribute, defaulting to the (whitespace collapsed) text
+		Value string
+		// Selected is the selectedness of the option
+		Selected bool
+		// Disabled is true if the option (or its optgroup) is disabled
+		Disabled bool
+	}
+
+	formEntry struct {
+		name  string
+		value string
+		file  bool
+	}
+)
+
+// NewForm builds a `Form` from a form element, where the controls include all button, input, select and textarea
+// elements in the document (found via the parent links of the form) that are associated with the form, either as
+// descendants without a form attribute, or via a form attribute matching the id of the form.
+//
+// The state of each control is initialised from the markup, as per the HTML standard, e.g. the checked and selected
+// attributes, and the default selection of the first non-disabled option of a select that isn't multiple (with a
+// display size of 1).
+func NewForm(node Node) (*Form, error) {
+	if node.Tag() != `form` || node.Data.Namespace != `` {
+		return nil, errors.New("htmlutil.NewForm not a form element")
+	}
+
+	f := Form{Node: node}
+
+	root := node
+	for parent := root.Parent(); parent.Data != nil; parent = parent.Parent() {
+		root = parent
+	}
+
+	id, hasID := node.GetAttr(``, `id`)
+
+	for n := range root.All(Tag(`button`, `input`, `select`, `textarea`)) {
+		if n.Data.Namespace != `` {
+			continue
+		}
+		if v, ok := n.GetAttr(``, `form`); ok {
+			if !hasID || v.Val != id.Val {
+				continue
+			}
+		} else if formOwner(n).Data != node.Data {
+			continue
+		}
+		f.Controls = append(f.Controls, newFormControl(n))
+	}
+
+	return &f, nil
+}
+
+// formOwner returns the nearest ancestor form element
+func formOwner(node Node) Node {
+	for parent := node.Parent(); parent.Data != nil; parent = parent.Parent() {
+		if parent.Tag() == `form` && parent.Data.Namespace == `` {
+			return parent
+		}
+	}
+	return Node{}
+}
+
+func newFormControl(node Node) *FormControl {
+	c := FormControl{
+		Node:     node,
+		Name:     node.GetAttrVal(``, `name`),
+		Disabled: formDisabled(node),
+	}
+
+	switch node.Tag() {
+	case `input`:
+		c.Type = strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, `type`)))
+		switch c.Type {
+		case `hidden`, `search`, `tel`, `url`, `email`, `password`, `date`, `month`, `week`, `time`,
+			`datetime-local`, `number`, `range`, `color`, `checkbox`, `radio`, `file`, `submit`, `image`, `reset`,
+			`button`:
+		default:
+			c.Type = `text`
+		}
+		c.Value = node.GetAttrVal(``, `value`)
+		switch c.Type {
+		case `checkbox`, `radio`:
+			if _, ok := node.GetAttr(``, `value`); !ok {
+				c.Value = `on`
+			}
+			_, c.Checked = node.GetAttr(``, `checked`)
+		}
+
+	case `button`:
+		c.Type = strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, `type`)))
+		switch c.Type {
+		case `reset`, `button`:
+		default:
+			c.Type = `submit`
+		}
+		c.Value = node.GetAttrVal(``, `value`)
+
+	case `textarea`:
+		c.Type = `textarea`
+		c.Value = node.InnerText()
+
+	case `select`:
+		c.Type = `select-one`
+		_, multiple := node.GetAttr(``, `multiple`)
+		if multiple {
+			c.Type = `select-multiple`
+		}
+		for option := range node.All(Tag(`option`)) {
+			if option.Data.Namespace != `` {
+				continue
+			}
+			o := FormOption{Node: option}
+			if v, ok := option.GetAttr(``, `value`); ok {
+				o.Value = v.Val
+			} else {
+				o.Value = strings.Join(strings.Fields(option.OuterText()), ` `)
+			}
+			_, o.Selected = option.GetAttr(``, `selected`)
+			_, o.Disabled = option.GetAttr(``, `disabled`)
+			if parent := option.Parent(); parent.Tag() == `optgroup` {
+				if _, ok := parent.GetAttr(``, `disabled`); ok {
+					o.Disabled = true
+				}
+			}
+			c.Options = append(c.Options, &o)
+		}
+		if !multiple {
+			size, err := strconv.Atoi(strings.TrimSpace(node.GetAttrVal(``, `size`)))
+			if err != nil || size < 1 {
+				size = 1
+			}
+			// only the last selected option remains selected
+			var selected *FormOption
+			for _, o := range c.Options {
+				if o.Selected {
+					if selected != nil {
+						selected.Selected = false
+					}
+					selected = o
+				}
+			}
+			if selected == nil && size == 1 {
+				for _, o := range c.Options {
+					if !o.Disabled {
+						o.Selected = true
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return &c
+}
+
+// formDisabled returns true if the node has a disabled attribute, or is a descendant of a disabled fieldset, that
+// isn't also a descendant of that fieldset's first legend child
+func formDisabled(node Node) bool {
+	if _, ok := node.GetAttr(``, `disabled`); ok {
+		return true
+	}
+	for child, parent := node, node.Parent(); parent.Data != nil; child, parent = parent, parent.Parent() {
+		if parent.Tag() != `fieldset` {
+			continue
+		}
+		if _, ok := parent.GetAttr(``, `disabled`); !ok {
+			continue
+		}
+		if child.Tag() == `legend` {
+			legend := parent.FirstChild()
+			for legend.Data != nil && legend.Tag() != `legend` {
+				legend = legend.NextSibling()
+			}
+			if legend.Data == child.Data {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Control returns the first control with the given name, or nil
+func (f *Form) Control(name string) *FormControl {
+	for _, c := range f.Controls {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Set modifies the state of the (non-button) controls with the given name, such that they would submit the given
+// values (if they aren't disabled), where checkboxes and radio buttons are checked only if their value is present,
+// options of selects are selected only if their value is present, and other controls are assigned the values in
+// order (or the empty string, if there are fewer values), returning an error if there are no such controls, or if
+// any of the values could not be applied
+func (f *Form) Set(name string, values ...string) error {
+	used := make([]bool, len(values))
+	has := func(value string) (ok bool) {
+		for i, v := range values {
+			if v == value {
+				used[i] = true
+				ok = true
+			}
+		}
+		return
+	}
+
+	var (
+		found bool
+		radio bool
+		next  int
+	)
+	for _, c := range f.Controls {
+		if c.Name != name {
+			continue
+		}
+		switch c.Type {
+		case `submit`, `image`, `reset`, `button`:
+			continue
+		case `checkbox`:
+			c.Checked = has(c.Value)
+		case `radio`:
+			// radio buttons in the same group are mutually exclusive
+			c.Checked = !radio && has(c.Value)
+			radio = radio || c.Checked
+		case `select-one`, `select-multiple`:
+			selected := false
+			for _, o := range c.Options {
+				o.Selected = (!selected || c.Type == `select-multiple`) && has(o.Value)
+				selected = selected || o.Selected
+			}
+		default:
+			for next < len(values) && used[next] {
+				next++
+			}
+			c.Value = ``
+			if next < len(values) {
+				c.Value = values[next]
+				used[next] = true
+			}
+		}
+		found = true
+	}
+
+	if !found {
+		return fmt.Errorf("htmlutil.Form.Set %q: no such control", name)
+	}
+	for i, v := range values {
+		if !used[i] {
+			return fmt.Errorf("htmlutil.Form.Set %q: value %q could not be set", name, v)
+		}
+	}
+	return nil
+}
+
+// Method returns the (upper case) http method for a submission, i.e. "GET" or "POST", from the method attribute of
+// the form, or the formmethod attribute of the submitter (if any)
+func (f *Form) Method(submitter *FormControl) string {
+	if strings.ToLower(strings.TrimSpace(f.attr(submitter, `method`))) == `post` {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// EncType returns the encoding type for a submission, i.e. "application/x-www-form-urlencoded",
+// "multipart/form-data", or "text/plain", from the enctype attribute of the form, or the formenctype attribute of the
+// submitter (if any), note that GET requests always use the default
+func (f *Form) EncType(submitter *FormControl) string {
+	switch v := strings.ToLower(strings.TrimSpace(f.attr(submitter, `enctype`))); v {
+	case `multipart/form-data`, `text/plain`:
+		return v
+	}
+	return `application/x-www-form-urlencoded`
+}
+
+// Action returns the url for a submission, from the action attribute of the form, or the formaction attribute of the
+// submitter (if any), resolved against base (typically the url of the document), which may be nil
+func (f *Form) Action(base *url.URL, submitter *FormControl) (*url.URL, error) {
+	action := strings.TrimSpace(f.attr(submitter, `action`))
+	if action == `` {
+		if base == nil {
+			return &url.URL{}, nil
+		}
+		v := *base
+		return &v, nil
+	}
+	u, err := url.Parse(action)
+	if err != nil {
+		return nil, fmt.Errorf("htmlutil.Form.Action %w", err)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u, nil
+}
+
+func (f *Form) attr(submitter *FormControl, key string) string {
+	if submitter != nil {
+		if v, ok := submitter.Node.GetAttr(``, `form`+key); ok {
+			return v.Val
+		}
+	}
+	return f.Node.GetAttrVal(``, key)
+}
+
+// entries builds the entry list, as per the HTML standard, where submitter (if non-nil) must be a submit button
+func (f *Form) entries(submitter *FormControl) []formEntry {
+	var entries []formEntry
+	for _, c := range f.Controls {
+		if c.Disabled || c.Node.Data == nil {
+			continue
+		}
+		switch c.Type {
+		case `submit`, `image`:
+			if c != submitter {
+				continue
+			}
+		case `reset`, `button`:
+			continue
+		case `checkbox`, `radio`:
+			if !c.Checked {
+				continue
+			}
+		}
+		if c.Type == `image` {
+			name := c.Name
+			if name != `` {
+				name += `.`
+			}
+			entries = append(entries, formEntry{name: name + `x`, value: `0`}, formEntry{name: name + `y`, value: `0`})
+			continue
+		}
+		if c.Name == `` {
+			continue
+		}
+		switch c.Type {
+		case `select-one`, `select-multiple`:
+			for _, o := range c.Options {
+				if o.Selected && !o.Disabled {
+					entries = append(entries, formEntry{name: c.Name, value: o.Value})
+				}
+			}
+		case `file`:
+			entries = append(entries, formEntry{name: c.Name, file: true})
+		case `hidden`:
+			value := c.Value
+			if value == `` && c.Name == `_charset_` {
+				value = `UTF-8`
+			}
+			entries = append(entries, formEntry{name: c.Name, value: value})
+		default:
+			entries = append(entries, formEntry{name: c.Name, value: c.Value})
+		}
+	}
+	for i := range entries {
+		entries[i].name = formNewlines(entries[i].name)
+		entries[i].value = formNewlines(entries[i].value)
+	}
+	return entries
+}
+
+// formNewlines normalises line breaks to CRLF
+func formNewlines(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// Values returns the values that would be submitted by the form, with the given submitter, which may be nil, or must
+// be a submit button (including image buttons) from `Controls`, note that the order of values is retained only for
+// each name, and that file inputs submit an empty value
+func (f *Form) Values(submitter *FormControl) url.Values {
+	values := make(url.Values)
+	for _, entry := range f.entries(submitter) {
+		values.Add(entry.name, entry.value)
+	}
+	return values
+}
+
+// Encode returns the form submission encoded as application/x-www-form-urlencoded, retaining the order of the
+// controls, see the `Values` method
+func (f *Form) Encode(submitter *FormControl) string {
+	var b strings.Builder
+	for _, entry := range f.entries(submitter) {
+		if b.Len() != 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(entry.name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(entry.value))
+	}
+	return b.String()
+}
+
+// WriteMultipart writes the form submission to w, encoded as multipart/form-data, returning the content type
+// (including the boundary), see the `Values` method, where file inputs are encoded as an empty file with no name
+func (f *Form) WriteMultipart(w io.Writer, submitter *FormControl) (string, error) {
+	writer := multipart.NewWriter(w)
+	for _, entry := range f.entries(submitter) {
+		if entry.file {
+			header := make(textproto.MIMEHeader)
+			header.Set(`Content-Disposition`, fmt.Sprintf(`form-data; name="%s"; filename=""`, formQuote(entry.name)))
+			header.Set(`Content-Type`, `application/octet-stream`)
+			if _, err := writer.CreatePart(header); err != nil {
+				return ``, err
+			}
+			continue
+		}
+		if err := writer.WriteField(entry.name, entry.value); err != nil {
+			return ``, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return ``, err
+	}
+	return writer.FormDataContentType(), nil
+}
+
+func formQuote(s string) string {
+	return strings.NewReplacer("\n", `%0A`, "\r", `%0D`, `"`, `%22`).Replace(s)
+}
+
+// Request builds a http request for the form submission, with the given submitter (see the `Values` method), using
+// the method, action, and encoding type (see the methods of the same names), where GET requests replace the query of
+// the action url
+func (f *Form) Request(base *url.URL, submitter *FormControl) (*http.Request, error) {
+	action, err := f.Action(base, submitter)
+	if err != nil {
+		return nil, err
+	}
+
+	method := f.Method(submitter)
+	if method == http.MethodGet {
+		action.RawQuery = f.Encode(submitter)
+		action.ForceQuery = false
+		return http.NewRequest(method, action.String(), nil)
+	}
+
+	var (
+		body        bytes.Buffer
+		contentType = f.EncType(submitter)
+	)
+	switch contentType {
+	case `multipart/form-data`:
+		if contentType, err = f.WriteMultipart(&body, submitter); err != nil {
+			return nil, err
+		}
+	case `text/plain`:
+		for _, entry := range f.entries(submitter) {
+			body.WriteString(entry.name)
+			body.WriteByte('=')
+			body.WriteString(entry.value)
+			body.WriteString("\r\n")
+		}
+		contentType += `; charset=utf-8`
+	default:
+		body.WriteString(f.Encode(submitter))
+	}
+
+	req, err := http.NewRequest(method, action.String(), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(`Content-Type`, contentType)
+	return req, nil
+}
@@ -0,0 +1,239 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"bytes"
+	"github.com/go-test/deep"
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const formInput = `<input form="login" name="outside" value="o">
+<form id="login" action="/session?x=1" method="post">
+	<input type="hidden" name="csrf" value="token">
+	<input name="user" value="alice">
+	<input type="PASSWORD" name="pass">
+	<input type="checkbox" name="remember" checked>
+	<input type="checkbox" name="tos" value="yes">
+	<input type="radio" name="plan" value="free" checked>
+	<input type="radio" name="plan" value="pro">
+	<input type="unknown" name="other" value="x" disabled>
+	<input type="file" name="avatar">
+	<input type="hidden" name="_charset_">
+	<select name="country"><option disabled>Pick</option><option value="au">Australia</option><option>New   Zealand</option></select>
+	<select name="tags" multiple><option selected>a</option><optgroup disabled><option selected>b</option></optgroup><option selected value="c">C</option></select>
+	<textarea name="bio">line one
+line two</textarea>
+	<fieldset disabled><legend><input name="legend" value="l"></legend><input name="fieldset" value="f"></fieldset>
+	<input type="reset" name="reset">
+	<button name="action" value="login">Log in</button>
+	<button name="action" value="register" formaction="register" formmethod="get">Register</button>
+	<input type="image" name="img">
+	<input form="other" name="elsewhere">
+</form>
+<form id="other"><input name="nope"></form>
+<textarea form="login" name="after">a` + "\r" + `b</textarea>`
+
+func TestNewForm(t *testing.T) {
+	form, err := NewForm(parse(formInput, ID(`login`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, types []string
+	for _, c := range form.Controls {
+		names = append(names, c.Name)
+		types = append(types, c.Type)
+	}
+	if diff := deep.Equal(names, []string{`outside`, `csrf`, `user`, `pass`, `remember`, `tos`, `plan`, `plan`, `other`, `avatar`, `_charset_`, `country`, `tags`, `bio`, `legend`, `fieldset`, `reset`, `action`, `action`, `img`, `after`}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(types, []string{`text`, `hidden`, `text`, `password`, `checkbox`, `checkbox`, `radio`, `radio`, `text`, `file`, `hidden`, `select-one`, `select-multiple`, `textarea`, `text`, `text`, `reset`, `submit`, `submit`, `image`, `textarea`}); diff != nil {
+		t.Error(diff)
+	}
+	if c := form.Control(`remember`); c == nil || c.Value != `on` || !c.Checked {
+		t.Error(c)
+	}
+	if c := form.Control(`missing`); c != nil {
+		t.Error(c)
+	}
+
+	expected := `outside=o&csrf=token&user=alice&pass=&remember=on&plan=free&avatar=&_charset_=UTF-8&country=au&tags=a&tags=c&bio=line+one%0D%0Aline+two&legend=l&after=a%0D%0Ab`
+	if v := form.Encode(nil); v != expected {
+		t.Error(v)
+	}
+	if diff := deep.Equal(form.Values(nil), url.Values{
+		`outside`:   {`o`},
+		`csrf`:      {`token`},
+		`user`:      {`alice`},
+		`pass`:      {``},
+		`remember`:  {`on`},
+		`plan`:      {`free`},
+		`avatar`:    {``},
+		`_charset_`: {`UTF-8`},
+		`country`:   {`au`},
+		`tags`:      {`a`, `c`},
+		`bio`:       {"line one\r\nline two"},
+		`legend`:    {`l`},
+		`after`:     {"a\r\nb"},
+	}); diff != nil {
+		t.Error(diff)
+	}
+
+	if v := form.Encode(form.Controls[19]); !strings.HasSuffix(v, `&img.x=0&img.y=0&after=a%0D%0Ab`) {
+		t.Error(v)
+	}
+}
+
+func TestForm_Set(t *testing.T) {
+	form, err := NewForm(parse(formInput, ID(`login`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range map[string][]string{
+		`user`:     {`bob`},
+		`pass`:     {`secret`},
+		`remember`: nil,
+		`tos`:      {`yes`},
+		`plan`:     {`pro`},
+		`country`:  {`New Zealand`},
+		`tags`:     {`c`, `b`},
+	} {
+		if err := form.Set(name, values...); err != nil {
+			t.Error(name, err)
+		}
+	}
+	if v := form.Encode(nil); v != `outside=o&csrf=token&user=bob&pass=secret&tos=yes&plan=pro&avatar=&_charset_=UTF-8&country=New+Zealand&tags=c&bio=line+one%0D%0Aline+two&legend=l&after=a%0D%0Ab` {
+		t.Error(v)
+	}
+	for _, testCase := range []struct {
+		Name   string
+		Values []string
+		Err    string
+	}{
+		{`missing`, nil, `htmlutil.Form.Set "missing": no such control`},
+		{`reset`, nil, `htmlutil.Form.Set "reset": no such control`},
+		{`plan`, []string{`free`, `pro`}, `htmlutil.Form.Set "plan": value "pro" could not be set`},
+		{`country`, []string{`nz`}, `htmlutil.Form.Set "country": value "nz" could not be set`},
+		{`user`, []string{`a`, `b`}, `htmlutil.Form.Set "user": value "b" could not be set`},
+	} {
+		if err := form.Set(testCase.Name, testCase.Values...); err == nil || err.Error() != testCase.Err {
+			t.Error(testCase.Name, err)
+		}
+	}
+}
+
+func TestForm_Request(t *testing.T) {
+	form, err := NewForm(parse(formInput, ID(`login`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse(`https://example.com/login/page?y=2`)
+
+	req, err := form.Request(base, form.Control(`action`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != `POST` || req.URL.String() != `https://example.com/session?x=1` || req.Header.Get(`Content-Type`) != `application/x-www-form-urlencoded` {
+		t.Error(req.Method, req.URL, req.Header)
+	}
+	if b, _ := io.ReadAll(req.Body); !strings.Contains(string(b), `&action=login&`) {
+		t.Error(string(b))
+	}
+
+	req, err = form.Request(base, form.Controls[18])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != `GET` || !strings.HasPrefix(req.URL.String(), `https://example.com/login/register?outside=o&csrf=token&`) || req.Body != nil {
+		t.Error(req.Method, req.URL, req.Body)
+	}
+
+	form.Node.Data.Attr = append(form.Node.Data.Attr, html.Attribute{Key: `enctype`, Val: `multipart/form-data`})
+	req, err = form.Request(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != `/session?x=1` {
+		t.Error(req.URL)
+	}
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get(`Content-Type`))
+	if err != nil || mediaType != `multipart/form-data` {
+		t.Fatal(mediaType, err)
+	}
+	reader := multipart.NewReader(req.Body, params[`boundary`])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(part)
+		parts = append(parts, part.FormName()+`|`+part.FileName()+`|`+part.Header.Get(`Content-Type`)+`|`+string(b))
+	}
+	if diff := deep.Equal(parts[6:9], []string{
+		`avatar||application/octet-stream|`,
+		`_charset_|||UTF-8`,
+		`country|||au`,
+	}); diff != nil {
+		t.Error(diff)
+	}
+	if len(parts) != 14 {
+		t.Error(parts)
+	}
+
+	form.Node.Data.Attr[len(form.Node.Data.Attr)-1].Val = `TEXT/PLAIN`
+	if req, err = form.Request(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(req.Body); !bytes.HasPrefix(b, []byte("outside=o\r\ncsrf=token\r\n")) || req.Header.Get(`Content-Type`) != `text/plain; charset=utf-8` {
+		t.Error(string(b), req.Header)
+	}
+
+	form.Node.Data.Attr[1].Val = `%zz`
+	if _, err := form.Request(base, nil); err == nil || !strings.HasPrefix(err.Error(), `htmlutil.Form.Action parse "%zz"`) {
+		t.Error(err)
+	}
+}
+
+func TestForm_Action(t *testing.T) {
+	form, err := NewForm(parse(`<form></form>`, Tag(`form`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := form.Action(nil, nil); err != nil || u.String() != `` {
+		t.Error(u, err)
+	}
+	base, _ := url.Parse(`https://example.com/a`)
+	if u, err := form.Action(base, nil); err != nil || u.String() != `https://example.com/a` || u == base {
+		t.Error(u, err)
+	}
+	if form.Method(nil) != `GET` || form.EncType(nil) != `application/x-www-form-urlencoded` {
+		t.Error(form.Method(nil), form.EncType(nil))
+	}
+	if form, err := NewForm(parse(`<div></div>`, Tag(`div`))); form != nil || err == nil || err.Error() != `htmlutil.NewForm not a form element` {
+		t.Error(form, err)
+	}
+}
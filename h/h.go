@@ -0,0 +1,241 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package h provides a small DSL for building html trees, using the same types as the rest of htmlutil, e.g.
+//
+//	doc := h.El(`div`, h.Class(`card`),
+//		h.El(`h1`, h.Text(`Title`)),
+//		h.El(`a`, h.Attr(`href`, `/more`), h.Text(`more`)),
+//	)
+//	s := doc.OuterHTML()
+//
+// Each builder returns a detached tree, with a root `Depth` of 0, which may be queried, modified (see the mutation
+// methods of `htmlutil.Node`), and rendered like any parsed tree. Tag names and attribute keys are used as is, and
+// should be lower case for html elements, to match the behavior of the parser. Like the parser, svg and math elements
+// are in the svg and math namespaces, as are their descendants (e.g. `h.El("svg", h.El("linearGradient",
+// h.Attr("gradientUnits", "userSpaceOnUse")))`), other than the content of svg foreignObject, desc and title
+// elements, which is html.
+package h
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+type (
+	// Child is an argument to El (or Doc), i.e. an attribute, or child node(s), implemented by `Element`, as well as
+	// the results of functions like Attr and Text
+	Child interface {
+		apply(parent *html.Node)
+	}
+
+	// Element is a built node, see El and Doc, which may be used as a `htmlutil.Node` (or as a Child, which will move
+	// it, removing it from any existing parent)
+	Element struct {
+		htmlutil.Node
+	}
+
+	childFunc func(parent *html.Node)
+)
+
+func (e Element) apply(parent *html.Node) {
+	if e.Data != nil && parent.Type == html.ElementNode && parent.Namespace != `` && !integrationPoint(parent) {
+		foreign(e.Data, parent.Namespace)
+	}
+	htmlutil.Node{Data: parent}.AppendChild(e.Node)
+}
+
+// integrationPoint returns true if node is a foreign element with html content
+func integrationPoint(node *html.Node) bool {
+	if node.Namespace == `svg` {
+		switch node.Data {
+		case `foreignObject`, `desc`, `title`:
+			return true
+		}
+	}
+	return false
+}
+
+// foreign moves node (and its descendants, other than the content of integration points) into the namespace, if
+// they are html elements
+func foreign(node *html.Node, namespace string) {
+	if node.Type != html.ElementNode || node.Namespace != `` {
+		return
+	}
+	node.Namespace = namespace
+	if integrationPoint(node) {
+		return
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		foreign(child, namespace)
+	}
+}
+
+func (f childFunc) apply(parent *html.Node) {
+	f(parent)
+}
+
+// El builds an element, applying each child in order, where nil children are ignored, note that svg and math elements
+// are in the corresponding namespace, as are their descendants
+func El(tag string, children ...Child) Element {
+	node := &html.Node{
+		Type:     html.ElementNode,
+		DataAtom: atom.Lookup([]byte(tag)),
+		Data:     tag,
+	}
+	switch tag {
+	case `svg`, `math`:
+		node.Namespace = tag
+	}
+	return build(node, children)
+}
+
+// Doc builds a document, starting with a html doctype, applying each child in order, where attributes are ignored
+func Doc(children ...Child) Element {
+	node := &html.Node{Type: html.DocumentNode}
+	node.AppendChild(&html.Node{Type: html.DoctypeNode, Data: `html`})
+	return build(node, children)
+}
+
+func build(node *html.Node, children []Child) Element {
+	for _, child := range children {
+		if child != nil {
+			child.apply(node)
+		}
+	}
+	return Element{Node: htmlutil.Node{Data: node}}
+}
+
+// Attr sets an attribute, replacing the value of any existing attribute with the same key, where the key is used as
+// is (case sensitive, unlike `htmlutil.Node.SetAttr`), to support camel case (foreign) attributes, such as the svg
+// viewBox attribute
+func Attr(key string, val string) Child {
+	return childFunc(func(parent *html.Node) {
+		if parent.Type != html.ElementNode {
+			return
+		}
+		for i, attr := range parent.Attr {
+			if attr.Namespace == `` && attr.Key == key {
+				parent.Attr[i].Val = val
+				return
+			}
+		}
+		parent.Attr = append(parent.Attr, html.Attribute{Key: key, Val: val})
+	})
+}
+
+// Bool sets a boolean attribute (with an empty value), e.g. `disabled` or `checked`
+func Bool(key string) Child {
+	return Attr(key, ``)
+}
+
+// ID sets the id attribute
+func ID(id string) Child {
+	return Attr(`id`, id)
+}
+
+// Class adds one or more classes (which may also be space separated), see `htmlutil.Node.AddClass`
+func Class(classes ...string) Child {
+	return childFunc(func(parent *html.Node) {
+		htmlutil.Node{Data: parent}.AddClass(classes...)
+	})
+}
+
+// Style adds declarations to the style attribute, where each pair of arguments is a property and a value, e.g.
+// `Style("color", "red", "margin", "0")`, note that a trailing property without a value is ignored
+func Style(pairs ...string) Child {
+	return childFunc(func(parent *html.Node) {
+		node := htmlutil.Node{Data: parent}
+		var declarations []string
+		if v := strings.TrimRight(strings.TrimSpace(node.GetAttrVal(``, `style`)), `;`); v != `` {
+			declarations = append(declarations, v)
+		}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			declarations = append(declarations, pairs[i]+`: `+pairs[i+1])
+		}
+		if len(declarations) != 0 {
+			node.SetAttr(``, `style`, strings.Join(declarations, `; `))
+		}
+	})
+}
+
+// Text adds a text node, which will be escaped when rendered, where empty text is ignored
+func Text(text string) Child {
+	return childFunc(func(parent *html.Node) {
+		if text != `` {
+			parent.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+		}
+	})
+}
+
+// Comment adds a comment node
+func Comment(text string) Child {
+	return childFunc(func(parent *html.Node) {
+		parent.AppendChild(&html.Node{Type: html.CommentNode, Data: text})
+	})
+}
+
+// Raw adds the nodes parsed from a html fragment (in the context of the parent, or a body element, if the parent is
+// not an element), which is useful for trusted content, e.g. from a template, note that it is NOT escaped
+func Raw(fragment string) Child {
+	return childFunc(func(parent *html.Node) {
+		context := parent
+		if context.Type != html.ElementNode {
+			context = &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: `body`}
+		}
+		// the reader can't fail, and the parser is error tolerant
+		nodes, _ := html.ParseFragment(strings.NewReader(fragment), context)
+		for _, node := range nodes {
+			parent.AppendChild(node)
+		}
+	})
+}
+
+// Node adds an existing node, moving it (removing it from any existing parent), use `htmlutil.Node.Clone` to add a
+// copy instead, note that empty nodes are ignored
+func Node(node htmlutil.Node) Child {
+	return Element{Node: node}
+}
+
+// Group combines children, e.g. for a helper returning several attributes or nodes
+func Group(children ...Child) Child {
+	return childFunc(func(parent *html.Node) {
+		for _, child := range children {
+			if child != nil {
+				child.apply(parent)
+			}
+		}
+	})
+}
+
+// If returns children as a Group if cond is true, or nil (which is ignored) otherwise
+func If(cond bool, children ...Child) Child {
+	if !cond {
+		return nil
+	}
+	return Group(children...)
+}
+
+// Each returns a Group of the results of fn for each value
+func Each[T any](values []T, fn func(i int, value T) Child) Child {
+	children := make([]Child, 0, len(values))
+	for i, value := range values {
+		children = append(children, fn(i, value))
+	}
+	return Group(children...)
+}
@@ -0,0 +1,132 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package h
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func parse(s string) htmlutil.Node {
+	node, err := htmlutil.Parse(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+func TestEl(t *testing.T) {
+	for _, testCase := range []struct {
+		Name   string
+		Input  Element
+		Output string
+	}{
+		{
+			Name:   `empty`,
+			Input:  El(`div`),
+			Output: `<div></div>`,
+		},
+		{
+			Name: `nested`,
+			Input: El(`div`, Attr(`class`, `x`), Text(`hi`), El(`a`, Attr(`href`, `/a?b&c`), Text(`<link>`)),
+				nil, El(`br`)),
+			Output: `<div class="x">hi<a href="/a?b&amp;c">&lt;link&gt;</a><br/></div>`,
+		},
+		{
+			Name: `attributes`,
+			Input: El(`input`, ID(`a`), Class(`b c`), Class(`b`, `d`), Attr(`type`, `text`), Attr(`type`, `checkbox`),
+				Bool(`checked`), Style(`color`, `red`), Style(`margin`, `0`, `padding`)),
+			Output: `<input id="a" class="b c d" type="checkbox" checked="" style="color: red; margin: 0"/>`,
+		},
+		{
+			Name: `conditional`,
+			Input: El(`ul`, Each([]string{`a`, `b`}, func(i int, value string) Child {
+				return El(`li`, If(i == 0, Class(`first`)), Text(value))
+			}), If(false, El(`li`)), Group(Comment(` end `), Text(``))),
+			Output: `<ul><li class="first">a</li><li>b</li><!-- end --></ul>`,
+		},
+		{
+			Name:   `raw`,
+			Input:  El(`table`, Raw(`<tr><td>1</td></tr>`), El(`p`, Raw(`a <b>b</b>`))),
+			Output: `<table><tbody><tr><td>1</td></tr></tbody><p>a <b>b</b></p></table>`,
+		},
+		{
+			Name:   `document`,
+			Input:  Doc(Attr(`lang`, `en`), El(`html`, El(`head`, El(`title`, Text(`T`))), El(`body`, Raw(`<p>x`)))),
+			Output: `<!DOCTYPE html><html><head><title>T</title></head><body><p>x</p></body></html>`,
+		},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if v := testCase.Input.OuterHTML(); v != testCase.Output {
+				t.Errorf("unexpected output:\n%q\n%q", v, testCase.Output)
+			}
+			if testCase.Input.Depth != 0 || testCase.Input.Parent().Data != nil {
+				t.Error(testCase.Input)
+			}
+		})
+	}
+}
+
+func TestEl_foreign(t *testing.T) {
+	el := El(`div`, El(`svg`, Attr(`viewBox`, `0 0 1 1`),
+		El(`g`, El(`linearGradient`, Attr(`gradientUnits`, `userSpaceOnUse`))),
+		El(`foreignObject`, El(`p`, Attr(`Title`, `x`))),
+	), El(`math`, El(`mi`, Text(`x`))))
+	const expected = `<div><svg viewBox="0 0 1 1"><g><linearGradient gradientUnits="userSpaceOnUse"></linearGradient></g>` +
+		`<foreignObject><p Title="x"></p></foreignObject></svg><math><mi>x</mi></math></div>`
+	if v := el.OuterHTML(); v != expected {
+		t.Errorf("unexpected output:\n%q\n%q", v, expected)
+	}
+	for tag, namespace := range map[string]string{
+		`div`:            ``,
+		`svg`:            `svg`,
+		`g`:              `svg`,
+		`linearGradient`: `svg`,
+		`foreignObject`:  `svg`,
+		`p`:              ``,
+		`math`:           `math`,
+		`mi`:             `math`,
+	} {
+		if v := el.GetNode(htmlutil.Tag(tag)); v.Data == nil || v.Data.Namespace != namespace {
+			t.Error(tag, v)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	doc := parse(`<p>a<b>b</b></p>`)
+	b := doc.GetNode(htmlutil.Tag(`b`))
+	el := El(`div`, Node(b.Clone(true)), Node(htmlutil.Node{}))
+	if v := doc.GetNode(htmlutil.Tag(`p`)).OuterHTML(); v != `<p>a<b>b</b></p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	el = El(`div`, el, Node(b))
+	if v := el.OuterHTML(); v != `<div><div><b>b</b></div><b>b</b></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := doc.GetNode(htmlutil.Tag(`p`)).OuterHTML(); v != `<p>a</p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := len(el.FilterNodes(htmlutil.Tag(`b`))); v != 2 {
+		t.Error(v)
+	}
+	if v := el.GetNode(htmlutil.Tag(`div`), htmlutil.Tag(`div`)); v.Depth != 1 || v.Data.DataAtom == 0 || v.Type() != html.ElementNode {
+		t.Error(v)
+	}
+}
@@ -45,11 +45,44 @@ package htmlutil
 
 import (
 	"errors"
+	"fmt"
 	"golang.org/x/net/html"
 	"io"
 	"strings"
 )
 
+// ErrNoMatch is returned (wrapped) by functions such as `Parse`, if no node matched the filters, and may be tested
+// for using `errors.Is`
+var ErrNoMatch = errors.New("no match")
+
+// ErrLimitExceeded is returned (wrapped) by `ParseWithOptions`, if any of the configured maximums were exceeded, and
+// may be tested for using `errors.Is`
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// ParseOptions configures `ParseWithOptions`, where the zero value behaves like `Parse`, with the exception of
+// character encoding detection, which is enabled by default
+type ParseOptions struct {
+	// DisableScripting is passed through (negated) as `html.ParseOptionEnableScripting`, which affects the parsing of
+	// `noscript` elements
+	DisableScripting bool
+	// ContentType is an optional Content-Type header value, which may specify the character encoding
+	ContentType string
+	// Charset is an optional character encoding label (e.g. "shift_jis"), which disables sniffing
+	Charset string
+	// DisableCharsetSniffing will treat the input as UTF-8 (ignored if Charset is set)
+	DisableCharsetSniffing bool
+	// MaxBytes is the maximum number of bytes that will be read from the input, if greater than zero, and is the only
+	// option that limits the resources used while parsing
+	MaxBytes int64
+	// MaxNodes is the maximum number of nodes (including the document node) in the parsed tree, if greater than zero,
+	// note that this is checked after the entire tree has been built, and so it is not a resource guard (see MaxBytes)
+	MaxNodes int
+	// MaxDepth is the maximum depth of any node (the document node having a depth of 0) in the parsed tree, if greater
+	// than zero, note that this is checked after the entire tree has been built, and so it is not a resource guard
+	// (see MaxBytes)
+	MaxDepth int
+}
+
 // Node is the data structure this package provides to allow utilisation of utility methods + extra metadata such
 // as the last match (`Match` property) for filter / find / get calls, as well as the overall (relative) depth,
 // allowing matching on things such as "all the table row elements that are direct children of a given tbody", a-la
@@ -64,17 +97,66 @@ type Node struct {
 }
 
 // Parse first performs html.Parse, parsing through any errors, before applying a find to the resulting Node (wrapped
-// like `Node{Data: node}`), returning the first matching Node, or an error, if no matches were found
+// like `Node{Data: node}`), returning the first matching Node, or an error, if no matches were found (see
+// `ErrNoMatch`)
 func Parse(r io.Reader, filters ...func(node Node) bool) (Node, error) {
 	if node, err := html.Parse(r); err != nil {
 		return Node{}, err
 	} else if node, ok := findNode(Node{Data: node}, filters...); !ok {
-		return Node{}, errors.New("htmlutil.Parse no match")
+		return Node{}, fmt.Errorf("htmlutil.Parse %w", ErrNoMatch)
+	} else {
+		return node, nil
+	}
+}
+
+// ParseAll is like Parse, except it returns all matching nodes (see the `FilterNodes` method), note that no matches
+// is not an error
+func ParseAll(r io.Reader, filters ...func(node Node) bool) ([]Node, error) {
+	node, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return filterNodes(Node{Data: node}, filters...), nil
+}
+
+// ParseWithOptions is like Parse, but with additional options (see `ParseOptions`), by default sniffing the character
+// encoding (using the BOM, the Content-Type, then any `<meta>` tags in the first 1024 bytes, as per
+// `charset.DetermineEncoding`) and decoding to UTF-8 first, where input without a declared encoding is treated as
+// UTF-8, note that the MaxNodes and MaxDepth limits are checked after parsing (the full tree is still built, so only
+// MaxBytes bounds memory use), prior to any filters
+func ParseWithOptions(r io.Reader, opts ParseOptions, filters ...func(node Node) bool) (Node, error) {
+	if node, err := parseWithOptions(r, opts); err != nil {
+		return Node{}, err
+	} else if node, ok := findNode(node, filters...); !ok {
+		return Node{}, fmt.Errorf("htmlutil.ParseWithOptions %w", ErrNoMatch)
+	} else {
+		return node, nil
+	}
+}
+
+// ParseFragment first performs html.ParseFragment, using the context node (which may be empty, behaving like a body
+// element), before applying a find to a synthetic root node (a `html.DocumentNode` with a depth of 0) containing the
+// fragment nodes as children, returning the first matching Node, or an error, if no matches were found
+func ParseFragment(r io.Reader, context Node, filters ...func(node Node) bool) (Node, error) {
+	if node, err := parseFragment(r, context); err != nil {
+		return Node{}, err
+	} else if node, ok := findNode(node, filters...); !ok {
+		return Node{}, fmt.Errorf("htmlutil.ParseFragment %w", ErrNoMatch)
 	} else {
 		return node, nil
 	}
 }
 
+// ParseFragmentAll is like ParseFragment, except it returns all matching nodes (see the `FilterNodes` method), note
+// that no matches is not an error
+func ParseFragmentAll(r io.Reader, context Node, filters ...func(node Node) bool) ([]Node, error) {
+	node, err := parseFragment(r, context)
+	if err != nil {
+		return nil, err
+	}
+	return filterNodes(node, filters...), nil
+}
+
 // Attr will return the value of `n.Data.Attr`, returning nil if `n.Data` is nil
 func (n Node) Attr() []html.Attribute {
 	if n.Data == nil {
@@ -18,6 +18,7 @@ package htmlutil
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/go-test/deep"
 	"golang.org/x/net/html"
@@ -378,6 +379,238 @@ func TestParse_notFound(t *testing.T) {
 	if err == nil || err.Error() != "htmlutil.Parse no match" {
 		t.Fatal(err)
 	}
+	if !errors.Is(err, ErrNoMatch) {
+		t.Error(err)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	nodes, err := ParseAll(strings.NewReader(`<p>1</p><div><p>2</p></div>`), Tag(`p`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(
+		outerHTMLs(nodes),
+		[]string{`<p>1</p>`, `<p>2</p>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"output diff:"}, diff...), "    \n"))
+	}
+	if v := nodes[1].Depth; v != 4 {
+		t.Error(v)
+	}
+	if nodes, err := ParseAll(strings.NewReader(`<p>1</p>`), Tag(`a`)); err != nil || nodes != nil {
+		t.Error(nodes, err)
+	}
+}
+
+func TestParseAll_eof(t *testing.T) {
+	reader, _ := io.Pipe()
+	_ = reader.Close()
+	if _, err := ParseAll(reader); err == nil || err.Error() != "io: read/write on closed pipe" {
+		t.Fatal(err)
+	}
+}
+
+func TestParseWithOptions_charset(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opts   ParseOptions
+		Output string
+	}
+	testCases := []TestCase{
+		{
+			Input:  "<meta charset=\"shift_jis\"><p>\x93\xfa\x96\x7b</p>",
+			Output: "日本",
+		},
+		{
+			Input:  "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-1\"><p>caf\xe9</p>",
+			Output: "café",
+		},
+		{
+			Input:  "<meta charset=\"iso-8859-1\"><p>caf\xe9</p>",
+			Opts:   ParseOptions{ContentType: "text/html; charset=shift_jis"},
+			Output: "caf\ufffd",
+		},
+		{
+			Input:  "\xef\xbb\xbf<meta charset=\"iso-8859-1\"><p>caf\xc3\xa9</p>",
+			Output: "café",
+		},
+		{
+			Input:  "<p>caf\xc3\xa9</p>",
+			Output: "café",
+		},
+		{
+			Input:  "<p>\x93\xfa\x96\x7b</p>",
+			Opts:   ParseOptions{Charset: "sjis"},
+			Output: "日本",
+		},
+		{
+			Input:  "<meta charset=\"iso-8859-1\"><p>caf\xc3\xa9</p>",
+			Opts:   ParseOptions{DisableCharsetSniffing: true},
+			Output: "café",
+		},
+		{
+			// no declared encoding, with only ASCII in the first 1024 bytes
+			Input:  "<!--" + strings.Repeat(" ", 1100) + "--><p>caf\xc3\xa9 \xe2\x80\x93 na\xc3\xafve</p>",
+			Output: "café – naïve",
+		},
+		{
+			Input:  "<meta charset=\"windows-1252\"><!--" + strings.Repeat(" ", 1100) + "--><p>caf\xe9 \x96 na\xefve</p>",
+			Output: "café – naïve",
+		},
+		{
+			// invalid UTF-8, without a declared encoding, is left as is
+			Input:  "<p>caf\xe9</p>",
+			Output: "caf\xe9",
+		},
+	}
+	for i, testCase := range testCases {
+		node, err := ParseWithOptions(strings.NewReader(testCase.Input), testCase.Opts, Tag(`p`))
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if v := node.OuterText(); v != testCase.Output {
+			t.Errorf("%d %q", i, v)
+		}
+	}
+}
+
+func TestParseWithOptions_scripting(t *testing.T) {
+	const input = `<head><noscript><link rel="x"></noscript></head>`
+	if v := parse(input, Tag(`noscript`)).InnerHTML(); v != `&lt;link rel=&#34;x&#34;&gt;` {
+		t.Error(v)
+	}
+	node, err := ParseWithOptions(strings.NewReader(input), ParseOptions{DisableScripting: true}, Tag(`noscript`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := node.InnerHTML(); v != `<link rel="x"/>` {
+		t.Error(v)
+	}
+}
+
+func TestParseWithOptions_limits(t *testing.T) {
+	const input = `<div><div><div>text</div></div></div>`
+	type TestCase struct {
+		Input string
+		Opts  ParseOptions
+		Error string
+	}
+	testCases := []TestCase{
+		{Input: input, Opts: ParseOptions{MaxBytes: int64(len(input)), MaxNodes: 8, MaxDepth: 6}},
+		{Input: input, Opts: ParseOptions{MaxBytes: int64(len(input)) - 1}, Error: `htmlutil.ParseWithOptions max bytes limit exceeded`},
+		{Input: strings.Repeat(input, 100), Opts: ParseOptions{MaxBytes: 1500}, Error: `htmlutil.ParseWithOptions max bytes limit exceeded`},
+		{Input: input, Opts: ParseOptions{MaxBytes: 10, Charset: `utf-8`}, Error: `htmlutil.ParseWithOptions max bytes limit exceeded`},
+		{Input: input, Opts: ParseOptions{MaxNodes: 7}, Error: `htmlutil.ParseWithOptions max nodes limit exceeded`},
+		{Input: input, Opts: ParseOptions{MaxDepth: 5}, Error: `htmlutil.ParseWithOptions max depth limit exceeded`},
+		{Input: input, Opts: ParseOptions{Charset: `nope`}, Error: `unsupported charset: "nope"`},
+	}
+	for i, testCase := range testCases {
+		_, err := ParseWithOptions(strings.NewReader(testCase.Input), testCase.Opts)
+		if testCase.Error == `` {
+			if err != nil {
+				t.Error(i, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != testCase.Error {
+			t.Error(i, err)
+		} else if strings.Contains(testCase.Error, `limit exceeded`) && !errors.Is(err, ErrLimitExceeded) {
+			t.Error(i, err)
+		}
+	}
+}
+
+func TestParseWithOptions_errors(t *testing.T) {
+	if _, err := ParseWithOptions(strings.NewReader(`<p>`), ParseOptions{}, Tag(`a`)); err == nil || err.Error() != `htmlutil.ParseWithOptions no match` || !errors.Is(err, ErrNoMatch) {
+		t.Error(err)
+	}
+	reader, _ := io.Pipe()
+	_ = reader.Close()
+	if _, err := ParseWithOptions(reader, ParseOptions{}); err == nil || err.Error() != "io: read/write on closed pipe" {
+		t.Error(err)
+	}
+	reader, _ = io.Pipe()
+	_ = reader.Close()
+	if _, err := ParseWithOptions(reader, ParseOptions{DisableCharsetSniffing: true}); err == nil || err.Error() != "io: read/write on closed pipe" {
+		t.Error(err)
+	}
+	if node, err := ParseWithOptions(strings.NewReader(``), ParseOptions{}); err != nil || node.OuterHTML() != `<html><head></head><body></body></html>` {
+		t.Error(node, err)
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	tbody := parse(`<table><tbody></tbody></table>`, Tag(`tbody`))
+	node, err := ParseFragment(strings.NewReader(`<tr><td>x</td></tr><tr><td>y</td></tr>`), tbody, Tag(`td`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := node.OuterHTML(); v != `<td>x</td>` {
+		t.Error(v)
+	}
+	if v := node.Depth; v != 2 {
+		t.Error(v)
+	}
+	root := node.Parent().Parent()
+	if v := root.Type(); v != html.DocumentNode || root.Depth != 0 {
+		t.Error(v, root.Depth)
+	}
+	if diff := deep.Equal(
+		outerHTMLs(root.Children()),
+		[]string{`<tr><td>x</td></tr>`, `<tr><td>y</td></tr>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"children diff:"}, diff...), "    \n"))
+	}
+	if v := root.OuterHTML(); v != `<tr><td>x</td></tr><tr><td>y</td></tr>` {
+		t.Error(v)
+	}
+}
+
+func TestParseFragment_noContext(t *testing.T) {
+	node, err := ParseFragment(strings.NewReader(`<tr><td>x</td></tr>`), Node{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := node.OuterHTML(); v != `x` {
+		t.Error(v)
+	}
+}
+
+func TestParseFragment_errors(t *testing.T) {
+	if _, err := ParseFragment(strings.NewReader(`<p>`), Node{}, Tag(`a`)); err == nil || err.Error() != "htmlutil.ParseFragment no match" || !errors.Is(err, ErrNoMatch) {
+		t.Error(err)
+	}
+	if _, err := ParseFragment(strings.NewReader(`<p>`), Node{Data: &html.Node{Type: html.TextNode}}); err == nil {
+		t.Error(err)
+	}
+	if _, err := ParseFragmentAll(strings.NewReader(`<p>`), Node{Data: &html.Node{Type: html.TextNode}}); err == nil {
+		t.Error(err)
+	}
+}
+
+func TestParseFragmentAll(t *testing.T) {
+	nodes, err := ParseFragmentAll(strings.NewReader(`<li>1</li><li>2<ul><li>3</li></ul></li>`), parse(`<ul></ul>`, Tag(`ul`)), Tag(`li`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type Result struct {
+		Text  string
+		Depth int
+	}
+	var results []Result
+	for _, node := range nodes {
+		results = append(results, Result{node.OuterText(), node.Depth})
+	}
+	if diff := deep.Equal(
+		results,
+		[]Result{{`1`, 1}, {`23`, 1}, {`3`, 3}},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"results diff:"}, diff...), "    \n"))
+	}
+	if nodes, err := ParseFragmentAll(strings.NewReader(`<li>1</li>`), Node{}, Tag(`a`)); err != nil || nodes != nil {
+		t.Error(nodes, err)
+	}
 }
 
 func TestGetNode_nil(t *testing.T) {
@@ -17,8 +17,17 @@
 package htmlutil
 
 import (
+	"bufio"
 	"bytes"
+	"fmt"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+	"io"
+	"iter"
+	"mime"
 	"strings"
 )
 
@@ -51,83 +60,148 @@ func (c filterConfig) match() *Node {
 }
 
 func (c filterConfig) filter() []Node {
-	c.Filters = c.filters()
+	var result []Node
+	for node := range c.seq() {
+		result = append(result, node)
+		if c.Find {
+			break
+		}
+	}
+	return result
+}
 
-	c.Node.Match = c.match()
+// seq implements the filter algorithm lazily, yielding results in the same order as they would be returned by
+// filter, where duplicates (for the same `*html.Node`) are squashed by retaining the first instance
+func (c filterConfig) seq() iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		c.Filters = c.filters()
 
-	var (
-		result []Node
-		fn     func(c filterConfig)
-	)
+		c.Node.Match = c.match()
 
-	fn = func(c filterConfig) {
-		if c.Node.Data == nil {
-			return
-		}
+		var (
+			// seen is every result yielded so far, since later results may duplicate earlier ones (a match for a
+			// node via an ancestor consuming a filter, vs the same node via the search continuing past that ancestor)
+			seen map[*html.Node]struct{}
+			fn   func(c filterConfig) bool
+		)
 
-		if c.Find && len(result) != 0 {
-			return
-		}
+		fn = func(c filterConfig) bool {
+			if c.Node.Data == nil {
+				return true
+			}
 
-		if len(c.Filters) == 0 {
-			result = append(result, c.Node)
-			return
-		}
+			if len(c.Filters) == 0 {
+				if _, ok := seen[c.Node.Data]; ok {
+					return true
+				}
+				if !yield(c.Node) {
+					return false
+				}
+				if seen == nil {
+					seen = make(map[*html.Node]struct{})
+				}
+				seen[c.Node.Data] = struct{}{}
+				return true
+			}
 
-		start := len(result)
+			if !func(c filterConfig) bool {
+				var filter func(node Node) bool
 
-		func(c filterConfig) {
-			var filter func(node Node) bool
+				for filter == nil && len(c.Filters) != 0 {
+					filter = c.Filters[0]
+					c.Filters = c.Filters[1:]
+				}
 
-			for filter == nil && len(c.Filters) != 0 {
-				filter = c.Filters[0]
-				c.Filters = c.Filters[1:]
-			}
+				if filter != nil && !filter(c.Node) {
+					return true
+				}
 
-			if filter != nil && !filter(c.Node) {
-				return
-			}
+				if len(c.Filters) == 0 {
+					return fn(c)
+				}
 
-			if len(c.Filters) == 0 {
-				fn(c)
+				c.Node.Match = c.match()
 
-				return
-			}
+				for n := c.Node.FirstChild(); n.Data != nil; n = n.NextSibling() {
+					c.Node = n
+
+					if !fn(c) {
+						return false
+					}
+				}
 
-			c.Node.Match = c.match()
+				return true
+			}(c) {
+				return false
+			}
 
 			for n := c.Node.FirstChild(); n.Data != nil; n = n.NextSibling() {
 				c.Node = n
 
-				fn(c)
+				if !fn(c) {
+					return false
+				}
 			}
-		}(c)
 
-		finish := len(result)
+			return true
+		}
 
-		for n := c.Node.FirstChild(); n.Data != nil; n = n.NextSibling() {
-			c.Node = n
+		fn(c)
+	}
+}
 
-			fn(c)
+func filterChains(chains [][]func(node Node) bool) [][]func(node Node) bool {
+	var result [][]func(node Node) bool
+	for _, chain := range chains {
+		if chain = (filterConfig{Filters: chain}).filters(); len(chain) != 0 {
+			result = append(result, chain)
+		}
+	}
+	return result
+}
 
-			for i := start; i < finish; i++ {
-				for j := finish; j < len(result); j++ {
-					if result[i].Data != result[j].Data {
-						continue
-					}
+// matchChain returns true if the (non-empty, nil-stripped) filters would match node, if they were applied from the
+// last match (inclusive), or the node itself if there is no last match, note that only the nodes on the path between
+// the last match and the node are considered
+func matchChain(node Node, filters []func(node Node) bool) bool {
+	if node.Data == nil {
+		return false
+	}
 
-					copy(result[j:], result[j+1:])
-					result[len(result)-1] = Node{}
-					result = result[:len(result)-1]
-					j--
+	// the path from the last match (the root of the search) to the node
+	path := []Node{node}
+	if node.Match != nil {
+		for parent := node.Parent(); parent.Data != nil && parent.Depth >= node.Match.Depth; parent = parent.Parent() {
+			path = append(path, parent)
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	var fn func(path []Node, filters []func(node Node) bool, match *Node) bool
+
+	fn = func(path []Node, filters []func(node Node) bool, match *Node) bool {
+		for i := range path {
+			c := filterConfig{Node: path[i]}
+			c.Node.Match = match
+			if !filters[0](c.Node) {
+				continue
+			}
+			if len(filters) == 1 {
+				if i == len(path)-1 {
+					return true
 				}
+				continue
+			}
+			if fn(path[i+1:], filters[1:], c.match()) {
+				return true
 			}
 		}
+		return false
 	}
 
-	fn(c)
-
-	return result
+	return fn(path, filters, (filterConfig{Node: path[0]}).match())
 }
 
 func filterNodes(node Node, filters ...func(node Node) bool) []Node {
@@ -154,6 +228,150 @@ func getNode(node Node, filters ...func(node Node) bool) Node {
 	return result
 }
 
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("htmlutil.ParseWithOptions max bytes %w", ErrLimitExceeded)
+	}
+	// read at most one byte more than the limit, so that it can be detected
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("htmlutil.ParseWithOptions max bytes %w", ErrLimitExceeded)
+	}
+	return n, err
+}
+
+func parseWithOptions(r io.Reader, opts ParseOptions) (Node, error) {
+	if opts.MaxBytes > 0 {
+		r = &limitReader{r: r, n: opts.MaxBytes}
+	}
+
+	if opts.Charset != `` {
+		var err error
+		if r, err = charset.NewReaderLabel(opts.Charset, r); err != nil {
+			return Node{}, err
+		}
+	} else if !opts.DisableCharsetSniffing {
+		reader := bufio.NewReaderSize(r, 1024)
+		preview, err := reader.Peek(1024)
+		if err != nil && err != io.EOF {
+			return Node{}, err
+		}
+		r = reader
+		// the encoding is only used if it was declared (by a BOM, the Content-Type, or a meta tag), since otherwise it
+		// falls back to windows-1252 (unless the preview contains non-ASCII UTF-8), which would corrupt UTF-8 input
+		// that happens to start with only ASCII
+		if e, name, certain := charset.DetermineEncoding(preview, opts.ContentType); e != encoding.Nop &&
+			(certain || name != `windows-1252` || metaCharset(preview)) {
+			r = transform.NewReader(r, e.NewDecoder())
+		}
+	}
+
+	node, err := html.ParseWithOptions(r, html.ParseOptionEnableScripting(!opts.DisableScripting))
+	if err != nil {
+		return Node{}, err
+	}
+
+	// note that these limits are checked after the tree has been built, MaxBytes being the only bound on resources
+
+	if opts.MaxNodes > 0 || opts.MaxDepth > 0 {
+		var (
+			count int
+			fn    func(node *html.Node, depth int) error
+		)
+		fn = func(node *html.Node, depth int) error {
+			count++
+			if opts.MaxNodes > 0 && count > opts.MaxNodes {
+				return fmt.Errorf("htmlutil.ParseWithOptions max nodes %w", ErrLimitExceeded)
+			}
+			if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+				return fmt.Errorf("htmlutil.ParseWithOptions max depth %w", ErrLimitExceeded)
+			}
+			for node := node.FirstChild; node != nil; node = node.NextSibling {
+				if err := fn(node, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := fn(node, 0); err != nil {
+			return Node{}, err
+		}
+	}
+
+	return Node{Data: node}, nil
+}
+
+// metaCharset returns true if the content contains a meta element declaring a (supported) character encoding, using
+// either a charset attribute, or a http-equiv="content-type" with a charset in the content attribute
+func metaCharset(content []byte) bool {
+	z := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != `meta` {
+				continue
+			}
+			var (
+				contentType bool
+				value       string
+			)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case `charset`:
+					if e, _ := charset.Lookup(string(val)); e != nil {
+						return true
+					}
+				case `http-equiv`:
+					contentType = strings.EqualFold(strings.TrimSpace(string(val)), `content-type`)
+				case `content`:
+					value = string(val)
+				}
+			}
+			if contentType {
+				if _, params, err := mime.ParseMediaType(value); err == nil {
+					if e, _ := charset.Lookup(params[`charset`]); e != nil {
+						return true
+					}
+				}
+			}
+		}
+	}
+}
+
+func parseFragment(r io.Reader, context Node) (Node, error) {
+	if context.Data == nil {
+		context.Data = &html.Node{
+			Type:     html.ElementNode,
+			Data:     `body`,
+			DataAtom: atom.Body,
+		}
+	}
+	nodes, err := html.ParseFragment(r, context.Data)
+	if err != nil {
+		return Node{}, err
+	}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, node := range nodes {
+		root.AppendChild(node)
+	}
+	return Node{Data: root}, nil
+}
+
 func encodeHTML(node *html.Node) string {
 	if node == nil {
 		return ""
@@ -233,6 +451,13 @@ func getAttrVal(namespace string, key string, attributes ...html.Attribute) stri
 	return result.Val
 }
 
+func attrFilter(namespace string, key string, match func(v string) bool) func(node Node) bool {
+	return func(node Node) bool {
+		attr, ok := node.GetAttr(namespace, key)
+		return ok && match(attr.Val)
+	}
+}
+
 func siblingIndex(node Node, filters ...func(node Node) bool) (v int) {
 	// results the count of previous siblings matching any filters
 	for node = node.PrevSibling(filters...); node.Data != nil; node = node.PrevSibling(filters...) {
@@ -0,0 +1,92 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the same nodes as `FilterNodes`, in the same order, evaluating the filters lazily, so
+// that breaking early avoids searching the remainder of the sub-tree (see package comment for filter behavior)
+func (n Node) All(filters ...func(node Node) bool) iter.Seq[Node] {
+	return (filterConfig{
+		Node:    n,
+		Filters: filters,
+	}).seq()
+}
+
+// Descendants is like All, except that the receiver itself will never be yielded (it is still treated as the root
+// of the search, and may match filters)
+func (n Node) Descendants(filters ...func(node Node) bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for node := range n.All(filters...) {
+			if node.Data == n.Data {
+				continue
+			}
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// Ancestors returns an iterator over every parent matching any filters, nearest first, as per repeated calls to the
+// `Parent` method
+func (n Node) Ancestors(filters ...func(node Node) bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for node := n.Parent(filters...); node.Data != nil; node = node.Parent(filters...) {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// ChildrenSeq returns an iterator over the same nodes as the `Children` method
+func (n Node) ChildrenSeq(filters ...func(node Node) bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for node := n.FirstChild(filters...); node.Data != nil; node = node.NextSibling(filters...) {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// FollowingSiblings returns an iterator over every next sibling matching any filters, nearest first, as per repeated
+// calls to the `NextSibling` method
+func (n Node) FollowingSiblings(filters ...func(node Node) bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for node := n.NextSibling(filters...); node.Data != nil; node = node.NextSibling(filters...) {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// PrecedingSiblings returns an iterator over every previous sibling matching any filters, nearest first, as per
+// repeated calls to the `PrevSibling` method
+func (n Node) PrecedingSiblings(filters ...func(node Node) bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for node := n.PrevSibling(filters...); node.Data != nil; node = node.PrevSibling(filters...) {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
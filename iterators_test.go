@@ -0,0 +1,167 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"github.com/go-test/deep"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const iteratorsInput = `<div class="one"><img alt="top level"/><div class="one"><img alt="further nested"/></div></div><div class="one"></div><div class="two"><img alt="Some Alt Text"/></div><div class="one"><img alt="final"/></div>`
+
+func seqOuterHTMLs(seq iter.Seq[Node]) []string {
+	return outerHTMLs(slices.Collect(seq))
+}
+
+func TestNode_All(t *testing.T) {
+	root := parse(iteratorsInput)
+	for i, filters := range [][]func(node Node) bool{
+		nil,
+		{nil},
+		{Tag(`div`)},
+		{Class(`one`), Tag(`img`)},
+		{Tag(`div`), Tag(`div`), Tag(`img`)},
+		{Tag(`body`), And(Tag(`div`), DirectChild()), Tag(`img`)},
+		{Tag(`nothing`)},
+	} {
+		expected := root.FilterNodes(filters...)
+		actual := slices.Collect(root.All(filters...))
+		if len(expected) != len(actual) {
+			t.Fatal(i, len(expected), len(actual))
+		}
+		for j := range expected {
+			if expected[j].Data != actual[j].Data || expected[j].Depth != actual[j].Depth || expected[j].Offset() != actual[j].Offset() {
+				t.Error(i, j, expected[j], actual[j])
+			}
+		}
+	}
+}
+
+func TestNode_All_break(t *testing.T) {
+	var (
+		root  = parse(iteratorsInput)
+		count int
+	)
+	for node := range root.All(func(node Node) bool {
+		count++
+		return node.Tag() == `img`
+	}) {
+		if v := node.GetAttrVal(``, `alt`); v != `top level` {
+			t.Error(v)
+		}
+		break
+	}
+	// document, html, head, body, div, img
+	if count != 6 {
+		t.Error(count)
+	}
+	if v := slices.Collect((Node{}).All()); v != nil {
+		t.Error(v)
+	}
+}
+
+func TestNode_Descendants(t *testing.T) {
+	div := parse(iteratorsInput, Class(`one`))
+	if diff := deep.Equal(
+		seqOuterHTMLs(div.Descendants(Class(`one`))),
+		[]string{`<div class="one"><img alt="further nested"/></div>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"descendants diff:"}, diff...), "    \n"))
+	}
+	if diff := deep.Equal(
+		seqOuterHTMLs(div.Descendants(Class(`one`), Tag(`img`))),
+		[]string{`<img alt="top level"/>`, `<img alt="further nested"/>`},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"descendants chain diff:"}, diff...), "    \n"))
+	}
+	for node := range div.Descendants() {
+		if node.Depth != 5 {
+			t.Error(node.Depth)
+		}
+		break
+	}
+}
+
+func TestNode_Ancestors(t *testing.T) {
+	img := parse(iteratorsInput, Class(`one`), Class(`one`), Tag(`img`))
+	var tags []string
+	var depths []int
+	for node := range img.Ancestors() {
+		tags = append(tags, node.Tag())
+		depths = append(depths, node.Depth)
+	}
+	if diff := deep.Equal(tags, []string{`div`, `div`, `body`, `html`, ``}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(depths, []int{4, 3, 2, 1, 0}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(
+		seqOuterHTMLs(img.Ancestors(func(node Node) bool {
+			return node.Offset() == 0 && node.HasClass(`one`)
+		})),
+		outerHTMLs([]Node{img.Parent(), img.Parent().Parent()}),
+	); diff != nil {
+		t.Error(diff)
+	}
+	for range img.Ancestors() {
+		break
+	}
+}
+
+func TestNode_ChildrenSeq(t *testing.T) {
+	body := parse(iteratorsInput, Tag(`body`))
+	if diff := deep.Equal(
+		seqOuterHTMLs(body.ChildrenSeq(Tag(`img`))),
+		outerHTMLs(body.Children(Tag(`img`))),
+	); diff != nil {
+		t.Error(diff)
+	}
+	for node := range body.ChildrenSeq() {
+		if node.Depth != 3 {
+			t.Error(node.Depth)
+		}
+		break
+	}
+}
+
+func TestNode_Siblings(t *testing.T) {
+	div := parse(iteratorsInput, Class(`two`))
+	if diff := deep.Equal(
+		seqOuterHTMLs(div.FollowingSiblings()),
+		[]string{`<div class="one"><img alt="final"/></div>`},
+	); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(
+		seqOuterHTMLs(div.PrecedingSiblings(func(node Node) bool {
+			return node.Offset() == 0 && node.HasClass(`one`)
+		})),
+		[]string{`<div class="one"></div>`, `<div class="one"><img alt="top level"/><div class="one"><img alt="further nested"/></div></div>`},
+	); diff != nil {
+		t.Error(diff)
+	}
+	for range div.FollowingSiblings() {
+		break
+	}
+	for range div.PrecedingSiblings() {
+		break
+	}
+}
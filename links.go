@@ -0,0 +1,201 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Link is a url referenced by an element, see the `Node.Links` method
+type Link struct {
+	// URL is the resolved url
+	URL *url.URL
+	// Node is the element that referenced the url
+	Node Node
+	// Attr is the key of the attribute containing the url, or an empty string for the content of style elements
+	Attr string
+	// Rel are the (lower case) values of the rel attribute of the element, if any
+	Rel []string
+}
+
+var linkCSSURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)`)
+
+// Links returns every url referenced by the attributes of elements in the sub-tree, including the receiver, in tree
+// order (then attribute order), including href, src, srcset (each candidate), action, formaction, poster, cite, data
+// (for object elements), the content of meta refresh elements, and CSS `url()` values in style attributes and
+// elements. Urls are resolved against the first base element (with a href) in the document, which is itself resolved
+// against base (typically the url of the document), and are only absolute if either is. Urls that can't be parsed
+// are skipped.
+func (n Node) Links(base *url.URL) []Link {
+	if n.Data == nil {
+		return nil
+	}
+
+	docBase := base
+	{
+		root := n
+		for parent := root.Parent(); parent.Data != nil; parent = parent.Parent() {
+			root = parent
+		}
+		if v, ok := root.FindNode(func(node Node) bool {
+			_, ok := node.GetAttr(``, `href`)
+			return ok && node.Tag() == `base` && node.Data.Namespace == ``
+		}); ok {
+			if u, ok := linkURL(base, v.GetAttrVal(``, `href`)); ok {
+				docBase = u
+			}
+		}
+	}
+
+	var links []Link
+	for node := range n.All(Type(html.ElementNode)) {
+		var rel []string
+		if v, ok := node.GetAttr(``, `rel`); ok {
+			rel = strings.Fields(strings.ToLower(v.Val))
+		}
+		add := func(attr string, ref string) {
+			resolve := docBase
+			if node.Tag() == `base` && attr == `href` {
+				resolve = base
+			}
+			if u, ok := linkURL(resolve, ref); ok {
+				links = append(links, Link{URL: u, Node: node, Attr: attr, Rel: rel})
+			}
+		}
+		for _, attr := range node.Data.Attr {
+			if attr.Namespace != `` {
+				continue
+			}
+			switch key := strings.ToLower(attr.Key); key {
+			case `href`, `src`, `action`, `formaction`, `poster`, `cite`:
+				add(key, attr.Val)
+			case `data`:
+				if node.Tag() == `object` {
+					add(key, attr.Val)
+				}
+			case `srcset`:
+				for _, ref := range linkSrcset(attr.Val) {
+					add(key, ref)
+				}
+			case `content`:
+				if node.Tag() == `meta` && strings.EqualFold(strings.TrimSpace(node.GetAttrVal(``, `http-equiv`)), `refresh`) {
+					if ref, ok := linkRefresh(attr.Val); ok {
+						add(key, ref)
+					}
+				}
+			case `style`:
+				for _, ref := range linkCSS(attr.Val) {
+					add(key, ref)
+				}
+			}
+		}
+		if node.Tag() == `style` && node.Data.Namespace == `` {
+			for _, ref := range linkCSS(node.InnerText()) {
+				add(``, ref)
+			}
+		}
+	}
+	return links
+}
+
+// linkURL parses a url, after stripping leading and trailing whitespace, and any tabs or newlines, resolving it
+// against base (if non-nil)
+func linkURL(base *url.URL, ref string) (*url.URL, bool) {
+	ref = strings.Trim(ref, "\t\n\f\r ")
+	ref = strings.NewReplacer("\t", ``, "\n", ``, "\r", ``).Replace(ref)
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u, true
+}
+
+// linkSrcset returns the urls of the image candidates in a srcset attribute
+func linkSrcset(s string) (refs []string) {
+	for {
+		s = strings.TrimLeft(s, "\t\n\f\r ,")
+		if s == `` {
+			return
+		}
+		i := strings.IndexAny(s, "\t\n\f\r ")
+		if i == -1 {
+			i = len(s)
+		}
+		ref := s[:i]
+		s = s[i:]
+		if trimmed := strings.TrimRight(ref, `,`); trimmed != ref {
+			refs = append(refs, trimmed)
+			continue
+		}
+		refs = append(refs, ref)
+		// skip descriptors, up to the next comma that isn't within parentheses
+		depth := 0
+		for i = 0; i < len(s); i++ {
+			if s[i] == '(' {
+				depth++
+			} else if s[i] == ')' && depth != 0 {
+				depth--
+			} else if s[i] == ',' && depth == 0 {
+				break
+			}
+		}
+		s = s[i:]
+	}
+}
+
+// linkRefresh returns the url (if any) from the content of a meta refresh element, e.g. "5; url=/next"
+func linkRefresh(s string) (string, bool) {
+	s = strings.TrimLeft(s, "\t\n\f\r ")
+	s = strings.TrimLeft(s, `.0123456789`)
+	s = strings.TrimLeft(s, "\t\n\f\r ")
+	if s == `` || (s[0] != ';' && s[0] != ',') {
+		return ``, false
+	}
+	s = strings.TrimLeft(s[1:], "\t\n\f\r ")
+	if len(s) >= 3 && strings.EqualFold(s[:3], `url`) {
+		if rest := strings.TrimLeft(s[3:], "\t\n\f\r "); strings.HasPrefix(rest, `=`) {
+			s = strings.TrimLeft(rest[1:], "\t\n\f\r ")
+		}
+	}
+	if s != `` && (s[0] == '"' || s[0] == '\'') {
+		if i := strings.IndexByte(s[1:], s[0]); i != -1 {
+			s = s[1 : i+1]
+		} else {
+			s = s[1:]
+		}
+	}
+	if s == `` {
+		return ``, false
+	}
+	return s, true
+}
+
+// linkCSS returns the values of every `url()` in a CSS string
+func linkCSS(s string) (refs []string) {
+	for _, match := range linkCSSURL.FindAllStringSubmatch(s, -1) {
+		if ref := match[1] + match[2] + match[3]; ref != `` {
+			refs = append(refs, ref)
+		}
+	}
+	return
+}
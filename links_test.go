@@ -0,0 +1,113 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"github.com/go-test/deep"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func linkStrings(links []Link) []string {
+	var result []string
+	for _, link := range links {
+		result = append(result, link.Node.Tag()+` `+link.Attr+` `+link.URL.String()+` [`+strings.Join(link.Rel, `,`)+`]`)
+	}
+	return result
+}
+
+func TestNode_Links(t *testing.T) {
+	const input = `<html><head>
+	<base href="/docs/">
+	<base href="/ignored/">
+	<meta http-equiv="Refresh" content="5; URL='next.html'">
+	<meta http-equiv="refresh" content="5">
+	<meta name="description" content="/not-a-link">
+	<link rel="Stylesheet alternate" href="style.css">
+	<style>body { background: url("bg.png") } .x { background: URL( 'x.png' ) } .y { background: url() }</style>
+</head><body>
+	<a href=" page.html#frag
+" rel="nofollow">a</a>
+	<a href="https://other.example/">b</a>
+	<a href="http://[::1">bad</a>
+	<img src="img.png" srcset="a.png 1x, b.png 2x,c.png,, d.png (max-width: 1px, 2px) 100w" data-src="lazy.png">
+	<video poster="poster.jpg"><source src="video.mp4"></video>
+	<object data="movie.swf"></object><div data="not-object"></div>
+	<blockquote cite="//example.org/quote">q</blockquote>
+	<form action="/submit"><button formaction="alt">b</button></form>
+	<div style="background-image: url(div.png)"></div>
+</body></html>`
+	base, _ := url.Parse(`https://example.com/a/b`)
+	doc := parse(input)
+	if diff := deep.Equal(linkStrings(doc.Links(base)), []string{
+		`base href https://example.com/docs/ []`,
+		`base href https://example.com/ignored/ []`,
+		`meta content https://example.com/docs/next.html []`,
+		`link href https://example.com/docs/style.css [stylesheet,alternate]`,
+		`style  https://example.com/docs/bg.png []`,
+		`style  https://example.com/docs/x.png []`,
+		`a href https://example.com/docs/page.html#frag [nofollow]`,
+		`a href https://other.example/ []`,
+		`img src https://example.com/docs/img.png []`,
+		`img srcset https://example.com/docs/a.png []`,
+		`img srcset https://example.com/docs/b.png []`,
+		`img srcset https://example.com/docs/c.png []`,
+		`img srcset https://example.com/docs/d.png []`,
+		`video poster https://example.com/docs/poster.jpg []`,
+		`source src https://example.com/docs/video.mp4 []`,
+		`object data https://example.com/docs/movie.swf []`,
+		`blockquote cite https://example.org/quote []`,
+		`form action https://example.com/submit []`,
+		`button formaction https://example.com/docs/alt []`,
+		`div style https://example.com/docs/div.png []`,
+	}); diff != nil {
+		t.Error(diff)
+	}
+
+	// the base element applies, even when searching a sub-tree
+	if diff := deep.Equal(linkStrings(doc.GetNode(Tag(`form`)).Links(nil)), []string{
+		`form action /submit []`,
+		`button formaction /docs/alt []`,
+	}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(linkStrings(parse(`<a href="x">x</a>`).Links(nil)), []string{`a href x []`}); diff != nil {
+		t.Error(diff)
+	}
+
+	if v := (Node{}).Links(base); v != nil {
+		t.Error(v)
+	}
+}
+
+func TestLinkRefresh(t *testing.T) {
+	for input, expected := range map[string]string{
+		`0;url=a`:             `a`,
+		` 1.5 , URL = "b c" `: `b c`,
+		`3;'d`:                `d`,
+		`3; e`:                `e`,
+		`3`:                   ``,
+		`3;`:                  ``,
+		`x;url=f`:             ``,
+	} {
+		if v, ok := linkRefresh(input); v != expected || ok != (expected != ``) {
+			t.Error(input, v, ok)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package markdown
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+var (
+	whitespace = regexp.MustCompile(`[\t\n\f\r ]+`)
+	entity     = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+	// orderedMarker matches text at the start of a line that would be interpreted as an ordered list item
+	orderedMarker = regexp.MustCompile(`^[0-9]{1,9}[.)](?: |$)`)
+)
+
+// inline renders a node as inline content, where "\n" indicates a hard line break, and whitespace has been
+// collapsed (but not trimmed)
+func (c *converter) inline(node htmlutil.Node) string {
+	if node.Type() == html.TextNode {
+		return c.escape(whitespace.ReplaceAllString(node.Data.Data, ` `))
+	}
+	if skip(node) {
+		return ``
+	}
+
+	switch node.Tag() {
+	case `br`:
+		return "\n"
+
+	case `em`, `i`:
+		return c.wrap(node, `*`)
+
+	case `strong`, `b`:
+		return c.wrap(node, `**`)
+
+	case `del`, `s`, `strike`:
+		return c.wrap(node, `~~`)
+
+	case `code`, `kbd`, `samp`, `tt`:
+		return c.codeSpan(node)
+
+	case `a`:
+		return c.link(node)
+
+	case `img`:
+		return c.image(node)
+
+	case `input`:
+		if c.list != 0 && strings.EqualFold(strings.TrimSpace(node.GetAttrVal(``, `type`)), `checkbox`) {
+			if _, ok := node.GetAttr(``, `checked`); ok {
+				return `[x] `
+			}
+			return `[ ] `
+		}
+		return ``
+	}
+
+	if isBlock(node) {
+		// block content within inline content (e.g. a div within a link) is separated by whitespace
+		return ` ` + c.inlineChildren(node) + ` `
+	}
+
+	return c.inlineChildren(node)
+}
+
+func (c *converter) inlineChildren(node htmlutil.Node) string {
+	var b strings.Builder
+	for child := range node.ChildrenSeq() {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+// wrap renders the children of node, surrounded by delimiter, moving any leading or trailing whitespace outside of
+// the delimiters, and omitting the delimiters if they are already in use by an ancestor
+func (c *converter) wrap(node htmlutil.Node, delimiter string) string {
+	c.emphasis[delimiter]++
+	text := c.inlineChildren(node)
+	c.emphasis[delimiter]--
+	if c.emphasis[delimiter] != 0 {
+		return text
+	}
+	trimmed := strings.Trim(text, " \n")
+	if trimmed == `` {
+		return text
+	}
+	i := strings.Index(text, trimmed)
+	return text[:i] + delimiter + trimmed + delimiter + text[i+len(trimmed):]
+}
+
+// codeSpan renders the text of node as a code span, moving any leading or trailing whitespace outside of the span
+func (c *converter) codeSpan(node htmlutil.Node) string {
+	text := whitespace.ReplaceAllString(node.OuterText(), ` `)
+	trimmed := strings.Trim(text, ` `)
+	if trimmed == `` {
+		return text
+	}
+	i := strings.Index(text, trimmed)
+	lead, trail := text[:i], text[i+len(trimmed):]
+	if c.table {
+		trimmed = strings.ReplaceAll(trimmed, `|`, `\|`)
+	}
+	fence := strings.Repeat("`", longestRun(trimmed, '`')+1)
+	if trimmed[0] == '`' || trimmed[len(trimmed)-1] == '`' {
+		trimmed = ` ` + trimmed + ` `
+	}
+	return lead + fence + trimmed + fence + trail
+}
+
+func (c *converter) link(node htmlutil.Node) string {
+	text := c.inlineChildren(node)
+	href, ok := node.GetAttr(``, `href`)
+	if !ok || c.opts.LinkStyle == LinkTextOnly {
+		return text
+	}
+	destination := strings.NewReplacer("\t", ``, "\n", ``, "\r", ``).Replace(strings.TrimSpace(href.Val))
+	title := node.GetAttrVal(``, `title`)
+
+	trimmed := strings.Trim(text, " \n")
+	i := strings.Index(text, trimmed)
+	if trimmed == `` {
+		i = len(text)
+	}
+	lead, trail := text[:i], text[i+len(trimmed):]
+
+	// autolinks, e.g. <https://example.com>
+	if title == `` && node.OuterWords() == destination && strings.Contains(destination, `:`) &&
+		!strings.ContainsAny(destination, " <>") && !c.table {
+		return lead + `<` + destination + `>` + trail
+	}
+
+	return lead + `[` + trimmed + `]` + c.destination(destination, title) + trail
+}
+
+func (c *converter) image(node htmlutil.Node) string {
+	alt := strings.TrimSpace(whitespace.ReplaceAllString(node.GetAttrVal(``, `alt`), ` `))
+	if c.opts.LinkStyle == LinkTextOnly {
+		return c.escape(alt)
+	}
+	src, ok := node.GetAttr(``, `src`)
+	if !ok {
+		return c.escape(alt)
+	}
+	destination := strings.NewReplacer("\t", ``, "\n", ``, "\r", ``).Replace(strings.TrimSpace(src.Val))
+	return `![` + c.escapeAlways(alt, `[]\`) + `]` + c.destination(destination, node.GetAttrVal(``, `title`))
+}
+
+// destination renders the destination and title of a link or image, using the configured link style
+func (c *converter) destination(destination string, title string) string {
+	if destination == `` || strings.ContainsAny(destination, " <>()") {
+		destination = `<` + strings.NewReplacer(`<`, `\<`, `>`, `\>`).Replace(destination) + `>`
+	}
+	if title != `` {
+		title = ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", ` `).Replace(title) + `"`
+	}
+	if c.opts.LinkStyle == LinkReference {
+		return `[` + c.reference(destination, title) + `]`
+	}
+	return `(` + destination + title + `)`
+}
+
+// escape escapes text according to the configured option
+func (c *converter) escape(s string) string {
+	switch c.opts.Escape {
+	case EscapeNone:
+		if c.table {
+			return strings.ReplaceAll(s, `|`, `\|`)
+		}
+		return s
+	case EscapeAll:
+		return c.escapeAlways(s, "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '\\', '`', '*', '_', '[', ']':
+			b.WriteByte('\\')
+		case '|':
+			if c.table {
+				b.WriteByte('\\')
+			}
+		case '~':
+			if i+1 < len(s) && s[i+1] == '~' || i > 0 && s[i-1] == '~' {
+				b.WriteByte('\\')
+			}
+		case '<':
+			if i+1 < len(s) && (s[i+1] == '/' || s[i+1] == '!' || s[i+1] == '?' ||
+				s[i+1] >= 'a' && s[i+1] <= 'z' || s[i+1] >= 'A' && s[i+1] <= 'Z') {
+				b.WriteByte('\\')
+			}
+		case '&':
+			if entity.MatchString(s[i:]) {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// escapeAlways escapes every character in chars, and pipes within tables
+func (c *converter) escapeAlways(s string, chars string) string {
+	if c.table && !strings.Contains(chars, `|`) {
+		chars += `|`
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(chars, s[i]) != -1 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// line renders inline content as lines of text, collapsing and trimming whitespace, and escaping any text at the
+// start of a line that would otherwise be interpreted as a block, joined by sep
+func (c *converter) line(s string, sep string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), ` `)
+		if line == `` {
+			continue
+		}
+		if c.opts.Escape == EscapeDefault {
+			if strings.IndexByte(`#>-+=`, line[0]) != -1 {
+				line = `\` + line
+			} else if v := orderedMarker.FindString(line); v != `` {
+				v = strings.TrimSuffix(v, ` `)
+				line = v[:len(v)-1] + `\` + line[len(v)-1:]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, sep)
+}
+
+// paragraph renders inline content as a paragraph, where hard line breaks are a backslash followed by a newline
+func (c *converter) paragraph(s string) string {
+	return c.line(s, "\\\n")
+}
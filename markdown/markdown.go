@@ -0,0 +1,501 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package markdown renders a `htmlutil.Node` sub-tree as CommonMark, with GitHub Flavored Markdown extensions
+// (tables, strikethrough and task lists).
+//
+// # Conversion
+//
+//   - headings are written in the ATX style (e.g. "## Heading"), and thematic breaks as "---"
+//   - emphasis (em, i) is written as "*text*", strong importance (strong, b) as "**text**", and deleted text (del, s,
+//     strike) as "~~text~~"
+//   - code (code, kbd, samp, tt) is written as a code span, and pre elements as fenced code blocks, with the language
+//     from a "language-x" (or "lang-x") class of the pre element, or of its code child
+//   - lists are written with "-" or "1." markers, respecting the start attribute of ol elements, where checkboxes
+//     within list items are written as task list markers
+//   - tables are written as GFM tables, using the header rows (see `htmlutil.NewTable`), or the first row, as the
+//     header, with alignment from the align attribute (or text-align style) of the header cells
+//   - whitespace is collapsed as per html, except within pre elements, and br elements are written as hard line breaks
+//   - head, script, style, template, form controls and foreign (svg and math) elements are omitted, and the content of
+//     other unknown elements is treated as either inline or block content, based on the tag
+package markdown
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EscapeDefault escapes only the characters that could otherwise be interpreted as markdown
+	EscapeDefault Escape = iota
+	// EscapeAll escapes every ASCII punctuation character
+	EscapeAll
+	// EscapeNone writes text as is (useful when the output won't be rendered), except for pipes within tables
+	EscapeNone
+)
+
+const (
+	// LinkInline writes links like `[text](url "title")`, and images like `![alt](url "title")`
+	LinkInline LinkStyle = iota
+	// LinkReference writes links like `[text][1]`, and images like `![alt][1]`, with the (numbered) link reference
+	// definitions at the end of the document
+	LinkReference
+	// LinkTextOnly writes only the text of links, and the alt text of images
+	LinkTextOnly
+)
+
+type (
+	// Escape controls how text is escaped
+	Escape int
+
+	// LinkStyle controls how links and images are written
+	LinkStyle int
+
+	// Options configures the conversion, where the zero value is the default
+	Options struct {
+		Escape    Escape
+		LinkStyle LinkStyle
+	}
+
+	converter struct {
+		opts Options
+		refs []string
+		// emphasis tracks the wrapping inline elements, to avoid nesting the same delimiters
+		emphasis map[string]int
+		// list is the depth of list items
+		list int
+		// table is true while rendering table cells
+		table bool
+	}
+
+	block struct {
+		text string
+		list bool
+	}
+)
+
+var (
+	// blockTags are html elements that are rendered as blocks
+	blockTags = map[string]bool{
+		`address`: true, `article`: true, `aside`: true, `blockquote`: true, `body`: true, `center`: true, `dd`: true,
+		`details`: true, `dialog`: true, `div`: true, `dl`: true, `dt`: true, `fieldset`: true, `figcaption`: true,
+		`figure`: true, `footer`: true, `form`: true, `h1`: true, `h2`: true, `h3`: true, `h4`: true, `h5`: true,
+		`h6`: true, `header`: true, `hgroup`: true, `hr`: true, `html`: true, `li`: true, `main`: true, `nav`: true,
+		`ol`: true, `p`: true, `pre`: true, `section`: true, `summary`: true, `table`: true, `ul`: true,
+	}
+
+	// skipTags are html elements that are omitted
+	skipTags = map[string]bool{
+		`button`: true, `datalist`: true, `embed`: true, `head`: true, `iframe`: true, `link`: true, `meta`: true,
+		`noscript`: true, `object`: true, `option`: true, `script`: true, `select`: true, `style`: true,
+		`template`: true, `textarea`: true, `title`: true,
+	}
+)
+
+// Convert renders the sub-tree of node (including node) as markdown, ending in a newline (unless empty), see the
+// package comment
+func Convert(node htmlutil.Node, opts Options) string {
+	if node.Data == nil {
+		return ``
+	}
+
+	c := converter{opts: opts, emphasis: make(map[string]int)}
+
+	nodes := []htmlutil.Node{node}
+	if node.Type() == html.DocumentNode {
+		nodes = children(node)
+	}
+
+	var b strings.Builder
+	for i, v := range c.blocks(nodes) {
+		if i != 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(v.text)
+	}
+	for i, ref := range c.refs {
+		if i == 0 && b.Len() != 0 {
+			b.WriteString("\n\n")
+		} else if i != 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(`[` + strconv.Itoa(i+1) + `]: ` + ref)
+	}
+	if b.Len() != 0 {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Write is like Convert, but writes the markdown to w
+func Write(w io.Writer, node htmlutil.Node, opts Options) error {
+	_, err := io.WriteString(w, Convert(node, opts))
+	return err
+}
+
+func children(node htmlutil.Node) []htmlutil.Node {
+	var nodes []htmlutil.Node
+	for child := range node.ChildrenSeq() {
+		nodes = append(nodes, child)
+	}
+	return nodes
+}
+
+// isBlock returns true if the node is an element rendered as a block
+func isBlock(node htmlutil.Node) bool {
+	return node.Type() == html.ElementNode && node.Data.Namespace == `` && blockTags[node.Tag()]
+}
+
+// skip returns true if the node (and its sub-tree) should be omitted
+func skip(node htmlutil.Node) bool {
+	switch node.Type() {
+	case html.TextNode:
+		return false
+	case html.ElementNode:
+		return node.Data.Namespace != `` || skipTags[node.Tag()]
+	default:
+		return true
+	}
+}
+
+// blocks renders nodes as blocks, where consecutive inline nodes form paragraphs
+func (c *converter) blocks(nodes []htmlutil.Node) []block {
+	var (
+		blocks []block
+		inline strings.Builder
+	)
+	flush := func() {
+		if v := c.paragraph(inline.String()); v != `` {
+			blocks = append(blocks, block{text: v})
+		}
+		inline.Reset()
+	}
+	for _, node := range nodes {
+		if skip(node) {
+			continue
+		}
+		if isBlock(node) {
+			flush()
+			blocks = append(blocks, c.block(node)...)
+			continue
+		}
+		inline.WriteString(c.inline(node))
+	}
+	flush()
+	return blocks
+}
+
+func (c *converter) block(node htmlutil.Node) []block {
+	switch tag := node.Tag(); tag {
+	case `h1`, `h2`, `h3`, `h4`, `h5`, `h6`:
+		text := c.line(c.inlineChildren(node), ` `)
+		if text == `` {
+			return nil
+		}
+		return []block{{text: strings.Repeat(`#`, int(tag[1]-'0')) + ` ` + text}}
+
+	case `p`:
+		if v := c.paragraph(c.inlineChildren(node)); v != `` {
+			return []block{{text: v}}
+		}
+		return nil
+
+	case `hr`:
+		return []block{{text: `---`}}
+
+	case `blockquote`:
+		text := join(c.blocks(children(node)))
+		if text == `` {
+			return nil
+		}
+		return []block{{text: indent(text, `> `, `> `)}}
+
+	case `ul`, `ol`:
+		return c.listBlock(node)
+
+	case `pre`:
+		return []block{{text: codeBlock(node)}}
+
+	case `table`:
+		if v := c.tableBlock(node); v != `` {
+			return []block{{text: v}}
+		}
+		return nil
+
+	default:
+		return c.blocks(children(node))
+	}
+}
+
+// join joins blocks with a blank line, except for lists following another block, which are joined with a single
+// newline (keeping nested lists tight)
+func join(blocks []block) string {
+	var b strings.Builder
+	for i, v := range blocks {
+		if i != 0 {
+			if v.list {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(v.text)
+	}
+	return b.String()
+}
+
+// indent prefixes the first line of s with first, and every other (non-empty) line with rest
+func indent(s string, first string, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == `` {
+			prefix = strings.TrimRight(prefix, ` `)
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *converter) listBlock(node htmlutil.Node) []block {
+	ordered := node.Tag() == `ol`
+	number := 1
+	if ordered {
+		if v, err := strconv.Atoi(strings.TrimSpace(node.GetAttrVal(``, `start`))); err == nil && v >= 0 {
+			number = v
+		}
+	}
+
+	c.list++
+	defer func() { c.list-- }()
+
+	var items [][]block
+	for child := range node.ChildrenSeq() {
+		switch {
+		case skip(child):
+		case child.Tag() == `li`:
+			items = append(items, c.blocks(children(child)))
+		case (child.Tag() == `ul` || child.Tag() == `ol`) && len(items) != 0:
+			// a list nested directly within a list belongs to the previous item
+			items[len(items)-1] = append(items[len(items)-1], c.block(child)...)
+		default:
+			if v := c.blocks([]htmlutil.Node{child}); len(v) != 0 {
+				items = append(items, v)
+			}
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	loose := false
+	for _, item := range items {
+		for i, v := range item {
+			if i != 0 && !v.list {
+				loose = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, item := range items {
+		if i != 0 {
+			if loose {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+		marker := `- `
+		if ordered {
+			marker = strconv.Itoa(number+i) + `. `
+		}
+		text := join(item)
+		if text == `` {
+			b.WriteString(strings.TrimRight(marker, ` `))
+			continue
+		}
+		b.WriteString(indent(text, marker, strings.Repeat(` `, len(marker))))
+	}
+	return []block{{text: b.String(), list: true}}
+}
+
+// codeBlock renders a pre element as a fenced code block
+func codeBlock(node htmlutil.Node) string {
+	language := codeLanguage(node)
+	if language == `` {
+		if code := node.FirstChild(htmlutil.Tag(`code`)); code.Data != nil && code.Data.Parent == node.Data {
+			language = codeLanguage(code)
+		}
+	}
+	text := strings.TrimSuffix(preText(node.Data), "\n")
+	fence := strings.Repeat("`", max(3, longestRun(text, '`')+1))
+	return fence + language + "\n" + text + "\n" + fence
+}
+
+func codeLanguage(node htmlutil.Node) string {
+	for _, class := range node.Classes() {
+		for _, prefix := range [...]string{`language-`, `lang-`} {
+			if v, ok := strings.CutPrefix(class, prefix); ok && v != `` {
+				return v
+			}
+		}
+	}
+	return ``
+}
+
+// preText returns the text of the sub-tree of a pre element, preserving whitespace, where br elements are newlines
+func preText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		for node := node.FirstChild; node != nil; node = node.NextSibling {
+			switch node.Type {
+			case html.TextNode:
+				b.WriteString(node.Data)
+			case html.ElementNode:
+				if node.Data == `br` && node.Namespace == `` {
+					b.WriteByte('\n')
+				} else {
+					walk(node)
+				}
+			}
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+func longestRun(s string, r byte) (longest int) {
+	current := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == r {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 0
+		}
+	}
+	return
+}
+
+func (c *converter) tableBlock(node htmlutil.Node) string {
+	table, err := htmlutil.NewTable(node)
+	if err != nil || table.Width() == 0 {
+		return join(c.blocks(children(node)))
+	}
+
+	c.table = true
+	defer func() { c.table = false }()
+
+	var (
+		header []string
+		align  []htmlutil.Node
+		rows   = table.Rows()
+	)
+	switch headerRows := table.HeaderRows(); {
+	case len(headerRows) == 1:
+		header = c.cells(headerRows[0], nil)
+		align = headerRows[0]
+	case len(headerRows) > 1:
+		for _, name := range table.Header() {
+			header = append(header, c.escape(name))
+		}
+		align = headerRows[len(headerRows)-1]
+	case len(rows) != 0:
+		header = c.cells(rows[0], nil)
+		align = rows[0]
+		rows = rows[1:]
+	default:
+		return ``
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString(`|`)
+		for _, cell := range cells {
+			b.WriteString(` ` + cell + ` |`)
+		}
+	}
+	writeRow(header)
+	b.WriteByte('\n')
+	delimiters := make([]string, len(header))
+	for i := range delimiters {
+		switch cellAlign(align[i]) {
+		case `left`:
+			delimiters[i] = `:---`
+		case `center`:
+			delimiters[i] = `:---:`
+		case `right`:
+			delimiters[i] = `---:`
+		default:
+			delimiters[i] = `---`
+		}
+	}
+	writeRow(delimiters)
+	// the last header row, so that any rowspan starting in the header is empty in the body
+	prev := align
+	for _, row := range rows {
+		b.WriteByte('\n')
+		writeRow(c.cells(row, prev))
+		prev = row
+	}
+	return b.String()
+}
+
+// cells renders each cell of a row, where cells repeated by a colspan or rowspan are empty
+func (c *converter) cells(row []htmlutil.Node, prev []htmlutil.Node) []string {
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		if cell.Data == nil ||
+			(i != 0 && row[i-1].Data == cell.Data) ||
+			(prev != nil && prev[i].Data == cell.Data) {
+			continue
+		}
+		cells[i] = c.line(c.inlineChildren(cell), ` `)
+	}
+	return cells
+}
+
+func cellAlign(cell htmlutil.Node) string {
+	if v := strings.ToLower(strings.TrimSpace(cell.GetAttrVal(``, `align`))); v != `` {
+		return v
+	}
+	for _, declaration := range strings.Split(cell.GetAttrVal(``, `style`), `;`) {
+		if property, value, ok := strings.Cut(declaration, `:`); ok && strings.EqualFold(strings.TrimSpace(property), `text-align`) {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ``
+}
+
+// reference adds a link reference definition (if it doesn't already exist), returning the label
+func (c *converter) reference(destination string, title string) string {
+	ref := destination + title
+	for i, v := range c.refs {
+		if v == ref {
+			return strconv.Itoa(i + 1)
+		}
+	}
+	c.refs = append(c.refs, ref)
+	return strconv.Itoa(len(c.refs))
+}
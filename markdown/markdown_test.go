@@ -0,0 +1,158 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package markdown
+
+import (
+	"bytes"
+	"github.com/joeycumines/go-htmlutil"
+	"strings"
+	"testing"
+)
+
+func parse(s string) htmlutil.Node {
+	v, err := htmlutil.Parse(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestConvert(t *testing.T) {
+	type TestCase struct {
+		Name   string
+		Input  string
+		Opts   Options
+		Output string
+	}
+	testCases := []TestCase{
+		{
+			Name:   `headings and paragraphs`,
+			Input:  "<head><title>T</title><style>p {}</style></head><h1>Title  <small>sub</small></h1><p>One\n  two <em> three </em><strong>four</strong><b><b>five</b></b></p><h2></h2><h3>#3</h3><hr><p>Line<br>break</p>",
+			Output: "# Title sub\n\nOne two *three* **four****five**\n\n### \\#3\n\n---\n\nLine\\\nbreak\n",
+		},
+		{
+			Name:   `inline content outside of blocks`,
+			Input:  `text <del>gone</del> <s></s><div>block</div> after <span>span</span>`,
+			Output: "text ~~gone~~\n\nblock\n\nafter span\n",
+		},
+		{
+			Name:   `escaping`,
+			Input:  `<p>*a* _b_ [c] \ ` + "`d`" + ` &lt;tag&gt; &amp;amp; a < b ~~e~~ ~f | g</p><p>- item</p><p>1. item</p><p>2) item</p><p>3.5</p><p># heading</p><p>&gt; quote</p>`,
+			Output: "\\*a\\* \\_b\\_ \\[c\\] \\\\ \\`d\\` \\<tag> \\&amp; a < b \\~\\~e\\~\\~ ~f | g\n\n\\- item\n\n1\\. item\n\n2\\) item\n\n3.5\n\n\\# heading\n\n\\> quote\n",
+		},
+		{
+			Name:   `escape all`,
+			Input:  `<p>a.b (c) - d</p>`,
+			Opts:   Options{Escape: EscapeAll},
+			Output: "a\\.b \\(c\\) \\- d\n",
+		},
+		{
+			Name:   `escape none`,
+			Input:  `<p>*a* - [b]</p><p># c</p>`,
+			Opts:   Options{Escape: EscapeNone},
+			Output: "*a* - [b]\n\n# c\n",
+		},
+		{
+			Name:   `links and images`,
+			Input:  `<p><a href="/a" title="A &quot;title&quot;"> text </a> <a href="https://example.com">https://example.com</a> <a href="/b c">space</a> <a>no href</a> <a href="/a" title="A &quot;title&quot;">again</a> <img src="i.png" alt="an [image]"> <img alt="no src"></p>`,
+			Output: "[text](/a \"A \\\"title\\\"\") <https://example.com> [space](</b c>) no href [again](/a \"A \\\"title\\\"\") ![an \\[image\\]](i.png) no src\n",
+		},
+		{
+			Name:   `reference links`,
+			Input:  `<p><a href="/a" title="A">one</a> <a href="/b">two</a> <a href="/a" title="A">three</a> <img src="i.png" alt="img"></p>`,
+			Opts:   Options{LinkStyle: LinkReference},
+			Output: "[one][1] [two][2] [three][1] ![img][3]\n\n[1]: /a \"A\"\n[2]: /b\n[3]: i.png\n",
+		},
+		{
+			Name:   `text only links`,
+			Input:  `<p><a href="/a">one</a> <img src="i.png" alt="*img*"></p>`,
+			Opts:   Options{LinkStyle: LinkTextOnly},
+			Output: "one \\*img\\*\n",
+		},
+		{
+			Name:   `code`,
+			Input:  "<p><code>a`b</code> <code>`c</code> <code> d </code> <code></code></p><pre><code class=\"x language-go\">func main() {\n\tfmt.Println(\"```\")\n}\n</code></pre><pre class=\"lang-sh\">echo<br>done</pre><pre>\n\n  plain</pre>",
+			Output: "``a`b`` `` `c `` `d`\n\n````go\nfunc main() {\n\tfmt.Println(\"```\")\n}\n````\n\n```sh\necho\ndone\n```\n\n```\n\n  plain\n```\n",
+		},
+		{
+			Name:   `lists`,
+			Input:  `<ul><li>one</li><li>two<ul><li>nested <em>em</em></li><li><ol start="3"><li>deep</li><li>deeper</li></ol></li></ul></li><li></li><li><input type="checkbox" checked> done</li><li><input type="checkbox"> todo</li></ul><input type="checkbox">`,
+			Output: "- one\n- two\n  - nested *em*\n  - 3. deep\n    4. deeper\n-\n- [x] done\n- [ ] todo\n",
+		},
+		{
+			Name:   `loose lists`,
+			Input:  `<ol><li><p>one</p><p>two</p></li><li>three</li></ol><ul><li>a</li><ul><li>b</li></ul><p>c</p></ul>`,
+			Output: "1. one\n\n   two\n\n2. three\n\n- a\n  - b\n- c\n",
+		},
+		{
+			Name:   `blockquotes`,
+			Input:  `<blockquote><p>one</p><blockquote>nested</blockquote><ul><li>item</li></ul></blockquote><blockquote> </blockquote>`,
+			Output: "> one\n>\n> > nested\n> - item\n",
+		},
+		{
+			Name:   `tables`,
+			Input:  `<table><thead><tr><th align="left">A</th><th style="color: red; text-align: center">B</th><th align="right">C|D</th><th>E</th></tr></thead><tbody><tr><td colspan="2"><em>x</em><br>y</td><td rowspan="2"><code>a|b</code></td></tr><tr><td>1</td><td>2</td><td>3</td></tr></tbody></table>`,
+			Output: "| A | B | C\\|D | E |\n| :--- | :---: | ---: | --- |\n| *x* y |  | `a\\|b` |  |\n| 1 | 2 |  | 3 |\n",
+		},
+		{
+			Name:   `tables without a header`,
+			Input:  `<table><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></table><table></table>`,
+			Output: "| a | b |\n| --- | --- |\n| c |  |\n",
+		},
+		{
+			Name:   `tables with a rowspan in the header`,
+			Input:  `<table><tr><td rowspan=2>A</td><td>B</td></tr><tr><td>c</td></tr></table>`,
+			Output: "| A | B |\n| --- | --- |\n|  | c |\n",
+		},
+		{
+			Name:   `tables with multiple header rows`,
+			Input:  `<table><thead><tr><th>A</th><th>*</th></tr><tr><th>B</th><th>C</th></tr></thead><tr><td>1</td><td>2</td></tr></table>`,
+			Output: "| A B | \\* C |\n| --- | --- |\n| 1 | 2 |\n",
+		},
+		{
+			Name:   `omitted`,
+			Input:  `<script>x</script><form><input value="x"><button>b</button><select><option>o</option></select><textarea>t</textarea></form><svg><text>svg</text></svg><!-- comment --><template>t</template>`,
+			Output: ``,
+		},
+		{
+			Name:   `block within inline`,
+			Input:  `<a href="/x"><div>one</div><div>two</div></a>`,
+			Output: "[one two](/x)\n",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if v := Convert(parse(testCase.Input), testCase.Opts); v != testCase.Output {
+				t.Errorf("unexpected output:\n%q\n%q", v, testCase.Output)
+			}
+		})
+	}
+}
+
+func TestConvert_element(t *testing.T) {
+	doc := parse(`<p>before</p><a href="/x">link</a>`)
+	if v := Convert(doc.GetNode(htmlutil.Tag(`a`)), Options{}); v != "[link](/x)\n" {
+		t.Error(v)
+	}
+	if v := Convert(htmlutil.Node{}, Options{}); v != `` {
+		t.Error(v)
+	}
+	var b bytes.Buffer
+	if err := Write(&b, doc.GetNode(htmlutil.Tag(`p`)), Options{}); err != nil || b.String() != "before\n" {
+		t.Error(b.String(), err)
+	}
+}
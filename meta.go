@@ -0,0 +1,194 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"strings"
+)
+
+type (
+	// Meta is the metadata of a document, see the `Metadata` function, note that urls are not resolved (see the
+	// `Node.Links` method)
+	Meta struct {
+		// Title is the (whitespace collapsed) text of the first title element
+		Title string
+		// Description is the content of the first description meta element
+		Description string
+		// Keywords are the (comma separated) values of every keywords meta element
+		Keywords []string
+		// Canonical is the href of the first canonical link element
+		Canonical string
+		// Robots are the (comma separated, lower case) directives of every robots meta element
+		Robots []string
+		// Alternates are the alternate link elements with a hreflang attribute
+		Alternates []MetaAlternate
+		// Icons are the link elements with a rel of icon (including e.g. "shortcut icon"), apple-touch-icon,
+		// apple-touch-icon-precomposed, or mask-icon
+		Icons []MetaIcon
+		// Feeds are the alternate link elements with an RSS, Atom, or JSON Feed type
+		Feeds []MetaFeed
+		// OpenGraph maps each OpenGraph property (e.g. "og:image") to the content of each meta element, in order
+		OpenGraph map[string][]string
+		// Twitter maps each Twitter card property (e.g. "twitter:card") to the content of each meta element, in order
+		Twitter map[string][]string
+	}
+
+	// MetaAlternate is an alternate language version of a document
+	MetaAlternate struct {
+		Hreflang string
+		Href     string
+	}
+
+	// MetaIcon is an icon for a document
+	MetaIcon struct {
+		Rel   string
+		Href  string
+		Type  string
+		Sizes string
+	}
+
+	// MetaFeed is a feed for a document
+	MetaFeed struct {
+		Href  string
+		Type  string
+		Title string
+	}
+)
+
+// Metadata extracts the metadata of a document (or any sub-tree), from every html title, meta and link element, in
+// tree order, where names (and rel values) are case insensitive, and are read from both the name and property
+// attributes (e.g. a meta element with both name="description" and property="og:description" sets both)
+func Metadata(doc Node) Meta {
+	var (
+		m     Meta
+		title bool
+	)
+
+	for node := range doc.All(Tag(`title`, `meta`, `link`)) {
+		if node.Data.Namespace != `` {
+			continue
+		}
+		switch node.Tag() {
+		case `title`:
+			if !title {
+				title = true
+				m.Title = node.OuterWords()
+			}
+
+		case `meta`:
+			content, ok := node.GetAttr(``, `content`)
+			if !ok {
+				continue
+			}
+			value := strings.TrimSpace(content.Val)
+			name := strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, `name`)))
+			m.meta(name, value)
+			if property := strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, `property`))); property != name {
+				m.meta(property, value)
+			}
+
+		case `link`:
+			href, ok := node.GetAttr(``, `href`)
+			if !ok {
+				continue
+			}
+			var (
+				rel       = strings.ToLower(strings.Join(strings.Fields(node.GetAttrVal(``, `rel`)), ` `))
+				typ       = strings.ToLower(strings.TrimSpace(node.GetAttrVal(``, `type`)))
+				alternate bool
+				icon      bool
+			)
+			for _, v := range strings.Fields(rel) {
+				switch v {
+				case `canonical`:
+					if m.Canonical == `` {
+						m.Canonical = strings.TrimSpace(href.Val)
+					}
+				case `alternate`:
+					alternate = true
+				case `icon`, `apple-touch-icon`, `apple-touch-icon-precomposed`, `mask-icon`:
+					icon = true
+				}
+			}
+			if icon {
+				m.Icons = append(m.Icons, MetaIcon{
+					Rel:   rel,
+					Href:  strings.TrimSpace(href.Val),
+					Type:  typ,
+					Sizes: strings.TrimSpace(node.GetAttrVal(``, `sizes`)),
+				})
+			}
+			if !alternate {
+				continue
+			}
+			if hreflang, ok := node.GetAttr(``, `hreflang`); ok {
+				m.Alternates = append(m.Alternates, MetaAlternate{
+					Hreflang: strings.TrimSpace(hreflang.Val),
+					Href:     strings.TrimSpace(href.Val),
+				})
+			}
+			switch typ {
+			case `application/rss+xml`, `application/atom+xml`, `application/feed+json`:
+				m.Feeds = append(m.Feeds, MetaFeed{
+					Href:  strings.TrimSpace(href.Val),
+					Type:  typ,
+					Title: strings.TrimSpace(node.GetAttrVal(``, `title`)),
+				})
+			}
+		}
+	}
+
+	return m
+}
+
+// meta handles the (lower case) name or property of a meta element, and its (trimmed) content
+func (m *Meta) meta(key string, value string) {
+	switch {
+	case key == `description`:
+		if m.Description == `` {
+			m.Description = value
+		}
+	case key == `keywords`:
+		m.Keywords = append(m.Keywords, metaList(value, false)...)
+	case key == `robots`:
+		m.Robots = append(m.Robots, metaList(value, true)...)
+	case strings.HasPrefix(key, `og:`):
+		if m.OpenGraph == nil {
+			m.OpenGraph = make(map[string][]string)
+		}
+		m.OpenGraph[key] = append(m.OpenGraph[key], value)
+	case strings.HasPrefix(key, `twitter:`):
+		if m.Twitter == nil {
+			m.Twitter = make(map[string][]string)
+		}
+		m.Twitter[key] = append(m.Twitter[key], value)
+	}
+}
+
+// metaList splits a comma separated list, trimming whitespace, and omitting empty values
+func metaList(s string, lower bool) []string {
+	var values []string
+	for _, v := range strings.Split(s, `,`) {
+		if v = strings.TrimSpace(v); v != `` {
+			if lower {
+				v = strings.ToLower(v)
+			}
+			values = append(values, v)
+		}
+	}
+	return values
+}
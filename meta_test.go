@@ -0,0 +1,105 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	const input = `<html><head>
+	<title>
+		Example   Page
+	</title>
+	<meta name="Description" content=" The description ">
+	<meta name="description" content="ignored">
+	<meta name="keywords" content="a, b,,c ">
+	<meta name="keywords" content="d">
+	<meta name="ROBOTS" content="NoIndex, nofollow">
+	<meta name="robots">
+	<link rel="canonical" href=" https://example.com/page ">
+	<link rel="canonical" href="https://example.com/ignored">
+	<link rel="alternate" hreflang="en-AU" href="/au">
+	<link rel="Alternate" hreflang="x-default" href="/">
+	<link rel="shortcut icon" href="/favicon.ico" type="image/x-icon">
+	<link rel="apple-touch-icon" href="/touch.png" sizes="180x180">
+	<link rel="iconic" href="/not-an-icon.png">
+	<link rel="alternate" type="application/rss+xml" title="RSS" href="/feed.xml">
+	<link rel="alternate" type="application/atom+xml" href="/atom.xml">
+	<link rel="alternate" type="text/html" href="/not-a-feed">
+	<link rel="alternate" type="application/json" href="/api/not-a-feed">
+	<link rel="alternate" type="Application/Feed+JSON" href="/feed.json">
+	<meta property="og:title" content="OG Title">
+	<meta property="og:image" content="/1.png">
+	<meta property="og:image:width" content="100">
+	<meta property="og:image" content="/2.png">
+	<meta name="twitter:card" content="summary">
+	<meta property="twitter:site" content="@example">
+</head><body>
+	<svg><title>not the title</title></svg>
+	<meta name="og:type" content="article">
+</body></html>`
+	if diff := deep.Equal(Metadata(parse(input)), Meta{
+		Title:       `Example Page`,
+		Description: `The description`,
+		Keywords:    []string{`a`, `b`, `c`, `d`},
+		Canonical:   `https://example.com/page`,
+		Robots:      []string{`noindex`, `nofollow`},
+		Alternates: []MetaAlternate{
+			{Hreflang: `en-AU`, Href: `/au`},
+			{Hreflang: `x-default`, Href: `/`},
+		},
+		Icons: []MetaIcon{
+			{Rel: `shortcut icon`, Href: `/favicon.ico`, Type: `image/x-icon`},
+			{Rel: `apple-touch-icon`, Href: `/touch.png`, Sizes: `180x180`},
+		},
+		Feeds: []MetaFeed{
+			{Href: `/feed.xml`, Type: `application/rss+xml`, Title: `RSS`},
+			{Href: `/atom.xml`, Type: `application/atom+xml`},
+			{Href: `/feed.json`, Type: `application/feed+json`},
+		},
+		OpenGraph: map[string][]string{
+			`og:title`:       {`OG Title`},
+			`og:image`:       {`/1.png`, `/2.png`},
+			`og:image:width`: {`100`},
+			`og:type`:        {`article`},
+		},
+		Twitter: map[string][]string{
+			`twitter:card`: {`summary`},
+			`twitter:site`: {`@example`},
+		},
+	}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(Metadata(Node{}), Meta{}); diff != nil {
+		t.Error(diff)
+	}
+
+	if diff := deep.Equal(Metadata(parse(`<meta name="description" property="og:description" content="hello">`+
+		`<meta name="og:title" property="OG:Title" content="title"><meta name="keywords" property="" content="a">`)), Meta{
+		Description: `hello`,
+		Keywords:    []string{`a`},
+		OpenGraph: map[string][]string{
+			`og:description`: {`hello`},
+			`og:title`:       {`title`},
+		},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,299 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// SetAttr sets the value of the first attribute matched by `Node.GetAttr`, or adds a new attribute, if n is an element
+// node, returning n, note that (like the parser) the key of a new attribute without a namespace is lower cased, unless
+// n is a foreign element (e.g. svg, which has attributes like viewBox)
+func (n Node) SetAttr(namespace string, key string, val string) Node {
+	if n.Type() != html.ElementNode {
+		return n
+	}
+	for i, attr := range n.Data.Attr {
+		if mutateAttrMatch(namespace, key, attr) {
+			n.Data.Attr[i].Val = val
+			return n
+		}
+	}
+	if namespace == `` && n.Data.Namespace == `` {
+		key = strings.ToLower(key)
+	}
+	n.Data.Attr = append(n.Data.Attr, html.Attribute{Namespace: namespace, Key: key, Val: val})
+	return n
+}
+
+// RemoveAttr removes every attribute matched by `Node.GetAttr`, returning n
+func (n Node) RemoveAttr(namespace string, key string) Node {
+	if n.Type() != html.ElementNode {
+		return n
+	}
+	attrs := n.Data.Attr[:0]
+	for _, attr := range n.Data.Attr {
+		if !mutateAttrMatch(namespace, key, attr) {
+			attrs = append(attrs, attr)
+		}
+	}
+	clear(n.Data.Attr[len(attrs):])
+	n.Data.Attr = attrs
+	return n
+}
+
+// mutateAttrMatch returns true if attr has the namespace and key, as per `Node.GetAttr`
+func mutateAttrMatch(namespace string, key string, attr html.Attribute) bool {
+	_, ok := getAttr(namespace, key, attr)
+	return ok
+}
+
+// AddClass adds each of the classes that n doesn't already have (see the `Node.HasClass` method) to the class
+// attribute, if n is an element node, returning n
+func (n Node) AddClass(classes ...string) Node {
+	if n.Type() != html.ElementNode {
+		return n
+	}
+	values := n.Classes()
+	for _, class := range strings.Fields(strings.Join(classes, ` `)) {
+		if !mutateContains(values, class) {
+			values = append(values, class)
+		}
+	}
+	if len(values) != 0 {
+		n.SetAttr(``, `class`, strings.Join(values, ` `))
+	}
+	return n
+}
+
+// RemoveClass removes each of the classes from the class attribute, removing the attribute if no classes remain,
+// returning n
+func (n Node) RemoveClass(classes ...string) Node {
+	if n.Type() != html.ElementNode {
+		return n
+	}
+	if _, ok := n.GetAttr(``, `class`); !ok {
+		return n
+	}
+	remove := strings.Fields(strings.Join(classes, ` `))
+	var values []string
+	for _, class := range n.Classes() {
+		if !mutateContains(remove, class) {
+			values = append(values, class)
+		}
+	}
+	if len(values) == 0 {
+		return n.RemoveAttr(``, `class`)
+	}
+	return n.SetAttr(``, `class`, strings.Join(values, ` `))
+}
+
+func mutateContains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetText replaces the children of an element (or document) node with a single text node (or no children, if text
+// is empty), or sets the data of a text or comment node, returning n
+func (n Node) SetText(text string) Node {
+	switch n.Type() {
+	case html.ElementNode, html.DocumentNode:
+		for n.Data.FirstChild != nil {
+			n.Data.RemoveChild(n.Data.FirstChild)
+		}
+		if text != `` {
+			n.Data.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+		}
+	case html.TextNode, html.CommentNode:
+		n.Data.Data = text
+	}
+	return n
+}
+
+// AppendChild moves child (removing it from any existing parent) to be the last child of n, returning child with an
+// updated `Depth`, or an empty node if either is empty, if n is not an element (or document) node, or if child is n
+// or an ancestor of n (which would form a cycle)
+func (n Node) AppendChild(child Node) Node {
+	if !mutateCanInsert(n, child) {
+		return Node{}
+	}
+	mutateDetach(child.Data)
+	n.Data.AppendChild(child.Data)
+	child.Depth = n.Depth + 1
+	return child
+}
+
+// PrependChild is like AppendChild, except child will become the first child of n
+func (n Node) PrependChild(child Node) Node {
+	if !mutateCanInsert(n, child) {
+		return Node{}
+	}
+	mutateDetach(child.Data)
+	n.Data.InsertBefore(child.Data, n.Data.FirstChild)
+	child.Depth = n.Depth + 1
+	return child
+}
+
+// InsertBefore moves node (removing it from any existing parent) to be the previous sibling of n, returning node with
+// an updated `Depth` (the same as n), or an empty node if either is empty, if n has no parent, or if node is n or an
+// ancestor of n
+func (n Node) InsertBefore(node Node) Node {
+	if n.Data == nil || !mutateCanInsert(n.Parent(), node) || node.Data == n.Data {
+		return Node{}
+	}
+	mutateDetach(node.Data)
+	n.Data.Parent.InsertBefore(node.Data, n.Data)
+	node.Depth = n.Depth
+	return node
+}
+
+// InsertAfter is like InsertBefore, except node will become the next sibling of n
+func (n Node) InsertAfter(node Node) Node {
+	if n.Data == nil || !mutateCanInsert(n.Parent(), node) || node.Data == n.Data {
+		return Node{}
+	}
+	mutateDetach(node.Data)
+	n.Data.Parent.InsertBefore(node.Data, n.Data.NextSibling)
+	node.Depth = n.Depth
+	return node
+}
+
+// Remove detaches n from its parent (if any), returning n with a `Depth` of 0, as the root of the detached tree
+func (n Node) Remove() Node {
+	if n.Data == nil {
+		return Node{}
+	}
+	mutateDetach(n.Data)
+	n.Depth = 0
+	return n
+}
+
+// ReplaceWith moves node (removing it from any existing parent) to the position of n, detaching n, and returning node
+// with an updated `Depth` (the same as n), or an empty node if either is empty, if n has no parent, or if node is an
+// ancestor of n, note that replacing n with itself is a no-op
+func (n Node) ReplaceWith(node Node) Node {
+	if n.Data != nil && node.Data == n.Data {
+		return n
+	}
+	if inserted := n.InsertBefore(node); inserted.Data != nil {
+		mutateDetach(n.Data)
+		return inserted
+	}
+	return Node{}
+}
+
+// Wrap moves wrapper (removing it from any existing parent) to the position of n (if any), then moves n to be the
+// last child of wrapper, returning wrapper with an updated `Depth` (the same as n), or an empty node if either is
+// empty, if wrapper is not an element node, or if wrapper is n or an ancestor of n
+func (n Node) Wrap(wrapper Node) Node {
+	if n.Data == nil || wrapper.Type() != html.ElementNode || mutateIsAncestor(wrapper.Data, n.Data) {
+		return Node{}
+	}
+	mutateDetach(wrapper.Data)
+	if n.Data.Parent != nil {
+		n.Data.Parent.InsertBefore(wrapper.Data, n.Data)
+	}
+	wrapper.Depth = n.Depth
+	wrapper.AppendChild(n)
+	return wrapper
+}
+
+// Unwrap replaces n with its children, returning the parent of n (with an updated `Depth`), or an empty node if n is
+// empty, or has no parent
+func (n Node) Unwrap() Node {
+	parent := n.Parent()
+	if parent.Data == nil {
+		return Node{}
+	}
+	for n.Data.FirstChild != nil {
+		child := n.Data.FirstChild
+		n.Data.RemoveChild(child)
+		parent.Data.InsertBefore(child, n.Data)
+	}
+	mutateDetach(n.Data)
+	return parent
+}
+
+// mutateCanInsert returns true if child may be inserted as a child of parent
+func mutateCanInsert(parent Node, child Node) bool {
+	switch parent.Type() {
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return false
+	}
+	return child.Data != nil && !mutateIsAncestor(child.Data, parent.Data)
+}
+
+// mutateIsAncestor returns true if ancestor is node, or an ancestor of node
+func mutateIsAncestor(ancestor *html.Node, node *html.Node) bool {
+	for ; node != nil; node = node.Parent {
+		if node == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// mutateDetach removes node from its parent, if any
+func mutateDetach(node *html.Node) {
+	if node.Parent != nil {
+		node.Parent.RemoveChild(node)
+	}
+}
+
+// RemoveAll removes every node from the sub-tree (a search including the receiver) matching the filters (see package
+// comment for filter behavior), returning the number of nodes removed, note that the matches are resolved before any
+// are removed, and that matches within an already removed node are not counted
+func (n Node) RemoveAll(filters ...func(node Node) bool) (count int) {
+	removed := make(map[*html.Node]struct{})
+	for _, node := range n.FilterNodes(filters...) {
+		if mutateAnyAncestor(removed, node.Data) {
+			continue
+		}
+		mutateDetach(node.Data)
+		removed[node.Data] = struct{}{}
+		count++
+	}
+	return
+}
+
+// UnwrapAll replaces every node from the sub-tree (a search including the receiver) matching the filters (see package
+// comment for filter behavior) with its children, as per the `Unwrap` method, returning the number of nodes
+// unwrapped, note that the matches are resolved before any are unwrapped, and that nodes without a parent are skipped
+func (n Node) UnwrapAll(filters ...func(node Node) bool) (count int) {
+	for _, node := range n.FilterNodes(filters...) {
+		if node.Unwrap().Data != nil {
+			count++
+		}
+	}
+	return
+}
+
+// mutateAnyAncestor returns true if node, or any ancestor of node, is in nodes
+func mutateAnyAncestor(nodes map[*html.Node]struct{}, node *html.Node) bool {
+	for ; node != nil; node = node.Parent {
+		if _, ok := nodes[node]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"testing"
+)
+
+func TestNode_SetAttr(t *testing.T) {
+	doc := parse(`<div ID="a" class="x  y" data-v="1"></div>`)
+	div := doc.GetNode(Tag(`div`))
+	if v := div.SetAttr(``, `id`, `b`).SetAttr(``, `Title`, `t`).RemoveAttr(``, `DATA-V`); v.Data != div.Data {
+		t.Fatal(v)
+	}
+	div.AddClass(`y z`, `w`).RemoveClass(`x`)
+	if v := div.OuterHTML(); v != `<div id="b" class="y z w" title="t"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	div.RemoveClass(`y`, `z`, `w`)
+	if v := div.OuterHTML(); v != `<div id="b" title="t"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	div.RemoveClass(`y`).AddClass().AddClass(` `)
+	if v := div.OuterHTML(); v != `<div id="b" title="t"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	div.FirstChild().SetAttr(``, `id`, `c`)
+	if v := Sanitize(parse(`<a>x</a>`).GetNode(Tag(`a`)).SetAttr(``, `HREF`, `/x`), UGCPolicy()).OuterHTML(); v != `<a href="/x" rel="nofollow">x</a>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := parse(`<svg></svg>`).GetNode(Tag(`svg`)).SetAttr(`xlink`, `Href`, `x`).SetAttr(``, `viewBox`, `0`).Attr(); len(v) != 2 || v[0].Key != `Href` || v[1].Key != `viewBox` {
+		t.Error(v)
+	}
+	text := div.SetText(`a<b`).FirstChild()
+	if text.Type() != html.TextNode || text.Depth != div.Depth+1 {
+		t.Fatal(text)
+	}
+	text.SetAttr(``, `id`, `c`).AddClass(`x`).SetText(`c`)
+	if v := div.OuterHTML(); v != `<div id="b" title="t">c</div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := div.SetText(``).OuterHTML(); v != `<div id="b" title="t"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+}
+
+func TestNode_AppendChild(t *testing.T) {
+	doc := parse(`<div id="a"><p>1</p></div><div id="b"><span>2</span><span>3</span></div>`)
+	body := doc.GetNode(Tag(`body`))
+	a := body.GetNode(Tag(`div`))
+	b := a.NextSibling()
+	span := b.FirstChild()
+
+	if v := a.AppendChild(span); v.Data != span.Data || v.Depth != a.Depth+1 || v.Parent().Data != a.Data {
+		t.Fatal(v)
+	}
+	if v := a.PrependChild(b.FirstChild()); v.Depth != a.Depth+1 || v.PrevSibling().Data != nil {
+		t.Fatal(v)
+	}
+	if v := body.InnerHTML(); v != `<div id="a"><span>3</span><p>1</p><span>2</span></div><div id="b"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+
+	// cycles, and invalid parents, are no-ops
+	for _, v := range []Node{
+		a.AppendChild(a),
+		a.GetNode(Tag(`p`)).AppendChild(body),
+		a.GetNode(Tag(`p`)).FirstChild().AppendChild(b),
+		a.PrependChild(Node{}),
+		(Node{}).AppendChild(b),
+	} {
+		if v.Data != nil {
+			t.Error(v)
+		}
+	}
+	if v := body.InnerHTML(); v != `<div id="a"><span>3</span><p>1</p><span>2</span></div><div id="b"></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+
+	// detached nodes
+	node := Node{Data: &html.Node{Type: html.ElementNode, Data: `i`}}
+	if v := b.AppendChild(node.SetText(`4`)); v.Depth != b.Depth+1 {
+		t.Fatal(v)
+	}
+	if v := b.OuterHTML(); v != `<div id="b"><i>4</i></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+}
+
+func TestNode_InsertBefore(t *testing.T) {
+	doc := parse(`<ul><li>1</li><li>2</li><li>3</li></ul>`)
+	ul := doc.GetNode(Tag(`ul`))
+	one := ul.FirstChild()
+	three := ul.LastChild()
+
+	if v := one.InsertBefore(three); v.Data != three.Data || v.Depth != one.Depth || v.NextSibling().Data != one.Data {
+		t.Fatal(v)
+	}
+	if v := one.InsertAfter(ul.LastChild()); v.Depth != one.Depth || v.PrevSibling().Data != one.Data {
+		t.Fatal(v)
+	}
+	if v := ul.InnerHTML(); v != `<li>3</li><li>1</li><li>2</li>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+
+	for _, v := range []Node{
+		one.InsertBefore(one),
+		one.InsertAfter(ul),
+		doc.InsertBefore(one),
+		(Node{}).InsertAfter(one),
+		one.InsertAfter(Node{}),
+	} {
+		if v.Data != nil {
+			t.Error(v)
+		}
+	}
+	if v := ul.InnerHTML(); v != `<li>3</li><li>1</li><li>2</li>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+}
+
+func TestNode_Remove(t *testing.T) {
+	doc := parse(`<p>a<b>b</b>c</p>`)
+	p := doc.GetNode(Tag(`p`))
+	b := p.GetNode(Tag(`b`))
+	if v := b.Remove(); v.Data != b.Data || v.Depth != 0 || v.Parent().Data != nil || v.PrevSibling().Data != nil || v.NextSibling().Data != nil {
+		t.Fatal(v)
+	}
+	if v := p.OuterHTML(); v != `<p>ac</p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if p.FirstChild().NextSibling().Data != p.LastChild().Data || p.LastChild().PrevSibling().Data != p.FirstChild().Data {
+		t.Error(`inconsistent siblings`)
+	}
+	if v := b.Remove(); v.Data != b.Data {
+		t.Error(v)
+	}
+	if v := (Node{}).Remove(); v.Data != nil {
+		t.Error(v)
+	}
+}
+
+func TestNode_ReplaceWith(t *testing.T) {
+	doc := parse(`<p>a<b>b</b><i>c</i></p>`)
+	p := doc.GetNode(Tag(`p`))
+	b := p.GetNode(Tag(`b`))
+	i := p.GetNode(Tag(`i`))
+	if v := b.ReplaceWith(i); v.Data != i.Data || v.Depth != b.Depth {
+		t.Fatal(v)
+	}
+	if v := p.OuterHTML(); v != `<p>a<i>c</i></p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if b.Data.Parent != nil {
+		t.Error(b)
+	}
+	if v := i.ReplaceWith(i); v.Data != i.Data {
+		t.Error(v)
+	}
+	for _, v := range []Node{
+		i.ReplaceWith(p),
+		b.ReplaceWith(i),
+		(Node{}).ReplaceWith(i),
+		i.ReplaceWith(Node{}),
+	} {
+		if v.Data != nil {
+			t.Error(v)
+		}
+	}
+	if v := p.OuterHTML(); v != `<p>a<i>c</i></p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+}
+
+func TestNode_Wrap(t *testing.T) {
+	doc := parse(`<p>a<b>b</b>c</p><div><span></span></div>`)
+	p := doc.GetNode(Tag(`p`))
+	b := p.GetNode(Tag(`b`))
+	span := doc.GetNode(Tag(`span`))
+	if v := b.Wrap(span); v.Data != span.Data || v.Depth != b.Depth || v.FirstChild().Data != b.Data || v.FirstChild().Depth != b.Depth+1 {
+		t.Fatal(v)
+	}
+	if v := doc.GetNode(Tag(`body`)).InnerHTML(); v != `<p>a<span><b>b</b></span>c</p><div></div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	for _, v := range []Node{
+		b.Wrap(p),
+		b.Wrap(b),
+		b.Wrap(b.FirstChild()),
+		b.Wrap(Node{}),
+		(Node{}).Wrap(span),
+	} {
+		if v.Data != nil {
+			t.Error(v)
+		}
+	}
+
+	if v := span.Unwrap(); v.Data != p.Data || v.Depth != p.Depth {
+		t.Fatal(v)
+	}
+	if v := p.OuterHTML(); v != `<p>a<b>b</b>c</p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if span.Data.Parent != nil || span.Data.FirstChild != nil {
+		t.Error(span)
+	}
+	if b.PrevSibling().Data != p.Data.FirstChild || b.NextSibling().Data != p.Data.LastChild {
+		t.Error(`inconsistent siblings`)
+	}
+	if v := doc.Unwrap(); v.Data != nil {
+		t.Error(v)
+	}
+	if v := (Node{}).Unwrap(); v.Data != nil {
+		t.Error(v)
+	}
+}
+
+func TestNode_RemoveAll(t *testing.T) {
+	doc := parse(`<div><script>a</script><p>b<script>c</script><img src="x.gif" width="1" height="1"><img src="y.png"></p><div class="ad"><div class="ad">d</div></div>e</div>`)
+	div := doc.GetNode(Tag(`div`))
+	if v := div.RemoveAll(Tag(`script`)); v != 2 {
+		t.Error(v)
+	}
+	if v := div.RemoveAll(func(node Node) bool {
+		return node.HasClass(`ad`) || (node.Tag() == `img` && node.GetAttrVal(``, `width`) == `1`)
+	}); v != 2 {
+		t.Error(v)
+	}
+	if v := div.OuterHTML(); v != `<div><p>b<img src="y.png"/></p>e</div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := div.RemoveAll(Tag(`span`)); v != 0 {
+		t.Error(v)
+	}
+	if v := div.FirstChild().RemoveAll(); v != 1 {
+		t.Error(v)
+	}
+	if v := div.OuterHTML(); v != `<div>e</div>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := (Node{}).RemoveAll(Tag(`div`)); v != 0 {
+		t.Error(v)
+	}
+}
+
+func TestNode_UnwrapAll(t *testing.T) {
+	doc := parse(`<p><span>a<span>b</span></span> <font><b>c</b></font></p>`)
+	p := doc.GetNode(Tag(`p`))
+	if v := p.UnwrapAll(Tag(`span`, `font`)); v != 3 {
+		t.Error(v)
+	}
+	if v := p.OuterHTML(); v != `<p>ab <b>c</b></p>` {
+		t.Errorf("unexpected output:\n%q", v)
+	}
+	if v := doc.UnwrapAll(); v != 0 {
+		t.Error(v)
+	}
+	if v := (Node{}).UnwrapAll(Tag(`p`)); v != 0 {
+		t.Error(v)
+	}
+}
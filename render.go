@@ -0,0 +1,516 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// AttrOrderSource writes attributes in the order they appear in the tree
+	AttrOrderSource AttrOrder = iota
+	// AttrOrderSorted writes attributes sorted by (namespace prefixed) key
+	AttrOrderSorted
+)
+
+const (
+	// QuoteDouble quotes attribute values with double quotes, e.g. `id="a"`
+	QuoteDouble QuoteStyle = iota
+	// QuoteSingle quotes attribute values with single quotes, e.g. `id='a'`
+	QuoteSingle
+	// QuoteMinimal omits quotes where possible, e.g. `id=a`, otherwise using double quotes
+	QuoteMinimal
+)
+
+type (
+	// AttrOrder is the order attributes are written by a `Renderer`
+	AttrOrder int
+
+	// QuoteStyle is the quoting of attribute values written by a `Renderer`
+	QuoteStyle int
+
+	// Renderer writes html, like `html.Render`, but with options to control formatting, where the zero value behaves
+	// like `html.Render`, except that void elements are not self-closing (e.g. `<br>` rather than `<br/>`), and that
+	// only the characters that require escaping are escaped.
+	//
+	// Pretty printing is enabled if either Indent or LineWidth are set, and writes each block level element (e.g. div,
+	// p, li, tr) on its own line, indented by its depth, with any inline content (text, and inline elements such as a
+	// or span) between, wrapped at whitespace, to fit within the line width (if possible). Whitespace that would not
+	// be rendered (e.g. between block level elements, or at the start or end of a block) is replaced by line breaks and
+	// indentation, and other whitespace (within inline content) is collapsed to a single space or line break, but is
+	// never added or removed. The content of elements that preserve whitespace (pre, textarea, script, style, and
+	// similar, elements with a `white-space` inline style that preserves whitespace, and foreign elements such as
+	// svg) is written as is.
+	Renderer struct {
+		// Indent is written once per level of depth, for pretty printing
+		Indent string
+		// LineWidth is the (soft) maximum number of characters per line, for pretty printing, if greater than zero,
+		// where long opening tags of block level elements are wrapped by writing each attribute on its own line
+		LineWidth int
+		// AttrOrder controls the order attributes are written
+		AttrOrder AttrOrder
+		// Quote controls the quoting of attribute values
+		Quote QuoteStyle
+		// MinimizeBooleanAttrs writes boolean attributes (e.g. checked, disabled) with an empty value, or a value
+		// equal to the key, as just the key, e.g. `<input checked>` rather than `<input checked="">`
+		MinimizeBooleanAttrs bool
+		// SelfClosingVoid writes void elements as self-closing, e.g. `<br/>`, like `html.Render`
+		SelfClosingVoid bool
+	}
+
+	renderWriter struct {
+		Renderer
+		w *bufio.Writer
+		// err is an error with the tree, e.g. a void element with children
+		err error
+		// done indicates that nothing further may be written, after a plaintext element
+		done bool
+	}
+
+	// renderWord is inline content that may not be broken across lines, which is preceded by either a space (which
+	// may be written as a line break), or a forced line break (e.g. following a br element), or neither
+	renderWord struct {
+		text    string
+		space   bool
+		newline bool
+	}
+)
+
+var (
+	renderVoidTags = map[string]bool{
+		`area`: true, `base`: true, `br`: true, `col`: true, `embed`: true, `hr`: true, `img`: true, `input`: true,
+		`keygen`: true, `link`: true, `meta`: true, `param`: true, `source`: true, `track`: true, `wbr`: true,
+	}
+
+	// renderRawTags are elements with text content that is written as is, without escaping
+	renderRawTags = map[string]bool{
+		`iframe`: true, `noembed`: true, `noframes`: true, `noscript`: true, `plaintext`: true, `script`: true,
+		`style`: true, `xmp`: true,
+	}
+
+	renderBooleanAttrs = map[string]bool{
+		`allowfullscreen`: true, `async`: true, `autofocus`: true, `autoplay`: true, `checked`: true,
+		`controls`: true, `default`: true, `defer`: true, `disabled`: true, `formnovalidate`: true, `hidden`: true,
+		`inert`: true, `ismap`: true, `itemscope`: true, `loop`: true, `multiple`: true, `muted`: true,
+		`nomodule`: true, `novalidate`: true, `open`: true, `playsinline`: true, `readonly`: true, `required`: true,
+		`reversed`: true, `selected`: true,
+	}
+
+	// renderBlockTags are elements where adjacent whitespace is not rendered, in addition to `renderedBlockTags`, and
+	// any child of a head element
+	renderBlockTags = map[string]bool{
+		`colgroup`: true, `col`: true, `frameset`: true, `frame`: true, `head`: true, `p`: true, `tbody`: true,
+		`td`: true, `tfoot`: true, `th`: true, `thead`: true, `tr`: true,
+	}
+
+	renderTextEscaper = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`, "\r", `&#13;`)
+)
+
+// Render writes the sub-tree of node to w, returning any error writing, or rendering (e.g. a void element with child
+// nodes), note that nothing will be written for an empty node
+func (r Renderer) Render(w io.Writer, node Node) error {
+	if node.Data == nil {
+		return nil
+	}
+	rw := renderWriter{Renderer: r, w: bufio.NewWriter(w)}
+	if (r.Indent != `` || r.LineWidth > 0) && !renderVerbatimAncestor(node.Data) {
+		if node.Data.Type == html.DocumentNode || renderBlock(node.Data) {
+			rw.block(node.Data, 0)
+		} else {
+			rw.lines(rw.words([]*html.Node{node.Data}), 0)
+		}
+	} else {
+		rw.compact(node.Data)
+	}
+	if rw.err != nil {
+		return rw.err
+	}
+	return rw.w.Flush()
+}
+
+func (r *renderWriter) write(s string) {
+	if !r.done {
+		_, _ = r.w.WriteString(s)
+	}
+}
+
+// block writes a block level element (or document) at the given depth, followed by a line break
+func (r *renderWriter) block(node *html.Node, depth int) {
+	indent := strings.Repeat(r.Indent, depth)
+
+	if node.Type == html.ElementNode && renderVerbatim(node) {
+		r.write(indent)
+		r.compact(node)
+		r.write("\n")
+		return
+	}
+
+	// the content, split into inline content (each a slice of words), and block level elements (each a single node)
+	var (
+		content [][]renderWord
+		blocks  []*html.Node
+		inline  []*html.Node
+	)
+	flush := func() {
+		if words := r.words(inline); len(words) != 0 {
+			content = append(content, words)
+			blocks = append(blocks, nil)
+		}
+		inline = nil
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if renderBlock(child) {
+			flush()
+			content = append(content, nil)
+			blocks = append(blocks, child)
+		} else {
+			inline = append(inline, child)
+		}
+	}
+	flush()
+
+	if node.Type == html.DocumentNode {
+		r.content(content, blocks, depth)
+		return
+	}
+
+	open, end := r.openTag(node), `</`+node.Data+`>`
+	if renderVoidTags[node.Data] {
+		if node.FirstChild != nil && r.err == nil {
+			r.err = fmt.Errorf("htmlutil.Renderer.Render void element <%s> has child nodes", node.Data)
+		}
+		end = ``
+	}
+
+	// single line, if there is only (unbroken) inline content, which fits
+	if len(blocks) == 0 || (len(blocks) == 1 && blocks[0] == nil) {
+		line := indent + open
+		if len(content) != 0 {
+			for _, word := range content[0] {
+				if word.newline {
+					line = ``
+					break
+				}
+				if word.space {
+					line += ` `
+				}
+				line += word.text
+			}
+		}
+		if line != `` && !strings.Contains(line, "\n") &&
+			(r.LineWidth <= 0 || utf8.RuneCountInString(line+end) <= r.LineWidth) {
+			r.write(line + end + "\n")
+			return
+		}
+	}
+
+	if r.LineWidth > 0 && utf8.RuneCountInString(indent+open) > r.LineWidth && len(node.Attr) > 1 {
+		attrs := r.attrs(node)
+		r.write(indent + `<` + node.Data)
+		for _, attr := range attrs {
+			r.write("\n" + indent + r.Indent + attr)
+		}
+		r.write(r.openTagEnd(node, attrs) + "\n")
+	} else {
+		r.write(indent + open + "\n")
+	}
+	r.content(content, blocks, depth+1)
+	if end != `` {
+		r.write(indent + end + "\n")
+	}
+}
+
+func (r *renderWriter) content(content [][]renderWord, blocks []*html.Node, depth int) {
+	for i := range content {
+		if blocks[i] != nil {
+			r.block(blocks[i], depth)
+		} else {
+			r.lines(content[i], depth)
+		}
+	}
+}
+
+// lines writes inline content at the given depth, wrapping at spaces, followed by a line break
+func (r *renderWriter) lines(words []renderWord, depth int) {
+	if len(words) == 0 {
+		return
+	}
+	indent := strings.Repeat(r.Indent, depth)
+	column := 0
+	for i, word := range words {
+		switch {
+		case i == 0:
+			r.write(indent)
+			column = utf8.RuneCountInString(indent)
+		case word.newline || (word.space && r.LineWidth > 0 && column+1+renderWidth(word.text) > r.LineWidth):
+			r.write("\n" + indent)
+			column = utf8.RuneCountInString(indent)
+		case word.space:
+			r.write(` `)
+			column++
+		}
+		r.write(word.text)
+		if i := strings.LastIndexByte(word.text, '\n'); i != -1 {
+			column = utf8.RuneCountInString(word.text[i+1:])
+		} else {
+			column += utf8.RuneCountInString(word.text)
+		}
+	}
+	r.write("\n")
+}
+
+// renderWidth returns the width of the first line of s
+func renderWidth(s string) int {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// words splits inline content into words, trimming any leading or trailing whitespace
+func (r *renderWriter) words(nodes []*html.Node) []renderWord {
+	var (
+		words []renderWord
+		word  renderWord
+		b     strings.Builder
+		// space and newline are pending, to precede the next word
+		space, newline bool
+	)
+	add := func(s string) {
+		if b.Len() != 0 && (space || newline) {
+			word.text = b.String()
+			words = append(words, word)
+			b.Reset()
+		}
+		if b.Len() == 0 {
+			word = renderWord{space: space && !newline && len(words) != 0, newline: newline && len(words) != 0}
+			space, newline = false, false
+		}
+		b.WriteString(s)
+	}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode && !renderRaw(node):
+			for text := node.Data; text != ``; {
+				i := strings.IndexAny(text, "\t\n\f\r ")
+				if i == -1 {
+					i = len(text)
+				}
+				if i != 0 {
+					add(renderTextEscaper.Replace(text[:i]))
+				}
+				text = text[i:]
+				if trimmed := strings.TrimLeft(text, "\t\n\f\r "); len(trimmed) != len(text) {
+					space = true
+					text = trimmed
+				}
+			}
+
+		case node.Type == html.ElementNode && !renderVerbatim(node) && !renderVoidTags[node.Data]:
+			add(r.openTag(node))
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+			add(`</` + node.Data + `>`)
+
+		default:
+			var b strings.Builder
+			c := renderWriter{Renderer: r.Renderer, w: bufio.NewWriter(&b)}
+			c.compact(node)
+			_ = c.w.Flush()
+			if c.err != nil && r.err == nil {
+				r.err = c.err
+			}
+			add(b.String())
+			if node.Type == html.ElementNode && node.Namespace == `` && node.Data == `br` {
+				newline = true
+			}
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+	if b.Len() != 0 {
+		word.text = b.String()
+		words = append(words, word)
+	}
+	return words
+}
+
+// compact writes node as is, without any added whitespace
+func (r *renderWriter) compact(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		if renderRaw(node) {
+			r.write(node.Data)
+		} else {
+			r.write(renderTextEscaper.Replace(node.Data))
+		}
+
+	case html.DocumentNode:
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			r.compact(child)
+		}
+
+	case html.ElementNode:
+		r.write(r.openTag(node))
+		if renderVoidTags[node.Data] {
+			if node.FirstChild != nil && r.err == nil {
+				r.err = fmt.Errorf("htmlutil.Renderer.Render void element <%s> has child nodes", node.Data)
+			}
+			return
+		}
+		// a leading newline would be ignored by the parser
+		if child := node.FirstChild; child != nil && child.Type == html.TextNode && strings.HasPrefix(child.Data, "\n") {
+			switch node.Data {
+			case `pre`, `listing`, `textarea`:
+				r.write("\n")
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			r.compact(child)
+		}
+		if node.Data == `plaintext` && node.Namespace == `` {
+			// plaintext elements can't be closed
+			r.done = true
+		}
+		r.write(`</` + node.Data + `>`)
+
+	default:
+		// comments, doctypes, and raw nodes
+		var b strings.Builder
+		if err := html.Render(&b, node); err != nil {
+			if r.err == nil {
+				r.err = err
+			}
+			return
+		}
+		r.write(b.String())
+	}
+}
+
+// openTag returns the opening tag of an element
+func (r *renderWriter) openTag(node *html.Node) string {
+	attrs := r.attrs(node)
+	s := `<` + node.Data
+	for _, attr := range attrs {
+		s += ` ` + attr
+	}
+	return s + r.openTagEnd(node, attrs)
+}
+
+// openTagEnd returns the end of the opening tag of an element, following the attributes
+func (r *renderWriter) openTagEnd(node *html.Node, attrs []string) string {
+	if !renderVoidTags[node.Data] || !r.SelfClosingVoid {
+		return `>`
+	}
+	if len(attrs) != 0 {
+		// an unquoted value would otherwise include the slash
+		if attr := attrs[len(attrs)-1]; strings.Contains(attr, `=`) && !strings.HasSuffix(attr, `"`) &&
+			!strings.HasSuffix(attr, `'`) {
+			return ` />`
+		}
+	}
+	return `/>`
+}
+
+// attrs returns the attributes of an element, as they should be written
+func (r *renderWriter) attrs(node *html.Node) []string {
+	attrs := make([]string, 0, len(node.Attr))
+	for _, attr := range node.Attr {
+		key := attr.Key
+		if attr.Namespace != `` {
+			key = attr.Namespace + `:` + key
+		}
+		if r.MinimizeBooleanAttrs && attr.Namespace == `` && node.Namespace == `` &&
+			renderBooleanAttrs[strings.ToLower(attr.Key)] && (attr.Val == `` || strings.EqualFold(attr.Val, attr.Key)) {
+			attrs = append(attrs, key)
+			continue
+		}
+		attrs = append(attrs, key+`=`+r.quote(attr.Val))
+	}
+	if r.AttrOrder == AttrOrderSorted {
+		slices.SortStableFunc(attrs, func(a, b string) int {
+			a, _, _ = strings.Cut(a, `=`)
+			b, _, _ = strings.Cut(b, `=`)
+			return strings.Compare(a, b)
+		})
+	}
+	return attrs
+}
+
+func (r *renderWriter) quote(v string) string {
+	v = strings.NewReplacer(`&`, `&amp;`, "\r", `&#13;`).Replace(v)
+	switch r.Quote {
+	case QuoteSingle:
+		return `'` + strings.ReplaceAll(v, `'`, `&#39;`) + `'`
+	case QuoteMinimal:
+		if v != `` && !strings.ContainsAny(v, "\t\n\f\r \"'=<>`") {
+			return v
+		}
+	}
+	return `"` + strings.ReplaceAll(v, `"`, `&#34;`) + `"`
+}
+
+// renderRaw returns true if node is text that must be written as is
+func renderRaw(node *html.Node) bool {
+	parent := node.Parent
+	return node.Type == html.TextNode && parent != nil && parent.Type == html.ElementNode && parent.Namespace == `` &&
+		renderRawTags[parent.Data]
+}
+
+// renderBlock returns true if whitespace adjacent to node is not rendered
+func renderBlock(node *html.Node) bool {
+	if node.Type != html.ElementNode || node.Namespace != `` {
+		return false
+	}
+	if renderedBlockTags[node.Data] || renderBlockTags[node.Data] {
+		return true
+	}
+	parent := node.Parent
+	return parent != nil && parent.Type == html.ElementNode && parent.Namespace == `` && parent.Data == `head`
+}
+
+// renderVerbatim returns true if the content of node (an element) must be written as is
+func renderVerbatim(node *html.Node) bool {
+	if node.Namespace != `` || renderRawTags[node.Data] || node.Data == `textarea` || node.Data == `title` {
+		return true
+	}
+	switch renderedWhiteSpace(node) {
+	case `pre`, `pre-wrap`, `pre-line`, `break-spaces`:
+		return true
+	}
+	return false
+}
+
+// renderVerbatimAncestor returns true if node is within an element with content that must be written as is
+func renderVerbatimAncestor(node *html.Node) bool {
+	for node = node.Parent; node != nil; node = node.Parent {
+		if node.Type == html.ElementNode && renderVerbatim(node) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"errors"
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	const page = `<!DOCTYPE html><html><head><title>A  b</title><meta charset="utf-8"><script>if (a < b) {}</script></head>` +
+		"<body>\n  <div class=\"x\" id=\"y\"><p>Some <b>bold</b>text, and a <a href=\"/x?a=1&amp;b=2\">link</a> that goes on for a while.</p>" +
+		"<pre>\n\n  keep   this\n</pre><ul><li>one</li><li>two <ul><li>three</li></ul></li></ul>" +
+		"text<div>block</div>more<br> after <textarea>\n x  y</textarea></div>" +
+		`<table><tr><td> 1 </td><td>2</td></tr></table><!-- c --></body></html>`
+	for _, testCase := range []struct {
+		Name     string
+		Input    string
+		Node     func(node Node) Node
+		Renderer Renderer
+		Output   string
+		// SkipText skips comparing the rendered text, e.g. if the context of the node is required
+		SkipText bool
+	}{
+		{
+			Name:   `compact`,
+			Input:  page,
+			Output: strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(page, `<tr>`, `<tbody><tr>`), `</tr>`, `</tr></tbody>`), "<textarea>\n", `<textarea>`),
+		},
+		{
+			Name:     `pretty`,
+			Input:    page,
+			Renderer: Renderer{Indent: `  `},
+			Output: `<!DOCTYPE html>
+<html>
+  <head>
+    <title>A  b</title>
+    <meta charset="utf-8">
+    <script>if (a < b) {}</script>
+  </head>
+  <body>
+    <div class="x" id="y">
+      <p>Some <b>bold</b>text, and a <a href="/x?a=1&amp;b=2">link</a> that goes on for a while.</p>
+      <pre>
+
+  keep   this
+</pre>
+      <ul>
+        <li>one</li>
+        <li>
+          two
+          <ul>
+            <li>three</li>
+          </ul>
+        </li>
+      </ul>
+      text
+      <div>block</div>
+      more<br>
+      after <textarea> x  y</textarea>
+    </div>
+    <table>
+      <tbody>
+        <tr>
+          <td>1</td>
+          <td>2</td>
+        </tr>
+      </tbody>
+    </table>
+    <!-- c -->
+  </body>
+</html>
+`,
+		},
+		{
+			Name:     `line width`,
+			Input:    `<div><p>Some <b>bold</b>text, and a <a href="/x">long link</a> that goes on for a while.</p><p lang="en" title="a title" class="a">short</p></div>`,
+			Node:     func(node Node) Node { return node.GetNode(Tag(`div`)) },
+			Renderer: Renderer{Indent: "\t", LineWidth: 30},
+			Output: "<div>\n\t<p>\n\t\tSome <b>bold</b>text, and a\n\t\t<a href=\"/x\">long link</a>\n\t\tthat goes on for a while.\n\t</p>\n" +
+				"\t<p\n\t\tlang=\"en\"\n\t\ttitle=\"a title\"\n\t\tclass=\"a\">\n\t\tshort\n\t</p>\n</div>\n",
+		},
+		{
+			Name:     `inline root`,
+			Input:    "<p><span>a\n   <i>b</i>\n</span></p>",
+			Node:     func(node Node) Node { return node.GetNode(Tag(`span`)) },
+			Renderer: Renderer{Indent: `  `},
+			Output:   "<span>a <i>b</i> </span>\n",
+		},
+		{
+			Name:     `within pre`,
+			Input:    "<pre><span>a\n   <i>b</i>\n</span></pre>",
+			Node:     func(node Node) Node { return node.GetNode(Tag(`span`)) },
+			Renderer: Renderer{Indent: `  `},
+			Output:   "<span>a\n   <i>b</i>\n</span>",
+			SkipText: true,
+		},
+		{
+			Name:     `white-space style`,
+			Input:    "<div><div style=\"white-space: pre-wrap\"> a  <p> b </p></div><span style=white-space:pre> c  d </span></div>",
+			Node:     func(node Node) Node { return node.GetNode(Tag(`div`)) },
+			Renderer: Renderer{Indent: `  `},
+			Output:   "<div>\n  <div style=\"white-space: pre-wrap\"> a  <p> b </p></div>\n  <span style=\"white-space:pre\"> c  d </span>\n</div>\n",
+		},
+		{
+			Name:   `attributes`,
+			Input:  `<input type="checkbox" checked="CHECKED" disabled="" value="" data-x='a"b' title="it's" z="a/"><br class="x"><svg viewBox="0 0 1 1"><path d="M0"/></svg>`,
+			Node:   func(node Node) Node { return node.GetNode(Tag(`body`)) },
+			Output: `<body><input type="checkbox" checked="CHECKED" disabled="" value="" data-x="a&#34;b" title="it's" z="a/"><br class="x"><svg viewBox="0 0 1 1"><path d="M0"></path></svg></body>`,
+		},
+		{
+			Name:  `attributes options`,
+			Input: `<input type="checkbox" checked="CHECKED" disabled="" value="" data-x='a"b' title="it's" z="a/"><br class="x"><svg viewBox="0 0 1 1"><path d="M0"/></svg>`,
+			Node:  func(node Node) Node { return node.GetNode(Tag(`body`)) },
+			Renderer: Renderer{
+				AttrOrder:            AttrOrderSorted,
+				Quote:                QuoteSingle,
+				MinimizeBooleanAttrs: true,
+				SelfClosingVoid:      true,
+			},
+			Output: `<body><input checked data-x='a"b' disabled title='it&#39;s' type='checkbox' value='' z='a/'/><br class='x'/><svg viewBox='0 0 1 1'><path d='M0'></path></svg></body>`,
+		},
+		{
+			Name:     `minimal quotes`,
+			Input:    `<input type="checkbox" checked="CHECKED" disabled="" value="" data-x='a"b' title="it's" z="a/"><br class="x"><a href="/a?b=c">x</a>`,
+			Node:     func(node Node) Node { return node.GetNode(Tag(`body`)) },
+			Renderer: Renderer{Quote: QuoteMinimal, SelfClosingVoid: true},
+			Output:   `<body><input type=checkbox checked=CHECKED disabled="" value="" data-x="a&#34;b" title="it's" z=a/ /><br class=x /><a href="/a?b=c">x</a></body>`,
+		},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			node := parse(testCase.Input)
+			if testCase.Node != nil {
+				node = testCase.Node(node)
+			}
+			var b strings.Builder
+			if err := testCase.Renderer.Render(&b, node); err != nil {
+				t.Fatal(err)
+			}
+			if v := b.String(); v != testCase.Output {
+				t.Errorf("unexpected output:\n%s\n%s", v, testCase.Output)
+			}
+			// the rendered text must be unchanged, when parsed again
+			if v, expected := parse(b.String()).RenderedText(), node.RenderedText(); !testCase.SkipText && v != expected {
+				t.Errorf("unexpected text:\n%q\n%q", v, expected)
+			}
+		})
+	}
+}
+
+func TestRenderer_Render_errors(t *testing.T) {
+	node := Node{Data: &html.Node{Type: html.ElementNode, Data: `div`}}
+	node.AppendChild(Node{Data: &html.Node{Type: html.ElementNode, Data: `br`}}).
+		AppendChild(Node{Data: &html.Node{Type: html.TextNode, Data: `x`}})
+	for _, r := range []Renderer{{}, {Indent: `  `}} {
+		var b strings.Builder
+		if err := r.Render(&b, node); err == nil || err.Error() != `htmlutil.Renderer.Render void element <br> has child nodes` {
+			t.Error(err)
+		}
+	}
+
+	expected := errors.New(`some error`)
+	if err := (Renderer{}).Render(renderErrorWriter{expected}, parse(`<p>a</p>`)); err != expected {
+		t.Error(err)
+	}
+
+	var b strings.Builder
+	if err := (Renderer{}).Render(&b, Node{}); err != nil || b.Len() != 0 {
+		t.Error(err, b.String())
+	}
+}
+
+type renderErrorWriter struct{ err error }
+
+func (w renderErrorWriter) Write([]byte) (int, error) { return 0, w.err }
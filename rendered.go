@@ -0,0 +1,283 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+type (
+	// renderedItem is either text, or a required line break count (if breaks is non-negative), as per the innerText
+	// algorithm, where a count of 0 is a boundary that only discards collapsible whitespace
+	renderedItem struct {
+		text     string
+		breaks   int
+		preserve bool
+	}
+
+	renderedText struct {
+		items []renderedItem
+	}
+)
+
+var (
+	renderedBlockTags = map[string]bool{
+		`address`: true, `article`: true, `aside`: true, `blockquote`: true, `body`: true, `caption`: true,
+		`center`: true, `dd`: true, `details`: true, `dialog`: true, `dir`: true, `div`: true, `dl`: true, `dt`: true,
+		`fieldset`: true, `figcaption`: true, `figure`: true, `footer`: true, `form`: true, `h1`: true, `h2`: true,
+		`h3`: true, `h4`: true, `h5`: true, `h6`: true, `header`: true, `hgroup`: true, `hr`: true, `html`: true,
+		`legend`: true, `li`: true, `listing`: true, `main`: true, `menu`: true, `nav`: true, `ol`: true,
+		`optgroup`: true, `option`: true, `plaintext`: true, `pre`: true, `search`: true, `section`: true,
+		`summary`: true, `table`: true, `ul`: true, `xmp`: true,
+	}
+
+	renderedSkipTags = map[string]bool{
+		`area`: true, `base`: true, `datalist`: true, `head`: true, `iframe`: true, `link`: true, `meta`: true,
+		`noembed`: true, `noframes`: true, `noscript`: true, `param`: true, `rp`: true, `script`: true, `style`: true,
+		`template`: true, `textarea`: true, `title`: true,
+	}
+
+	renderedSpaces = regexp.MustCompile(`[\t\n\f\r ]+`)
+	renderedTabs   = regexp.MustCompile(`[\t ]+`)
+	renderedLines  = regexp.MustCompile(`[\t ]*\n[\t ]*`)
+)
+
+// RenderedText approximates the `innerText` algorithm of the HTML standard, returning the text of the sub-tree as it
+// would be rendered by a browser (without any CSS other than inline styles).
+//
+// Whitespace is collapsed, unless preserved by a pre (or similar) element, or a `white-space` inline style. Block
+// level elements (e.g. div, li, h1) are separated by a line break, p elements by a blank line, and br elements are
+// line breaks. Table cells are separated by tabs, and rows by line breaks. Elements that aren't rendered are skipped,
+// including script, style, template, head, and textarea elements, foreign content other than text, and any element
+// with a `hidden` attribute or a `display: none` inline style.
+func (n Node) RenderedText() string {
+	if n.Data == nil {
+		return ``
+	}
+
+	// the white-space property is inherited
+	whiteSpace := `normal`
+	for node := n.Data; node != nil; node = node.Parent {
+		if v := renderedWhiteSpace(node); v != `` {
+			whiteSpace = v
+			break
+		}
+	}
+
+	var r renderedText
+	if n.Data.Type == html.ElementNode || n.Data.Type == html.DocumentNode {
+		for child := n.Data.FirstChild; child != nil; child = child.NextSibling {
+			r.walk(child, whiteSpace)
+		}
+	} else {
+		r.walk(n.Data, whiteSpace)
+	}
+	return r.String()
+}
+
+// renderedWhiteSpace returns the white-space property, specified by node, if any
+func renderedWhiteSpace(node *html.Node) string {
+	if node.Type != html.ElementNode {
+		return ``
+	}
+	if v := renderedStyle(node, `white-space`); v != `` {
+		return v
+	}
+	if node.Namespace == `` {
+		switch node.Data {
+		case `pre`, `listing`, `plaintext`, `xmp`:
+			return `pre`
+		}
+	}
+	return ``
+}
+
+// renderedStyle returns the (lower case) value of a property from the inline style of node, if any
+func renderedStyle(node *html.Node, property string) (value string) {
+	for _, declaration := range strings.Split(getAttrVal(``, `style`, node.Attr...), `;`) {
+		if k, v, ok := strings.Cut(declaration, `:`); ok && strings.EqualFold(strings.TrimSpace(k), property) {
+			value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), `!important`)))
+		}
+	}
+	return
+}
+
+func (r *renderedText) walk(node *html.Node, whiteSpace string) {
+	switch node.Type {
+	case html.TextNode:
+		r.text(node.Data, whiteSpace)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if _, ok := getAttr(``, `hidden`, node.Attr...); ok {
+		return
+	}
+	display := renderedStyle(node, `display`)
+	if display == `none` {
+		return
+	}
+	if v := renderedWhiteSpace(node); v != `` {
+		whiteSpace = v
+	}
+
+	if node.Namespace != `` {
+		// foreign content (e.g. svg) is only rendered as text
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			r.walk(child, whiteSpace)
+		}
+		return
+	}
+
+	if renderedSkipTags[node.Data] {
+		return
+	}
+
+	breaks := -1
+	switch {
+	case node.Data == `p`:
+		breaks = 2
+	case display == `block` || (display == `` && renderedBlockTags[node.Data]):
+		breaks = 1
+	case node.Data == `td` || node.Data == `th` || node.Data == `tr`:
+		breaks = 0
+	}
+	if breaks >= 0 {
+		r.items = append(r.items, renderedItem{breaks: breaks})
+	}
+
+	if node.Data == `br` {
+		r.items = append(r.items, renderedItem{text: "\n", preserve: true, breaks: -1})
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		r.walk(child, whiteSpace)
+	}
+
+	if breaks >= 0 {
+		r.items = append(r.items, renderedItem{breaks: breaks})
+	}
+	switch node.Data {
+	case `td`, `th`:
+		if !renderedLastCell(node) {
+			r.items = append(r.items, renderedItem{text: "\t", preserve: true, breaks: -1})
+		}
+	case `tr`:
+		if !renderedLastRow(node) {
+			r.items = append(r.items, renderedItem{text: "\n", preserve: true, breaks: -1})
+		}
+	}
+}
+
+// text adds the text of a text node, processing whitespace as per the white-space property
+func (r *renderedText) text(s string, whiteSpace string) {
+	switch whiteSpace {
+	case `pre`, `pre-wrap`, `break-spaces`:
+		r.items = append(r.items, renderedItem{text: s, preserve: true, breaks: -1})
+	case `pre-line`:
+		s = renderedLines.ReplaceAllString(s, "\n")
+		r.items = append(r.items, renderedItem{text: renderedTabs.ReplaceAllString(s, ` `), breaks: -1})
+	default:
+		r.items = append(r.items, renderedItem{text: renderedSpaces.ReplaceAllString(s, ` `), breaks: -1})
+	}
+}
+
+func renderedLastCell(node *html.Node) bool {
+	for sibling := node.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode && sibling.Namespace == `` && (sibling.Data == `td` || sibling.Data == `th`) {
+			return false
+		}
+	}
+	return true
+}
+
+func renderedLastRow(node *html.Node) bool {
+	for sibling := node.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode && sibling.Namespace == `` && sibling.Data == `tr` {
+			return false
+		}
+	}
+	if parent := node.Parent; parent != nil && parent.Type == html.ElementNode && parent.Namespace == `` {
+		switch parent.Data {
+		case `thead`, `tbody`, `tfoot`:
+			for section := parent.NextSibling; section != nil; section = section.NextSibling {
+				if section.Type != html.ElementNode || section.Namespace != `` {
+					continue
+				}
+				switch section.Data {
+				case `thead`, `tbody`, `tfoot`:
+					for row := section.FirstChild; row != nil; row = row.NextSibling {
+						if row.Type == html.ElementNode && row.Namespace == `` && row.Data == `tr` {
+							return false
+						}
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// String joins the items, as per the innerText algorithm, where collapsible whitespace is discarded at the start and
+// end of each line
+func (r *renderedText) String() string {
+	var (
+		b         strings.Builder
+		breaks    int
+		space     bool
+		lineStart = true
+	)
+	for _, item := range r.items {
+		if item.breaks >= 0 {
+			breaks = max(breaks, item.breaks)
+			space = false
+			lineStart = true
+			continue
+		}
+		text := item.text
+		if text == `` {
+			continue
+		}
+		if !item.preserve {
+			if strings.HasPrefix(text, ` `) {
+				space = space || !lineStart
+				text = text[1:]
+			}
+			if text == `` {
+				continue
+			}
+		}
+		if breaks > 0 && b.Len() != 0 {
+			b.WriteString(strings.Repeat("\n", breaks))
+		}
+		breaks = 0
+		if space && !(item.preserve && strings.HasPrefix(text, "\n")) {
+			b.WriteByte(' ')
+		}
+		space = false
+		if !item.preserve && strings.HasSuffix(text, ` `) {
+			text = text[:len(text)-1]
+			space = true
+		}
+		b.WriteString(text)
+		lineStart = strings.HasSuffix(text, "\n") || strings.HasSuffix(text, "\t")
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"testing"
+)
+
+func TestNode_RenderedText(t *testing.T) {
+	for _, testCase := range []struct {
+		Name   string
+		Input  string
+		Output string
+	}{
+		{
+			Name:   `blocks and paragraphs`,
+			Input:  "<head><title>T</title><style>p {}</style></head><body>\n  <h1> Title </h1>\n<p>One\n  <b>two</b> </p><p> three<br> four </p><div>five<div>six</div></div>seven <span> </span> eight</body>",
+			Output: "Title\n\nOne two\n\nthree\nfour\n\nfive\nsix\nseven eight",
+		},
+		{
+			Name:   `lists`,
+			Input:  `<ul><li>one</li> <li>two <ul><li>nested</li></ul></li></ul>`,
+			Output: "one\ntwo\nnested",
+		},
+		{
+			Name:   `pre`,
+			Input:  "<p>before</p><pre>\n  line one\n\tline two\n</pre><div style=\"white-space: pre-line\">  a   b\n   c  </div><span style=\"white-space:pre\">  x  </span>",
+			Output: "before\n\n  line one\n\tline two\n\na b\nc\n  x  ",
+		},
+		{
+			Name:   `tables`,
+			Input:  `<table><caption>Cap</caption><thead><tr><th> A </th><th>B</th></tr></thead><tbody><tr><td>1</td><td>2</td></tr><tr><td>3</td></tr></tbody><tfoot></tfoot></table>after`,
+			Output: "Cap\nA\tB\n1\t2\n3\nafter",
+		},
+		{
+			Name:   `skipped`,
+			Input:  `<div>a<script>b</script><style>c</style><template>d</template><span hidden>e</span><span style="color: red; display: none !important">f</span><textarea>g</textarea><noscript>h</noscript><svg><text>i</text></svg><div style="display:block" hidden>j</div><span style="display: block">k</span></div>`,
+			Output: "ai\nk",
+		},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if v := parse(testCase.Input).RenderedText(); v != testCase.Output {
+				t.Errorf("unexpected output:\n%q\n%q", v, testCase.Output)
+			}
+		})
+	}
+}
+
+func TestNode_RenderedText_receiver(t *testing.T) {
+	doc := parse("<pre>a  <span> b\n c </span></pre><p>x</p><p>  y  z </p>")
+	if v := doc.GetNode(Tag(`span`)).RenderedText(); v != " b\n c " {
+		t.Errorf("%q", v)
+	}
+	if v := doc.GetNode(Tag(`span`)).FirstChild().RenderedText(); v != " b\n c " {
+		t.Errorf("%q", v)
+	}
+	if v := doc.GetNode(Tag(`p`)).RenderedText(); v != `x` {
+		t.Errorf("%q", v)
+	}
+	if v := doc.GetNode(Tag(`p`)).NextSibling().FirstChild().RenderedText(); v != `y z` {
+		t.Errorf("%q", v)
+	}
+	if v := (Node{}).RenderedText(); v != `` {
+		t.Errorf("%q", v)
+	}
+}
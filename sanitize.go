@@ -0,0 +1,289 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+)
+
+const (
+	// SanitizeUnwrap replaces a disallowed element with its (sanitized) children
+	SanitizeUnwrap SanitizeAction = iota
+	// SanitizeDrop removes a disallowed element, along with its children
+	SanitizeDrop
+)
+
+type (
+	// SanitizeAction is the handling of a disallowed element, see `Policy`
+	SanitizeAction int
+
+	// Policy is an allowlist for `Sanitize`, where the zero value allows only text, note that tag names, attribute
+	// keys, and url schemes are case insensitive
+	Policy struct {
+		// Elements maps each allowed (html) element, by tag name, to the attributes allowed for that element
+		Elements map[string][]string
+		// GlobalAttrs are attributes allowed for every allowed element
+		GlobalAttrs []string
+		// URLSchemes are the schemes allowed for absolute urls (e.g. "https"), in url attributes (e.g. href, src, and
+		// each candidate of a srcset), which are removed if they aren't allowed, or can't be parsed
+		URLSchemes []string
+		// AllowRelativeURLs allows urls without a scheme, in url attributes
+		AllowRelativeURLs bool
+		// NoFollow adds "nofollow" to the rel attribute of a and area elements with a href
+		NoFollow bool
+		// AllowComments retains comment nodes
+		AllowComments bool
+		// Disallowed is the default handling of disallowed elements, note that elements with raw text content (e.g.
+		// script and style) are always dropped, if they are disallowed, including within foreign content (e.g. svg)
+		Disallowed SanitizeAction
+		// Actions overrides the handling of specific disallowed elements, by tag name
+		Actions map[string]SanitizeAction
+	}
+
+	sanitizer struct {
+		policy   Policy
+		elements map[string]map[string]bool
+		global   map[string]bool
+		schemes  map[string]bool
+		actions  map[string]SanitizeAction
+	}
+)
+
+var (
+	// sanitizeURLAttrs are attributes containing a url
+	sanitizeURLAttrs = map[string]bool{
+		`action`: true, `background`: true, `cite`: true, `codebase`: true, `data`: true, `formaction`: true,
+		`href`: true, `icon`: true, `longdesc`: true, `manifest`: true, `poster`: true, `src`: true, `usemap`: true,
+	}
+
+	// sanitizeRawTags are elements that are always dropped if they are disallowed, as their content is not html
+	sanitizeRawTags = map[string]bool{
+		`iframe`: true, `noembed`: true, `noframes`: true, `noscript`: true, `plaintext`: true, `script`: true,
+		`style`: true, `template`: true, `textarea`: true, `title`: true, `xmp`: true,
+	}
+)
+
+// UGCPolicy returns a policy suitable for user generated content, allowing common formatting elements, links and
+// images (with http, https, mailto or relative urls), and tables, adding rel="nofollow" to links, and unwrapping
+// any other elements
+func UGCPolicy() Policy {
+	return Policy{
+		Elements: map[string][]string{
+			`a`: {`href`}, `abbr`: nil, `b`: nil, `blockquote`: {`cite`}, `br`: nil, `caption`: nil, `cite`: nil,
+			`code`: nil, `dd`: nil, `del`: {`cite`, `datetime`}, `dfn`: nil, `div`: nil, `dl`: nil, `dt`: nil,
+			`em`: nil, `figcaption`: nil, `figure`: nil, `h1`: nil, `h2`: nil, `h3`: nil, `h4`: nil, `h5`: nil,
+			`h6`: nil, `hr`: nil, `i`: nil, `img`: {`src`, `alt`, `width`, `height`}, `ins`: {`cite`, `datetime`},
+			`kbd`: nil, `li`: nil, `mark`: nil, `ol`: {`start`, `reversed`}, `p`: nil, `pre`: nil, `q`: {`cite`},
+			`s`: nil, `samp`: nil, `small`: nil, `span`: nil, `strong`: nil, `sub`: nil, `sup`: nil, `table`: nil,
+			`tbody`: nil, `td`: {`colspan`, `rowspan`}, `tfoot`: nil, `th`: {`colspan`, `rowspan`, `scope`},
+			`thead`: nil, `time`: {`datetime`}, `tr`: nil, `u`: nil, `ul`: nil, `var`: nil,
+		},
+		GlobalAttrs:       []string{`dir`, `lang`, `title`},
+		URLSchemes:        []string{`http`, `https`, `mailto`},
+		AllowRelativeURLs: true,
+		NoFollow:          true,
+	}
+}
+
+// Sanitize returns a sanitized copy of the sub-tree of node (which is not modified), retaining only the elements and
+// attributes allowed by the policy, and text (and comments, if allowed). Foreign elements (e.g. svg) and namespaced
+// attributes are never allowed, and note that the values of attributes other than urls (e.g. style) are retained as
+// is, if they are allowed.
+//
+// The result is a detached tree, which may be rendered using `Node.OuterHTML`. If node is a disallowed element, then
+// the result is either empty (if dropped), or a document node containing the sanitized children (if unwrapped).
+func Sanitize(node Node, policy Policy) Node {
+	if node.Data == nil {
+		return Node{}
+	}
+
+	s := sanitizer{
+		policy:   policy,
+		elements: make(map[string]map[string]bool),
+		global:   sanitizeSet(policy.GlobalAttrs),
+		schemes:  sanitizeSet(policy.URLSchemes),
+		actions:  make(map[string]SanitizeAction),
+	}
+	for tag, attrs := range policy.Elements {
+		s.elements[strings.ToLower(tag)] = sanitizeSet(attrs)
+	}
+	for tag, action := range policy.Actions {
+		s.actions[strings.ToLower(tag)] = action
+	}
+
+	if node.Data.Type == html.ElementNode {
+		if _, ok := s.element(node.Data); !ok {
+			if s.action(node.Data) == SanitizeDrop {
+				return Node{}
+			}
+			return Node{Data: s.copy(&html.Node{Type: html.DocumentNode}, node.Data)}
+		}
+	}
+	if nodes := s.nodes(node.Data); len(nodes) != 0 {
+		return Node{Data: nodes[0]}
+	}
+	return Node{}
+}
+
+func sanitizeSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToLower(v)] = true
+	}
+	return set
+}
+
+// nodes returns the sanitized copies of node, which will be either no nodes (dropped), a single node (allowed), or
+// the sanitized children of node (unwrapped)
+func (s *sanitizer) nodes(node *html.Node) []*html.Node {
+	switch node.Type {
+	case html.TextNode, html.DoctypeNode:
+		return []*html.Node{{Type: node.Type, Data: node.Data, Attr: append([]html.Attribute(nil), node.Attr...)}}
+
+	case html.CommentNode:
+		if s.policy.AllowComments {
+			return []*html.Node{{Type: node.Type, Data: node.Data}}
+		}
+		return nil
+
+	case html.DocumentNode:
+		return []*html.Node{s.copy(&html.Node{Type: html.DocumentNode}, node)}
+
+	case html.ElementNode:
+		attrs, ok := s.element(node)
+		if ok {
+			return []*html.Node{s.copy(&html.Node{
+				Type:      html.ElementNode,
+				DataAtom:  node.DataAtom,
+				Data:      node.Data,
+				Namespace: node.Namespace,
+				Attr:      attrs,
+			}, node)}
+		}
+		if s.action(node) == SanitizeDrop {
+			return nil
+		}
+		var nodes []*html.Node
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			nodes = append(nodes, s.nodes(child)...)
+		}
+		return nodes
+
+	default:
+		return nil
+	}
+}
+
+// copy appends the sanitized children of node to parent, returning parent
+func (s *sanitizer) copy(parent *html.Node, node *html.Node) *html.Node {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		for _, v := range s.nodes(child) {
+			parent.AppendChild(v)
+		}
+	}
+	return parent
+}
+
+// element returns the allowed attributes of an element, or false if the element is not allowed
+func (s *sanitizer) element(node *html.Node) ([]html.Attribute, bool) {
+	if node.Namespace != `` {
+		return nil, false
+	}
+	allowed, ok := s.elements[node.Data]
+	if !ok {
+		return nil, false
+	}
+
+	var attrs []html.Attribute
+	for _, attr := range node.Attr {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != `` || (!allowed[key] && !s.global[key]) {
+			continue
+		}
+		if sanitizeURLAttrs[key] && !s.url(attr.Val) {
+			continue
+		}
+		if key == `srcset` && !s.srcset(attr.Val) {
+			continue
+		}
+		attrs = append(attrs, html.Attribute{Key: key, Val: attr.Val})
+	}
+
+	if s.policy.NoFollow && (node.Data == `a` || node.Data == `area`) {
+		if _, ok := getAttr(``, `href`, attrs...); ok {
+			rel := -1
+			for i := range attrs {
+				if attrs[i].Key == `rel` {
+					rel = i
+				}
+			}
+			if rel == -1 {
+				attrs = append(attrs, html.Attribute{Key: `rel`, Val: `nofollow`})
+			} else if values := strings.Fields(strings.ToLower(attrs[rel].Val)); !sanitizeContains(values, `nofollow`) {
+				attrs[rel].Val = strings.Join(append(values, `nofollow`), ` `)
+			}
+		}
+	}
+
+	return attrs, true
+}
+
+func sanitizeContains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sanitizer) action(node *html.Node) SanitizeAction {
+	// including foreign elements (e.g. svg script), which would otherwise be unwrapped, leaking their content as text
+	if sanitizeRawTags[strings.ToLower(node.Data)] {
+		return SanitizeDrop
+	}
+	if action, ok := s.actions[node.Data]; ok && node.Namespace == `` {
+		return action
+	}
+	return s.policy.Disallowed
+}
+
+// url returns true if the url is allowed by the policy, where (like browsers) leading and trailing control characters
+// and spaces, and any tabs or newlines, are ignored
+func (s *sanitizer) url(v string) bool {
+	v = strings.TrimFunc(v, func(r rune) bool { return r <= ' ' })
+	v = strings.NewReplacer("\t", ``, "\n", ``, "\r", ``).Replace(v)
+	u, err := url.Parse(v)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == `` {
+		return s.policy.AllowRelativeURLs
+	}
+	return s.schemes[strings.ToLower(u.Scheme)]
+}
+
+func (s *sanitizer) srcset(v string) bool {
+	for _, ref := range linkSrcset(v) {
+		if !s.url(ref) {
+			return false
+		}
+	}
+	return true
+}
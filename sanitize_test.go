@@ -0,0 +1,113 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"golang.org/x/net/html"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	for _, testCase := range []struct {
+		Name   string
+		Input  string
+		Policy Policy
+		Output string
+	}{
+		{
+			Name:   `ugc`,
+			Input:  `<div class="x" onclick="evil()"><p>Hello <b>world</b><script>alert(1)</script><style>p{}</style><custom>unwrapped <i>text</i></custom></p><!-- comment --><a href="https://example.com" rel="Author">link</a><a href=" JAVA&#x09;SCRIPT:alert(1)">js</a><a>no href</a><img src="/x.png" srcset="a.png 1x, javascript:x 2x" alt="alt"><svg><a href="/y">svg</a></svg><iframe src="https://example.com"></iframe></div>`,
+			Policy: UGCPolicy(),
+			Output: `<div><p>Hello <b>world</b>unwrapped <i>text</i></p><a href="https://example.com" rel="nofollow">link</a><a>js</a><a>no href</a><img src="/x.png" alt="alt"/>svg</div>`,
+		},
+		{
+			Name:   `foreign raw text`,
+			Input:  `<p>hi<svg><script>alert(1)</script><style>x{}</style><text>svg</text></svg><math><mi>x</mi><style>y{}</style></math>evil</p>`,
+			Policy: UGCPolicy(),
+			Output: `<p>hisvgxevil</p>`,
+		},
+		{
+			Name:  `rel and schemes`,
+			Input: `<a href="mailto:a@example.com" rel="external NOFOLLOW" title="t">a</a><a href="ftp://example.com" rel="x">b</a><a href="/relative">c</a><a href="http://[::1">d</a>`,
+			Policy: Policy{
+				Elements:   map[string][]string{`A`: {`HREF`, `rel`}},
+				URLSchemes: []string{`MAILTO`, `ftp`},
+				NoFollow:   true,
+			},
+			Output: `<a href="mailto:a@example.com" rel="external NOFOLLOW">a</a><a href="ftp://example.com" rel="x nofollow">b</a><a>c</a><a>d</a>`,
+		},
+		{
+			Name:  `drop`,
+			Input: `<p>keep <span>drop</span> <em>unwrap</em><script>x</script></p><!--c-->`,
+			Policy: Policy{
+				Elements:      map[string][]string{`p`: nil},
+				Disallowed:    SanitizeDrop,
+				Actions:       map[string]SanitizeAction{`body`: SanitizeUnwrap, `em`: SanitizeUnwrap, `script`: SanitizeUnwrap},
+				AllowComments: true,
+			},
+			Output: `<p>keep  unwrap</p><!--c-->`,
+		},
+		{
+			Name:   `zero policy`,
+			Input:  `<p>a &lt;b&gt; <b>c</b></p>`,
+			Output: `a &lt;b&gt; c`,
+		},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			doc := parse(testCase.Input)
+			before := doc.OuterHTML()
+			result := Sanitize(doc.GetNode(Tag(`body`)), testCase.Policy)
+			if result.Data.Type != html.DocumentNode || result.Data.Parent != nil {
+				t.Fatal(result.Data)
+			}
+			if v := result.InnerHTML(); v != testCase.Output {
+				t.Errorf("unexpected output:\n%s\n%s", v, testCase.Output)
+			}
+			if doc.OuterHTML() != before {
+				t.Error(`input was modified`)
+			}
+		})
+	}
+}
+
+func TestSanitize_root(t *testing.T) {
+	policy := Policy{Elements: map[string][]string{`p`: nil, `html`: nil, `body`: nil}}
+	doc := parse(`<p id="x">a<span>b</span></p><div>c</div>`)
+
+	if v := Sanitize(doc.GetNode(Tag(`p`)), policy); v.Data.Parent != nil || v.OuterHTML() != `<p>ab</p>` {
+		t.Error(v)
+	}
+	if v := Sanitize(doc.GetNode(Tag(`div`)), policy); v.Data.Type != html.DocumentNode || v.OuterHTML() != `c` {
+		t.Error(v)
+	}
+	policy.Disallowed = SanitizeDrop
+	if v := Sanitize(doc.GetNode(Tag(`div`)), policy); v.Data != nil {
+		t.Error(v)
+	}
+	if v := Sanitize(doc, policy); v.Data.Type != html.DocumentNode || v.OuterHTML() != `<html><body><p>a</p></body></html>` {
+		t.Error(v)
+	}
+	if v := Sanitize(doc.GetNode(Tag(`div`)).FirstChild(), policy); v.Data.Type != html.TextNode || v.OuterHTML() != `c` {
+		t.Error(v)
+	}
+	if v := Sanitize(parse(`<!--x-->`).FirstChild(), policy); v.Data != nil {
+		t.Error(v)
+	}
+	if v := Sanitize(Node{}, policy); v.Data != nil {
+		t.Error(v)
+	}
+}
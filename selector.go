@@ -0,0 +1,724 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	// SelectorError is the error returned by Compile for a selector that could not be parsed, where Column is the
+	// (1-based) position, in runes, of the offending token
+	SelectorError struct {
+		Selector string
+		Column   int
+		Reason   string
+	}
+
+	selectorParser struct {
+		input []rune
+		pos   int
+	}
+
+	// selectorCompound is a compound selector (e.g. `a.b[c]`), optionally chained to a previous sibling compound via
+	// either the `+` or `~` combinator
+	selectorCompound struct {
+		match      func(node Node) bool
+		combinator rune
+		prev       *selectorCompound
+	}
+
+	// selectorStep is one part of a complex selector, split on the descendant and child combinators
+	selectorStep struct {
+		child    bool
+		compound *selectorCompound
+	}
+)
+
+// Compile parses a CSS Selectors Level 3 string, returning a filter chain usable with any of the variadic filter
+// methods / functions of this package, note that the descendant (` `) and child (`>`) combinators are implemented as
+// separate filters (with the child combinator using `Node.Offset`), and the root node itself is treated as part of
+// the search, consistent with the package filter behavior
+//
+// Selector groups (comma separated) are supported, but compile to a single filter, combining the filter chain for
+// each complex selector using `AnyOf`.
+//
+// Pseudo-elements are not supported, and dynamic pseudo-classes such as `:hover` or `:visited` will never match.
+func Compile(selector string) ([]func(node Node) bool, error) {
+	p := selectorParser{input: []rune(selector)}
+
+	group, err := p.parseGroup()
+	if err != nil {
+		err.Selector = selector
+		return nil, err
+	}
+
+	chains := make([][]func(node Node) bool, len(group))
+	for i, steps := range group {
+		for _, step := range steps {
+			chains[i] = append(chains[i], step.filter())
+		}
+	}
+
+	if len(chains) == 1 {
+		return chains[0], nil
+	}
+
+	return []func(node Node) bool{AnyOf(chains...)}, nil
+}
+
+// MustCompile is like Compile but panics if the selector cannot be parsed
+func MustCompile(selector string) []func(node Node) bool {
+	filters, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return filters
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("htmlutil.Compile %s at column %d: %q", e.Reason, e.Column, e.Selector)
+}
+
+func (s selectorStep) filter() func(node Node) bool {
+	if s.child {
+		return func(node Node) bool {
+			return node.Offset() == 1 && s.compound.matches(node)
+		}
+	}
+	return s.compound.matches
+}
+
+func (c *selectorCompound) matches(node Node) bool {
+	if node.Type() != html.ElementNode || !c.match(node) {
+		return false
+	}
+	switch c.combinator {
+	case '+':
+		return c.prev.matches(prevElementSibling(node))
+	case '~':
+		for node = prevElementSibling(node); node.Data != nil; node = prevElementSibling(node) {
+			if c.prev.matches(node) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func prevElementSibling(node Node) Node {
+	for node = node.PrevSibling(); node.Data != nil && node.Data.Type != html.ElementNode; node = node.PrevSibling() {
+	}
+	return node
+}
+
+func nextElementSibling(node Node) Node {
+	for node = node.NextSibling(); node.Data != nil && node.Data.Type != html.ElementNode; node = node.NextSibling() {
+	}
+	return node
+}
+
+func (p *selectorParser) errorf(pos int, format string, args ...interface{}) *SelectorError {
+	return &SelectorError{
+		Column: pos + 1,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *selectorParser) peek() rune {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return utf8.RuneError
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && isSelectorSpace(p.peek()) {
+		p.pos++
+	}
+	return p.pos != start
+}
+
+func (p *selectorParser) unexpected() *SelectorError {
+	if p.eof() {
+		return p.errorf(p.pos, "unexpected end of selector")
+	}
+	return p.errorf(p.pos, "unexpected %q", p.peek())
+}
+
+func (p *selectorParser) parseGroup() ([][]selectorStep, *SelectorError) {
+	var group [][]selectorStep
+	for {
+		p.skipSpace()
+		steps, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, steps)
+		p.skipSpace()
+		if p.eof() {
+			return group, nil
+		}
+		if p.peek() != ',' {
+			return nil, p.unexpected()
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseSelector() ([]selectorStep, *SelectorError) {
+	compound, err := p.parseCompound()
+	if err != nil {
+		return nil, err
+	}
+	steps := []selectorStep{{}}
+	for {
+		space := p.skipSpace()
+		if p.eof() || p.peek() == ',' {
+			steps[len(steps)-1].compound = compound
+			return steps, nil
+		}
+		combinator := ' '
+		if r := p.peek(); r == '>' || r == '+' || r == '~' {
+			combinator = r
+			p.pos++
+			p.skipSpace()
+		} else if !space {
+			return nil, p.unexpected()
+		}
+		next, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		switch combinator {
+		case '+', '~':
+			next.combinator = combinator
+			next.prev = compound
+		default:
+			steps[len(steps)-1].compound = compound
+			steps = append(steps, selectorStep{child: combinator == '>'})
+		}
+		compound = next
+	}
+}
+
+func (p *selectorParser) parseCompound() (*selectorCompound, *SelectorError) {
+	var (
+		start = p.pos
+		tag   string
+		preds []func(node Node) bool
+	)
+
+	if p.peek() == '*' {
+		p.pos++
+	} else if isSelectorIdentStart(p.input[p.pos:]) {
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		tag = strings.ToLower(name)
+	}
+
+	if !p.eof() && p.peek() == '|' {
+		return nil, p.errorf(p.pos, "namespace prefixes are not supported")
+	}
+
+	for !p.eof() {
+		var (
+			pred func(node Node) bool
+			err  *SelectorError
+		)
+		switch p.peek() {
+		case '#':
+			p.pos++
+			var id string
+			if id, err = p.parseName(); err == nil {
+				pred = ID(id)
+			}
+		case '.':
+			p.pos++
+			var class string
+			if class, err = p.parseIdent(); err == nil {
+				pred = Class(class)
+			}
+		case '[':
+			pred, err = p.parseAttr()
+		case ':':
+			pred, err = p.parsePseudo()
+		default:
+			if p.pos == start {
+				return nil, p.unexpected()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pred == nil {
+			break
+		}
+		preds = append(preds, pred)
+	}
+
+	if p.pos == start {
+		return nil, p.unexpected()
+	}
+
+	return &selectorCompound{
+		match: func(node Node) bool {
+			if tag != `` && !strings.EqualFold(node.Data.Data, tag) {
+				return false
+			}
+			for _, pred := range preds {
+				if !pred(node) {
+					return false
+				}
+			}
+			return true
+		},
+	}, nil
+}
+
+func (p *selectorParser) parseAttr() (func(node Node) bool, *SelectorError) {
+	// consume '['
+	p.pos++
+	p.skipSpace()
+
+	if !p.eof() && (p.peek() == '|' || p.peek() == '*') {
+		return nil, p.errorf(p.pos, "namespace prefixes are not supported")
+	}
+
+	key, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() == '|' && p.pos+1 < len(p.input) && p.input[p.pos+1] != '=' {
+		return nil, p.errorf(p.pos, "namespace prefixes are not supported")
+	}
+
+	p.skipSpace()
+
+	if p.peek() == ']' {
+		p.pos++
+		return HasAttr(``, key), nil
+	}
+
+	var op string
+	if r := p.peek(); r == '=' {
+		op = `=`
+		p.pos++
+	} else if strings.ContainsRune(`~|^$*`, r) && p.pos+1 < len(p.input) && p.input[p.pos+1] == '=' {
+		op = string(r) + `=`
+		p.pos += 2
+	} else {
+		return nil, p.unexpected()
+	}
+
+	p.skipSpace()
+
+	var value string
+	if r := p.peek(); r == '"' || r == '\'' {
+		value, err = p.parseString()
+	} else {
+		value, err = p.parseIdent()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+
+	if p.peek() != ']' {
+		return nil, p.unexpected()
+	}
+	p.pos++
+
+	var match func(v string) bool
+	switch op {
+	case `=`:
+		match = func(v string) bool { return v == value }
+	case `~=`:
+		match = func(v string) bool {
+			for _, word := range strings.FieldsFunc(v, isSelectorSpace) {
+				if word == value {
+					return true
+				}
+			}
+			return false
+		}
+	case `|=`:
+		match = func(v string) bool { return v == value || strings.HasPrefix(v, value+`-`) }
+	case `^=`:
+		match = func(v string) bool { return value != `` && strings.HasPrefix(v, value) }
+	case `$=`:
+		match = func(v string) bool { return value != `` && strings.HasSuffix(v, value) }
+	default:
+		match = func(v string) bool { return value != `` && strings.Contains(v, value) }
+	}
+
+	return attrFilter(``, key, match), nil
+}
+
+func (p *selectorParser) parsePseudo() (func(node Node) bool, *SelectorError) {
+	start := p.pos
+
+	// consume ':'
+	p.pos++
+
+	if p.peek() == ':' {
+		return nil, p.errorf(start, "pseudo-elements are not supported")
+	}
+
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+
+	if p.peek() == '(' {
+		p.pos++
+		p.skipSpace()
+		var pred func(node Node) bool
+		switch name {
+		case `not`:
+			var compound *selectorCompound
+			if compound, err = p.parseCompound(); err == nil {
+				pred = func(node Node) bool {
+					return !compound.match(node)
+				}
+			}
+		case `lang`:
+			var lang string
+			if lang, err = p.parseIdent(); err == nil {
+				pred = func(node Node) bool {
+					return matchLang(node, lang)
+				}
+			}
+		case `nth-child`, `nth-last-child`, `nth-of-type`, `nth-last-of-type`:
+			pred, err = p.parseNth(name)
+		default:
+			return nil, p.errorf(start, "unsupported pseudo-class %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ')' {
+			return nil, p.unexpected()
+		}
+		p.pos++
+		return pred, nil
+	}
+
+	switch name {
+	case `first-child`:
+		return nthPseudo(0, 1, false, false), nil
+	case `last-child`:
+		return nthPseudo(0, 1, true, false), nil
+	case `first-of-type`:
+		return nthPseudo(0, 1, false, true), nil
+	case `last-of-type`:
+		return nthPseudo(0, 1, true, true), nil
+	case `only-child`:
+		return func(node Node) bool {
+			return prevElementSibling(node).Data == nil && nextElementSibling(node).Data == nil
+		}, nil
+	case `only-of-type`:
+		first, last := nthPseudo(0, 1, false, true), nthPseudo(0, 1, true, true)
+		return func(node Node) bool {
+			return first(node) && last(node)
+		}, nil
+	case `root`:
+		return func(node Node) bool {
+			return node.Data.Parent != nil && node.Data.Parent.Type == html.DocumentNode
+		}, nil
+	case `empty`:
+		return func(node Node) bool {
+			for c := node.Data.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode || (c.Type == html.TextNode && c.Data != ``) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case `link`, `any-link`:
+		return func(node Node) bool {
+			switch node.Data.Data {
+			case `a`, `area`, `link`:
+				_, ok := node.GetAttr(``, `href`)
+				return ok
+			}
+			return false
+		}, nil
+	case `enabled`:
+		return func(node Node) bool {
+			return isFormControl(node) && !isDisabled(node)
+		}, nil
+	case `disabled`:
+		return func(node Node) bool {
+			return isFormControl(node) && isDisabled(node)
+		}, nil
+	case `checked`:
+		return func(node Node) bool {
+			switch node.Data.Data {
+			case `input`:
+				switch strings.ToLower(node.GetAttrVal(``, `type`)) {
+				case `checkbox`, `radio`:
+					_, ok := node.GetAttr(``, `checked`)
+					return ok
+				}
+			case `option`:
+				_, ok := node.GetAttr(``, `selected`)
+				return ok
+			}
+			return false
+		}, nil
+	case `visited`, `hover`, `active`, `focus`, `target`:
+		return func(node Node) bool {
+			return false
+		}, nil
+	case `first-line`, `first-letter`, `before`, `after`:
+		return nil, p.errorf(start, "pseudo-elements are not supported")
+	default:
+		return nil, p.errorf(start, "unsupported pseudo-class %q", name)
+	}
+}
+
+// parseNth parses the `an+b` argument of the nth-* pseudo-classes
+func (p *selectorParser) parseNth(name string) (func(node Node) bool, *SelectorError) {
+	start := p.pos
+	for !p.eof() && p.peek() != ')' {
+		p.pos++
+	}
+	var (
+		expr = strings.ToLower(strings.Join(strings.FieldsFunc(string(p.input[start:p.pos]), isSelectorSpace), ``))
+		a, b int
+		err  error
+	)
+	switch expr {
+	case `odd`:
+		a, b = 2, 1
+	case `even`:
+		a, b = 2, 0
+	default:
+		if i := strings.IndexByte(expr, 'n'); i == -1 {
+			b, err = strconv.Atoi(expr)
+		} else {
+			switch s := expr[:i]; s {
+			case ``, `+`:
+				a = 1
+			case `-`:
+				a = -1
+			default:
+				a, err = strconv.Atoi(s)
+			}
+			if s := expr[i+1:]; err == nil && s != `` {
+				if s[0] != '+' && s[0] != '-' {
+					err = strconv.ErrSyntax
+				} else {
+					b, err = strconv.Atoi(s)
+				}
+			}
+		}
+	}
+	if err != nil || expr == `` {
+		return nil, p.errorf(start, "invalid %s argument %q", name, string(p.input[start:p.pos]))
+	}
+	return nthPseudo(a, b, strings.Contains(name, `last`), strings.HasSuffix(name, `of-type`)), nil
+}
+
+// nthPseudo builds a predicate matching elements with a (1-based) sibling index that satisfies `a*n + b` for some
+// n >= 0, where last counts from the last sibling, and ofType counts only siblings with the same tag
+func nthPseudo(a, b int, last bool, ofType bool) func(node Node) bool {
+	return func(node Node) bool {
+		if node.Data.Parent == nil {
+			return false
+		}
+		index := 1
+		for sibling := node; ; index++ {
+			if last {
+				sibling = nextElementSibling(sibling)
+			} else {
+				sibling = prevElementSibling(sibling)
+			}
+			if sibling.Data == nil {
+				break
+			}
+			if ofType && sibling.Data.Data != node.Data.Data {
+				index--
+			}
+		}
+		if a == 0 {
+			return index == b
+		}
+		n := index - b
+		return n%a == 0 && n/a >= 0
+	}
+}
+
+func matchLang(node Node, lang string) bool {
+	for ; node.Data != nil; node = node.Parent() {
+		if attr, ok := node.GetAttr(``, `lang`); ok {
+			v := strings.ToLower(attr.Val)
+			lang = strings.ToLower(lang)
+			return v == lang || strings.HasPrefix(v, lang+`-`)
+		}
+	}
+	return false
+}
+
+func isFormControl(node Node) bool {
+	switch node.Data.Data {
+	case `button`, `input`, `select`, `textarea`, `optgroup`, `option`, `fieldset`:
+		return node.Data.Namespace == ``
+	}
+	return false
+}
+
+func isDisabled(node Node) bool {
+	_, ok := node.GetAttr(``, `disabled`)
+	return ok
+}
+
+func (p *selectorParser) parseIdent() (string, *SelectorError) {
+	if !isSelectorIdentStart(p.input[p.pos:]) {
+		return ``, p.unexpected()
+	}
+	return p.parseName()
+}
+
+// parseName parses a (non-empty) sequence of name characters, e.g. as used by id selectors
+func (p *selectorParser) parseName() (string, *SelectorError) {
+	var b strings.Builder
+	for !p.eof() {
+		r := p.peek()
+		if r == '\\' {
+			v, err := p.parseEscape()
+			if err != nil {
+				return ``, err
+			}
+			b.WriteString(v)
+			continue
+		}
+		if !isSelectorNameChar(r) {
+			break
+		}
+		b.WriteRune(r)
+		p.pos++
+	}
+	if b.Len() == 0 {
+		return ``, p.unexpected()
+	}
+	return b.String(), nil
+}
+
+func (p *selectorParser) parseString() (string, *SelectorError) {
+	start := p.pos
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return ``, p.errorf(start, "unterminated string")
+		}
+		switch r := p.peek(); r {
+		case quote:
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == '\n' {
+				p.pos += 2
+				continue
+			}
+			v, err := p.parseEscape()
+			if err != nil {
+				return ``, err
+			}
+			b.WriteString(v)
+		case '\n':
+			return ``, p.errorf(p.pos, "unterminated string")
+		default:
+			b.WriteRune(r)
+			p.pos++
+		}
+	}
+}
+
+func (p *selectorParser) parseEscape() (string, *SelectorError) {
+	start := p.pos
+	// consume '\\'
+	p.pos++
+	if p.eof() || p.peek() == '\n' {
+		return ``, p.errorf(start, "invalid escape")
+	}
+	hex := p.pos
+	for p.pos < len(p.input) && p.pos-hex < 6 && isHexDigit(p.peek()) {
+		p.pos++
+	}
+	if p.pos == hex {
+		r := p.peek()
+		p.pos++
+		return string(r), nil
+	}
+	v, _ := strconv.ParseUint(string(p.input[hex:p.pos]), 16, 32)
+	if !p.eof() && isSelectorSpace(p.peek()) {
+		p.pos++
+	}
+	if r := rune(v); r != 0 && utf8.ValidRune(r) {
+		return string(r), nil
+	}
+	return string(unicode.ReplacementChar), nil
+}
+
+func isSelectorIdentStart(input []rune) bool {
+	if len(input) != 0 && input[0] == '-' {
+		input = input[1:]
+	}
+	if len(input) == 0 {
+		return false
+	}
+	r := input[0]
+	return r == '_' || r == '\\' || r == '-' || r >= 0x80 || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isSelectorNameChar(r rune) bool {
+	return r == '_' || r == '-' || r >= 0x80 || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func isSelectorSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
+
+func isHexDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
@@ -0,0 +1,192 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	const input = `<div id="main" class="content wide" lang="en-AU">
+	<p class="intro">one</p>
+	<div class="item"><a href="/1">1</a><span><a href="/2">2</a></span></div>
+	<div class="item"><a>3</a></div>
+	<ul><li>a</li><li class="x">b</li><li>c</li><li>d</li><li class="x">e</li></ul>
+	<p data-v="foo-bar baz">two</p><span>after</span><em>em</em>
+	<form><input type="checkbox" checked/><input disabled/><select><option selected>o</option></select></form>
+	<section></section>
+</div>`
+	type TestCase struct {
+		Selector string
+		Output   []string
+	}
+	testCases := []TestCase{
+		{
+			Selector: `div.item > a[href]`,
+			Output:   []string{`<a href="/1">1</a>`},
+		},
+		{
+			Selector: `div.item a[href]`,
+			Output:   []string{`<a href="/1">1</a>`, `<a href="/2">2</a>`},
+		},
+		{
+			Selector: `#main > p`,
+			Output:   []string{`<p class="intro">one</p>`, `<p data-v="foo-bar baz">two</p>`},
+		},
+		{
+			Selector: `DIV.item:not([class~=x]) A:first-child`,
+			Output:   []string{`<a href="/1">1</a>`, `<a href="/2">2</a>`, `<a>3</a>`},
+		},
+		{
+			Selector: `li:nth-child(2n+1)`,
+			Output:   []string{`<li>a</li>`, `<li>c</li>`, `<li class="x">e</li>`},
+		},
+		{
+			Selector: `li:nth-last-child( -n + 2 )`,
+			Output:   []string{`<li>d</li>`, `<li class="x">e</li>`},
+		},
+		{
+			Selector: `li.x + li`,
+			Output:   []string{`<li>c</li>`},
+		},
+		{
+			Selector: `li.x ~ li.x`,
+			Output:   []string{`<li class="x">e</li>`},
+		},
+		{
+			Selector: `p ~ span`,
+			Output:   []string{`<span>after</span>`},
+		},
+		{
+			Selector: `[data-v|=foo]`,
+			Output:   []string{`<p data-v="foo-bar baz">two</p>`},
+		},
+		{
+			Selector: `[data-v^="foo-"][data-v$='baz'][data-v*=r\ b]`,
+			Output:   []string{`<p data-v="foo-bar baz">two</p>`},
+		},
+		{
+			Selector: `p:last-of-type, em:only-of-type`,
+			Output:   []string{`<p data-v="foo-bar baz">two</p>`, `<em>em</em>`},
+		},
+		{
+			Selector: `:checked`,
+			Output:   []string{`<input type="checkbox" checked=""/>`, `<option selected="">o</option>`},
+		},
+		{
+			Selector: `input:disabled`,
+			Output:   []string{`<input disabled=""/>`},
+		},
+		{
+			Selector: `section:empty:lang(en)`,
+			Output:   []string{`<section></section>`},
+		},
+		{
+			Selector: `a:link:hover`,
+			Output:   nil,
+		},
+		{
+			Selector: `.\63 ontent > ul > li:nth-of-type(2)`,
+			Output:   []string{`<li class="x">b</li>`},
+		},
+	}
+	root := parse(input)
+	for i, testCase := range testCases {
+		name := fmt.Sprintf("Compile_#%d", i+1)
+		filters, err := Compile(testCase.Selector)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		var output []string
+		for _, node := range root.FilterNodes(filters...) {
+			output = append(output, node.OuterHTML())
+		}
+		if diff := deep.Equal(
+			output,
+			testCase.Output,
+		); diff != nil {
+			t.Error(strings.Join(append([]string{name + " output diff:"}, diff...), "    \n"))
+		}
+	}
+}
+
+func TestCompile_root(t *testing.T) {
+	if v := parse(`<p>a</p>`, MustCompile(`:root > body > p`)...).OuterHTML(); v != `<p>a</p>` {
+		t.Error(v)
+	}
+	if v := parse(`<p>a</p>`, MustCompile(`:root`)...).Tag(); v != `html` {
+		t.Error(v)
+	}
+}
+
+func TestCompile_groupScope(t *testing.T) {
+	node := parse(`<div><p><b>1</b></p></div>`, MustCompile(`p`)...)
+	if v := node.FilterNodes(MustCompile(`div b, i`)...); len(v) != 0 {
+		t.Error(v)
+	}
+	if v := node.FilterNodes(MustCompile(`div b`)...); len(v) != 0 {
+		t.Error(v)
+	}
+	if v := node.FilterNodes(MustCompile(`p > b, i`)...); len(v) != 1 || v[0].OuterHTML() != `<b>1</b>` {
+		t.Error(v)
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	type TestCase struct {
+		Selector string
+		Column   int
+		Error    string
+	}
+	testCases := []TestCase{
+		{``, 1, `htmlutil.Compile unexpected end of selector at column 1: ""`},
+		{`div >`, 6, `htmlutil.Compile unexpected end of selector at column 6: "div >"`},
+		{`div, ,p`, 6, `htmlutil.Compile unexpected ',' at column 6: "div, ,p"`},
+		{`a[href`, 7, `htmlutil.Compile unexpected end of selector at column 7: "a[href"`},
+		{`a[href="x]`, 8, `htmlutil.Compile unterminated string at column 8: "a[href=\"x]"`},
+		{`p::before`, 2, `htmlutil.Compile pseudo-elements are not supported at column 2: "p::before"`},
+		{`p:nth-child(x)`, 13, `htmlutil.Compile invalid nth-child argument "x" at column 13: "p:nth-child(x)"`},
+		{`p:unknown`, 2, `htmlutil.Compile unsupported pseudo-class "unknown" at column 2: "p:unknown"`},
+		{`svg|a`, 4, `htmlutil.Compile namespace prefixes are not supported at column 4: "svg|a"`},
+		{`é.1`, 3, `htmlutil.Compile unexpected '1' at column 3: "é.1"`},
+	}
+	for i, testCase := range testCases {
+		_, err := Compile(testCase.Selector)
+		e, ok := err.(*SelectorError)
+		if !ok {
+			t.Fatal(i, err)
+		}
+		if e.Column != testCase.Column {
+			t.Error(i, e.Column)
+		}
+		if v := e.Error(); v != testCase.Error {
+			t.Error(i, v)
+		}
+	}
+}
+
+func TestMustCompile_panic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic")
+		}
+	}()
+	MustCompile(`>`)
+}
@@ -0,0 +1,654 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"strings"
+)
+
+type (
+	streamer struct {
+		filters []func(node Node) bool
+		fn      func(node Node) bool
+		stack   []streamEntry
+		// captures is the number of entries in the stack that are the root of a matched sub-tree (at most one)
+		captures int
+		// pending are the matched nodes that are waiting for the outermost sub-tree to be completed
+		pending []Node
+		text    strings.Builder
+		html    bool
+		head    int
+		body    bool
+		stopped bool
+	}
+
+	streamEntry struct {
+		node Node
+		// states are the filter states that apply to children of the node
+		states  []streamState
+		capture bool
+		started bool
+	}
+
+	streamState struct {
+		index int
+		match *Node
+	}
+)
+
+const (
+	streamHeadNone = iota
+	streamHeadOpen
+	streamHeadClosed
+)
+
+var (
+	streamVoid = map[string]struct{}{
+		`area`: {}, `base`: {}, `basefont`: {}, `bgsound`: {}, `br`: {}, `col`: {}, `embed`: {}, `frame`: {}, `hr`: {},
+		`img`: {}, `input`: {}, `keygen`: {}, `link`: {}, `meta`: {}, `param`: {}, `source`: {}, `track`: {}, `wbr`: {},
+	}
+
+	streamHeadContent = map[string]struct{}{
+		`base`: {}, `basefont`: {}, `bgsound`: {}, `link`: {}, `meta`: {}, `noscript`: {}, `script`: {}, `style`: {},
+		`template`: {}, `title`: {},
+	}
+
+	// streamSVGTags maps lowercase svg tag names to their adjusted case, like the parser
+	streamSVGTags = map[string]string{
+		`altglyph`:            `altGlyph`,
+		`altglyphdef`:         `altGlyphDef`,
+		`altglyphitem`:        `altGlyphItem`,
+		`animatecolor`:        `animateColor`,
+		`animatemotion`:       `animateMotion`,
+		`animatetransform`:    `animateTransform`,
+		`clippath`:            `clipPath`,
+		`feblend`:             `feBlend`,
+		`fecolormatrix`:       `feColorMatrix`,
+		`fecomponenttransfer`: `feComponentTransfer`,
+		`fecomposite`:         `feComposite`,
+		`feconvolvematrix`:    `feConvolveMatrix`,
+		`fediffuselighting`:   `feDiffuseLighting`,
+		`fedisplacementmap`:   `feDisplacementMap`,
+		`fedistantlight`:      `feDistantLight`,
+		`feflood`:             `feFlood`,
+		`fefunca`:             `feFuncA`,
+		`fefuncb`:             `feFuncB`,
+		`fefuncg`:             `feFuncG`,
+		`fefuncr`:             `feFuncR`,
+		`fegaussianblur`:      `feGaussianBlur`,
+		`feimage`:             `feImage`,
+		`femerge`:             `feMerge`,
+		`femergenode`:         `feMergeNode`,
+		`femorphology`:        `feMorphology`,
+		`feoffset`:            `feOffset`,
+		`fepointlight`:        `fePointLight`,
+		`fespecularlighting`:  `feSpecularLighting`,
+		`fespotlight`:         `feSpotLight`,
+		`fetile`:              `feTile`,
+		`feturbulence`:        `feTurbulence`,
+		`foreignobject`:       `foreignObject`,
+		`glyphref`:            `glyphRef`,
+		`lineargradient`:      `linearGradient`,
+		`radialgradient`:      `radialGradient`,
+		`textpath`:            `textPath`,
+	}
+
+	// streamSVGAttrs maps lowercase svg attribute names to their adjusted case
+	streamSVGAttrs = map[string]string{
+		`attributename`:       `attributeName`,
+		`attributetype`:       `attributeType`,
+		`basefrequency`:       `baseFrequency`,
+		`baseprofile`:         `baseProfile`,
+		`calcmode`:            `calcMode`,
+		`clippathunits`:       `clipPathUnits`,
+		`diffuseconstant`:     `diffuseConstant`,
+		`edgemode`:            `edgeMode`,
+		`filterunits`:         `filterUnits`,
+		`glyphref`:            `glyphRef`,
+		`gradienttransform`:   `gradientTransform`,
+		`gradientunits`:       `gradientUnits`,
+		`kernelmatrix`:        `kernelMatrix`,
+		`kernelunitlength`:    `kernelUnitLength`,
+		`keypoints`:           `keyPoints`,
+		`keysplines`:          `keySplines`,
+		`keytimes`:            `keyTimes`,
+		`lengthadjust`:        `lengthAdjust`,
+		`limitingconeangle`:   `limitingConeAngle`,
+		`markerheight`:        `markerHeight`,
+		`markerunits`:         `markerUnits`,
+		`markerwidth`:         `markerWidth`,
+		`maskcontentunits`:    `maskContentUnits`,
+		`maskunits`:           `maskUnits`,
+		`numoctaves`:          `numOctaves`,
+		`pathlength`:          `pathLength`,
+		`patterncontentunits`: `patternContentUnits`,
+		`patterntransform`:    `patternTransform`,
+		`patternunits`:        `patternUnits`,
+		`pointsatx`:           `pointsAtX`,
+		`pointsaty`:           `pointsAtY`,
+		`pointsatz`:           `pointsAtZ`,
+		`preservealpha`:       `preserveAlpha`,
+		`preserveaspectratio`: `preserveAspectRatio`,
+		`primitiveunits`:      `primitiveUnits`,
+		`refx`:                `refX`,
+		`refy`:                `refY`,
+		`repeatcount`:         `repeatCount`,
+		`repeatdur`:           `repeatDur`,
+		`requiredextensions`:  `requiredExtensions`,
+		`requiredfeatures`:    `requiredFeatures`,
+		`specularconstant`:    `specularConstant`,
+		`specularexponent`:    `specularExponent`,
+		`spreadmethod`:        `spreadMethod`,
+		`startoffset`:         `startOffset`,
+		`stddeviation`:        `stdDeviation`,
+		`stitchtiles`:         `stitchTiles`,
+		`surfacescale`:        `surfaceScale`,
+		`systemlanguage`:      `systemLanguage`,
+		`tablevalues`:         `tableValues`,
+		`targetx`:             `targetX`,
+		`targety`:             `targetY`,
+		`textlength`:          `textLength`,
+		`viewbox`:             `viewBox`,
+		`viewtarget`:          `viewTarget`,
+		`xchannelselector`:    `xChannelSelector`,
+		`ychannelselector`:    `yChannelSelector`,
+		`zoomandpan`:          `zoomAndPan`,
+	}
+
+	// streamMathAttrs maps lowercase mathml attribute names to their adjusted case
+	streamMathAttrs = map[string]string{
+		`definitionurl`: `definitionURL`,
+	}
+
+	// streamClosedBy maps open elements to the start tags that will implicitly close them
+	streamClosedBy = func() map[string]map[string]struct{} {
+		set := func(tags ...string) map[string]struct{} {
+			m := make(map[string]struct{}, len(tags))
+			for _, tag := range tags {
+				m[tag] = struct{}{}
+			}
+			return m
+		}
+		headings := []string{`h1`, `h2`, `h3`, `h4`, `h5`, `h6`}
+		sections := []string{`tbody`, `thead`, `tfoot`}
+		return map[string]map[string]struct{}{
+			`p`: set(append([]string{
+				`address`, `article`, `aside`, `blockquote`, `center`, `details`, `dialog`, `dir`, `div`, `dl`,
+				`fieldset`, `figcaption`, `figure`, `footer`, `form`, `header`, `hgroup`, `hr`, `li`, `dd`, `dt`,
+				`listing`, `main`, `menu`, `nav`, `ol`, `p`, `plaintext`, `pre`, `section`, `summary`, `table`,
+				`ul`, `xmp`,
+			}, headings...)...),
+			`li`:       set(`li`),
+			`dt`:       set(`dt`, `dd`),
+			`dd`:       set(`dt`, `dd`),
+			`option`:   set(`option`, `optgroup`),
+			`optgroup`: set(`optgroup`),
+			`tr`:       set(append([]string{`tr`}, sections...)...),
+			`td`:       set(append([]string{`td`, `th`, `tr`}, sections...)...),
+			`th`:       set(append([]string{`td`, `th`, `tr`}, sections...)...),
+			`tbody`:    set(sections...),
+			`thead`:    set(sections...),
+			`tfoot`:    set(sections...),
+			`h1`:       set(headings...),
+			`h2`:       set(headings...),
+			`h3`:       set(headings...),
+			`h4`:       set(headings...),
+			`h5`:       set(headings...),
+			`h6`:       set(headings...),
+		}
+	}()
+)
+
+// Stream reads HTML from r using `html.Tokenizer`, without building the full tree, calling fn with each node matched
+// by the filters (see package comment for filter behavior), until fn returns false or the input is exhausted,
+// returning any non-EOF read error. Note that matches are passed in document order, which may differ from the order
+// returned by `FilterNodes`, for filters that depend on the match chain.
+//
+// Only the currently open elements (the partial ancestors of the current token, with their attributes) are retained
+// while tokenizing, and each matched sub-tree is retained only until it is complete and has been passed to fn, so
+// memory use is relative to the document depth, and the size of the matched sub-trees, rather than the size of the
+// document. Matched nodes have their `Parent` link set (to the partial ancestors), but their ancestors
+// and their own top level have no siblings, and they are detached from their parent's children.
+//
+// Filters are evaluated when each node is first encountered, and so are supported only if they look at the node
+// itself (type, tag, attributes), and its ancestors (e.g. `Parent`, `Offset`, and the match chain), this includes
+// `Tag`, `ID`, `Class`, the `Attr*` filters, `Type`, `DirectChild`, and selectors (see `Compile`) without sibling
+// combinators or structural pseudo-classes. Filters that look at children, text content, or siblings (e.g.
+// `TextContains`, `:empty`, `+`, `:nth-child`) will see none, and should instead be applied by fn, which receives the
+// complete sub-tree. Note that providing no filters will match (and therefore retain) the entire document.
+//
+// The tree is constructed using a simplified version of the HTML5 algorithm, which will imply the html, head, body
+// and tbody elements, and common end tags (e.g. for p, li and td), and will produce the same tree as `Parse` for
+// typical documents, but not in all cases (e.g. misnested formatting elements, or content "foster parented" out of a
+// table).
+//
+// Note that it will panic if fn is nil.
+func Stream(r io.Reader, fn func(node Node) bool, filters ...func(node Node) bool) error {
+	if fn == nil {
+		panic(errors.New("htmlutil.Stream nil fn"))
+	}
+	return (&streamer{
+		filters: (filterConfig{Filters: filters}).filters(),
+		fn:      fn,
+	}).run(r)
+}
+
+func (s *streamer) run(r io.Reader) error {
+	root := &html.Node{Type: html.DocumentNode}
+	{
+		node := Node{Data: root}
+		entry := streamEntry{node: node}
+		if len(s.filters) == 0 {
+			entry.capture = true
+			s.captures++
+			s.pending = append(s.pending, node)
+		} else {
+			var result *Node
+			result, entry.states = s.evaluate(node, []streamState{{match: &Node{Data: root}}})
+			if result != nil {
+				entry.capture = true
+				s.captures++
+				s.pending = append(s.pending, *result)
+			}
+		}
+		s.stack = append(s.stack, entry)
+	}
+
+	z := html.NewTokenizer(r)
+
+	for !s.stopped {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			break
+		}
+		token := z.Token()
+		switch tt {
+		case html.TextToken:
+			s.text.WriteString(token.Data)
+		case html.CommentToken:
+			s.flushText()
+			s.insert(&html.Node{Type: html.CommentNode, Data: token.Data}, false)
+		case html.DoctypeToken:
+			s.flushText()
+			if len(s.stack) == 1 && !s.html {
+				s.insert(&html.Node{Type: html.DoctypeNode, Data: token.Data}, false)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			s.flushText()
+			if s.startTag(token, tt == html.SelfClosingTagToken) && s.top().Namespace != `` {
+				z.NextIsNotRawText()
+			}
+		case html.EndTagToken:
+			s.endTag(token)
+		}
+	}
+
+	if !s.stopped {
+		s.flushText()
+		s.ensureBody()
+	}
+
+	for len(s.stack) != 0 && !s.stopped {
+		s.pop()
+	}
+
+	return nil
+}
+
+func (s *streamer) top() *html.Node {
+	return s.stack[len(s.stack)-1].node.Data
+}
+
+// evaluate applies the filters to node (which must have a nil `Match`), for each of the states that apply to it,
+// returning the first result (if any), and the states that apply to the node's children
+func (s *streamer) evaluate(node Node, states []streamState) (result *Node, children []streamState) {
+	add := func(state streamState) {
+		for _, v := range children {
+			if v.index == state.index && v.match.Data == state.match.Data {
+				return
+			}
+		}
+		children = append(children, state)
+	}
+	for _, state := range states {
+		node.Match = state.match
+		if s.filters[state.index](node) {
+			if state.index == len(s.filters)-1 {
+				if result == nil {
+					v := node
+					result = &v
+				}
+			} else {
+				match := state.match
+				if match.Data != node.Data {
+					v := node
+					match = &v
+				}
+				add(streamState{index: state.index + 1, match: match})
+			}
+		}
+		add(state)
+	}
+	return
+}
+
+// insert adds a node as a child of the current node, evaluating the filters, and pushing it onto the stack if open
+func (s *streamer) insert(n *html.Node, open bool) {
+	if s.stopped {
+		return
+	}
+
+	parent := &s.stack[len(s.stack)-1]
+	parent.started = true
+	if s.captures != 0 {
+		parent.node.Data.AppendChild(n)
+	} else {
+		n.Parent = parent.node.Data
+	}
+
+	entry := streamEntry{node: Node{Data: n, Depth: parent.node.Depth + 1}}
+	result, states := s.evaluate(entry.node, parent.states)
+	if open {
+		entry.states = states
+	}
+
+	if result != nil {
+		s.pending = append(s.pending, *result)
+		if s.captures == 0 {
+			if !open {
+				s.flush()
+				return
+			}
+			entry.capture = true
+			s.captures++
+		}
+	}
+
+	if open {
+		s.stack = append(s.stack, entry)
+	}
+}
+
+func (s *streamer) pop() {
+	entry := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	if entry.capture {
+		s.captures--
+		s.flush()
+	}
+}
+
+func (s *streamer) flush() {
+	pending := s.pending
+	s.pending = nil
+	for _, node := range pending {
+		if !s.fn(node) {
+			s.stopped = true
+			return
+		}
+	}
+}
+
+func (s *streamer) element(name string, attr []html.Attribute) *html.Node {
+	return &html.Node{
+		Type:     html.ElementNode,
+		DataAtom: atom.Lookup([]byte(name)),
+		Data:     name,
+		Attr:     attr,
+	}
+}
+
+func (s *streamer) ensureHTML() {
+	if !s.html {
+		s.html = true
+		s.insert(s.element(`html`, nil), true)
+	}
+}
+
+func (s *streamer) ensureBody() {
+	if s.body {
+		return
+	}
+	s.ensureHTML()
+	s.closeHead()
+	if s.head == streamHeadNone {
+		s.head = streamHeadClosed
+		s.insert(s.element(`head`, nil), false)
+	}
+	s.body = true
+	s.insert(s.element(`body`, nil), true)
+}
+
+func (s *streamer) closeHead() {
+	if s.head != streamHeadOpen {
+		return
+	}
+	s.head = streamHeadClosed
+	for len(s.stack) > 1 && !s.stopped {
+		top := s.top()
+		s.pop()
+		if top.Type == html.ElementNode && top.Data == `head` && top.Namespace == `` {
+			break
+		}
+	}
+}
+
+// flushText inserts any buffered text, which is necessary since the parser merges adjacent text
+func (s *streamer) flushText() {
+	text := s.text.String()
+	s.text.Reset()
+	if text == `` {
+		return
+	}
+
+	if top := s.top(); !s.body && (top.Type == html.DocumentNode || top.Namespace == `` && (top.Data == `html` || top.Data == `head`)) {
+		trimmed := strings.TrimLeft(text, "\t\n\f\r ")
+		if top.Data == `head` {
+			// leading whitespace goes in the head, like the parser
+			if ws := text[:len(text)-len(trimmed)]; ws != `` {
+				s.insert(&html.Node{Type: html.TextNode, Data: ws}, false)
+			}
+		}
+		if trimmed == `` {
+			return
+		}
+		text = trimmed
+		s.ensureBody()
+	}
+
+	if entry := &s.stack[len(s.stack)-1]; !entry.started && entry.node.Data.Namespace == `` {
+		switch entry.node.Data.Data {
+		case `pre`, `listing`, `textarea`:
+			text = strings.TrimPrefix(text, "\n")
+			if text == `` {
+				return
+			}
+		}
+	}
+
+	s.insert(&html.Node{Type: html.TextNode, Data: text}, false)
+}
+
+// startTag handles a start tag token, returning true if an element was left open
+func (s *streamer) startTag(token html.Token, selfClosing bool) bool {
+	name := token.Data
+
+	switch name {
+	case `html`:
+		if s.html {
+			return false
+		}
+		s.html = true
+		s.insert(s.element(name, token.Attr), true)
+		return true
+
+	case `head`:
+		s.ensureHTML()
+		if s.head != streamHeadNone || s.body {
+			return false
+		}
+		s.head = streamHeadOpen
+		s.insert(s.element(name, token.Attr), true)
+		return true
+
+	case `body`:
+		if s.body {
+			return false
+		}
+		s.ensureHTML()
+		s.closeHead()
+		if s.head == streamHeadNone {
+			s.head = streamHeadClosed
+			s.insert(s.element(`head`, nil), false)
+		}
+		s.body = true
+		s.insert(s.element(name, token.Attr), true)
+		return true
+	}
+
+	if !s.body {
+		if _, ok := streamHeadContent[name]; ok {
+			s.ensureHTML()
+			if s.head == streamHeadNone {
+				s.head = streamHeadOpen
+				s.insert(s.element(`head`, nil), true)
+			}
+		} else {
+			s.ensureBody()
+		}
+	}
+
+	parent := s.top()
+	namespace := parent.Namespace
+	switch {
+	case namespace == `` && (name == `svg` || name == `math`):
+		namespace = name
+	case namespace == `svg` && (parent.Data == `foreignObject` || parent.Data == `desc` || parent.Data == `title`),
+		namespace == `math` && parent.Data == `annotation-xml`:
+		namespace = ``
+	}
+
+	if namespace == `` {
+		s.implyEndTags(name)
+		s.implyTable(name)
+	}
+
+	n := s.element(name, token.Attr)
+	n.Namespace = namespace
+	if namespace != `` {
+		adjust := streamMathAttrs
+		if namespace == `svg` {
+			adjust = streamSVGAttrs
+			if v, ok := streamSVGTags[name]; ok {
+				n.Data = v
+				n.DataAtom = atom.Lookup([]byte(v))
+			}
+		}
+		for i, attr := range n.Attr {
+			if v, ok := adjust[attr.Key]; ok {
+				n.Attr[i].Key = v
+			}
+			if prefix, key, ok := strings.Cut(attr.Key, `:`); ok && (prefix == `xlink` || prefix == `xml` || prefix == `xmlns`) {
+				n.Attr[i].Namespace = prefix
+				n.Attr[i].Key = key
+			}
+		}
+	}
+
+	open := !selfClosing
+	if namespace == `` {
+		_, void := streamVoid[name]
+		open = !void
+	}
+	s.insert(n, open)
+
+	return open
+}
+
+func (s *streamer) implyEndTags(name string) {
+	for len(s.stack) > 1 && !s.stopped {
+		top := s.top()
+		if top.Namespace != `` {
+			return
+		}
+		if _, ok := streamClosedBy[top.Data][name]; !ok {
+			return
+		}
+		s.pop()
+	}
+}
+
+func (s *streamer) implyTable(name string) {
+	if s.stopped {
+		return
+	}
+	switch name {
+	case `tr`:
+		if s.top().Data == `table` {
+			s.insert(s.element(`tbody`, nil), true)
+		}
+	case `td`, `th`:
+		switch s.top().Data {
+		case `table`:
+			s.insert(s.element(`tbody`, nil), true)
+			s.insert(s.element(`tr`, nil), true)
+		case `tbody`, `thead`, `tfoot`:
+			s.insert(s.element(`tr`, nil), true)
+		}
+	}
+}
+
+// endTag handles an end tag token, which is ignored unless it closes a currently open element (other than the html
+// or body elements), in which case it will also close any elements it contains
+func (s *streamer) endTag(token html.Token) {
+	name := token.Data
+	if name == `html` || name == `body` {
+		return
+	}
+	index := -1
+	for i := len(s.stack) - 1; i > 0; i-- {
+		n := s.stack[i].node.Data
+		if n.Type != html.ElementNode {
+			break
+		}
+		if strings.ToLower(n.Data) == name {
+			index = i
+			break
+		}
+		if n.Namespace == `` && (n.Data == `html` || n.Data == `body` || n.Data == `head`) {
+			break
+		}
+	}
+	if index == -1 {
+		return
+	}
+	s.flushText()
+	if name == `head` && s.stack[index].node.Data.Namespace == `` {
+		s.closeHead()
+		return
+	}
+	for len(s.stack) > index && !s.stopped {
+		s.pop()
+	}
+}
@@ -0,0 +1,201 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-test/deep"
+	"golang.org/x/net/html"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type streamResult struct {
+	HTML   string
+	Depth  int
+	Offset int
+}
+
+func streamResults(nodes []Node) []streamResult {
+	var results []streamResult
+	for _, node := range nodes {
+		results = append(results, streamResult{
+			HTML:   node.OuterHTML(),
+			Depth:  node.Depth,
+			Offset: node.Offset(),
+		})
+	}
+	return results
+}
+
+func TestStream(t *testing.T) {
+	inputs := []string{
+		``,
+		`<!DOCTYPE html><html lang="en"><head><title>a &amp; b</title><meta charset="utf-8"/></head><body class="x"><div class="one"><p>one</p><div class="one"><img alt="two"/></div></div><!--c--><p id="three">three</p></body></html>`,
+		`<div class="one"><p>one<p>two<ul><li>a<li class="one">b</ul></div>`,
+		`<title>t</title> <link href="/x"/><span>text</span>`,
+		`<table class="one"><tr><td>a<td>b<tr><th>c</table><pre>` + "\n" + `pre</pre>`,
+		`<div><svg viewbox="0 0 1 1"><a xlink:href="#x"><title>svg title</title></a><foreignobject><p>p</p></foreignobject></svg></div>`,
+		`<dl><dt>a<dd>b<dt class="one">c</dl><select><option>a<option>b</select>text</unknown>more`,
+		`<div class="one"><div><div class="one"><b>x</b></div></div><b>y</b></div>`,
+	}
+	filterSets := [][]func(node Node) bool{
+		nil,
+		{Tag(`div`)},
+		{Class(`one`)},
+		{Tag(`body`), DirectChild()},
+		{Class(`one`), Tag(`b`, `p`, `li`, `img`)},
+		{Class(`one`), And(Tag(), DirectChild())},
+		MustCompile(`div.one > div b, td, th, dd, option`),
+		{Type(html.TextNode)},
+		{Type(html.CommentNode)},
+		{Tag(`a`, `title`, `p`)},
+		{Tag(`head`)},
+	}
+	for i, input := range inputs {
+		root := parse(input)
+		order := make(map[*html.Node]int)
+		for node := range root.All(func(node Node) bool { return true }) {
+			order[node.Data] = len(order)
+		}
+		for j, filters := range filterSets {
+			name := fmt.Sprintf("Stream_#%d_#%d", i+1, j+1)
+			var actual []Node
+			if err := Stream(strings.NewReader(input), func(node Node) bool {
+				actual = append(actual, node)
+				return true
+			}, filters...); err != nil {
+				t.Fatal(name, err)
+			}
+			// results are in document order, which may differ from the filter algorithm (depth first, by match)
+			expected := root.FilterNodes(filters...)
+			sort.SliceStable(expected, func(i, j int) bool {
+				return order[expected[i].Data] < order[expected[j].Data]
+			})
+			if diff := deep.Equal(
+				streamResults(actual),
+				streamResults(expected),
+			); diff != nil {
+				t.Error(strings.Join(append([]string{name + " output diff:"}, diff...), "    \n"))
+			}
+		}
+	}
+}
+
+func TestStream_parent(t *testing.T) {
+	var actual []string
+	if err := Stream(
+		strings.NewReader(`<div id="a"><section><p>1</p><p>2</p></section></div>`),
+		func(node Node) bool {
+			if node.Data.PrevSibling != nil || node.Data.NextSibling != nil {
+				t.Error(node)
+			}
+			var tags []string
+			for parent := node.Parent(); parent.Data != nil; parent = parent.Parent() {
+				if parent.Data.FirstChild != nil {
+					t.Error(parent)
+				}
+				tags = append(tags, parent.Tag())
+			}
+			actual = append(actual, node.OuterHTML()+` `+strings.Join(tags, `,`))
+			return true
+		},
+		MustCompile(`#a p`)...,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(actual, []string{
+		`<p>1</p> section,div,body,html,`,
+		`<p>2</p> section,div,body,html,`,
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestStream_stop(t *testing.T) {
+	var (
+		count int
+		tags  []string
+	)
+	if err := Stream(
+		strings.NewReader(`<div><p>1</p><p>2</p></div><p>3</p>`),
+		func(node Node) bool {
+			tags = append(tags, node.OuterHTML())
+			return len(tags) != 2
+		},
+		func(node Node) bool {
+			count++
+			return node.Tag() == `div` || node.Tag() == `p`
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(tags, []string{`<div><p>1</p><p>2</p></div>`, `<p>1</p>`}); diff != nil {
+		t.Error(diff)
+	}
+	// document, html, head, body, then the div and its descendants
+	if count != 9 {
+		t.Error(count)
+	}
+}
+
+func TestStream_error(t *testing.T) {
+	expected := errors.New(`some error`)
+	var actual []string
+	err := Stream(
+		iotest.DataErrReader(iotest.OneByteReader(errReader{
+			r:   strings.NewReader(`<p>1</p><p>2`),
+			err: expected,
+		})),
+		func(node Node) bool {
+			actual = append(actual, node.OuterHTML())
+			return true
+		},
+		Tag(`p`),
+	)
+	if err != expected {
+		t.Error(err)
+	}
+	if diff := deep.Equal(actual, []string{`<p>1</p>`}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestStream_panic(t *testing.T) {
+	defer func() {
+		if r := fmt.Sprint(recover()); r != "htmlutil.Stream nil fn" {
+			t.Fatal(r)
+		}
+	}()
+	_ = Stream(strings.NewReader(`<p>1</p>`), nil)
+}
+
+type errReader struct {
+	r   *strings.Reader
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil {
+		err = r.err
+	}
+	return n, err
+}
@@ -0,0 +1,188 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package structured
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/joeycumines/go-htmlutil"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// jsonldContext is the (supported subset of the) active context
+type jsonldContext struct {
+	vocab string
+	terms map[string]string
+}
+
+// JSONLD returns the top-level items of every `<script type="application/ld+json">` element in the sub-tree, where a
+// top-level array contains multiple items, and an object with a `@graph` is replaced by the items of that graph,
+// returning an error (with the index of each script element) for any script that was not valid JSON, after extracting
+// the items from every valid script
+func JSONLD(node htmlutil.Node) ([]*Item, error) {
+	var (
+		items []*Item
+		errs  []error
+		index int
+	)
+	for script := range node.All(htmlutil.Tag(`script`)) {
+		if script.Data.Namespace != `` || !jsonldType(script.GetAttrVal(``, `type`)) {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(script.InnerText()))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			errs = append(errs, fmt.Errorf("structured.JSONLD script[%d]: %w", index, err))
+		} else {
+			items = append(items, jsonldItems(script, jsonldContext{}, v)...)
+		}
+		index++
+	}
+	return items, errors.Join(errs...)
+}
+
+func jsonldType(s string) bool {
+	s, _, _ = strings.Cut(s, `;`)
+	return strings.EqualFold(strings.TrimSpace(s), `application/ld+json`)
+}
+
+func jsonldItems(node htmlutil.Node, ctx jsonldContext, v any) []*Item {
+	switch v := v.(type) {
+	case []any:
+		var items []*Item
+		for _, v := range v {
+			items = append(items, jsonldItems(node, ctx, v)...)
+		}
+		return items
+	case map[string]any:
+		if graph, ok := v[`@graph`]; ok {
+			if c, ok := v[`@context`]; ok {
+				ctx = ctx.with(c)
+			}
+			return jsonldItems(node, ctx, graph)
+		}
+		return []*Item{jsonldItem(node, ctx, v)}
+	default:
+		return nil
+	}
+}
+
+func jsonldItem(node htmlutil.Node, ctx jsonldContext, m map[string]any) *Item {
+	if c, ok := m[`@context`]; ok {
+		ctx = ctx.with(c)
+	}
+	item := &Item{Format: FormatJSONLD, Node: node}
+	for k, v := range m {
+		switch {
+		case k == `@id`:
+			if s, ok := v.(string); ok {
+				item.ID = strings.TrimSpace(s)
+			}
+		case k == `@type`:
+			for _, v := range jsonldValues(node, ctx, v) {
+				if v.Item == nil && v.Text != `` {
+					item.Types = append(item.Types, expand(ctx.vocab, ctx.terms, v.Text))
+				}
+			}
+		case strings.HasPrefix(k, `@`):
+		default:
+			for _, v := range jsonldValues(node, ctx, v) {
+				item.add(k, v)
+			}
+		}
+	}
+	return item
+}
+
+func jsonldValues(node htmlutil.Node, ctx jsonldContext, v any) []Value {
+	switch v := v.(type) {
+	case string:
+		return []Value{{Node: node, Text: strings.TrimSpace(v)}}
+	case json.Number:
+		return []Value{{Node: node, Text: v.String()}}
+	case bool:
+		return []Value{{Node: node, Text: strconv.FormatBool(v)}}
+	case []any:
+		var values []Value
+		for _, v := range v {
+			values = append(values, jsonldValues(node, ctx, v)...)
+		}
+		return values
+	case map[string]any:
+		if value, ok := v[`@value`]; ok {
+			if _, ok := value.(map[string]any); !ok {
+				return jsonldValues(node, ctx, value)
+			}
+			return nil
+		}
+		for _, k := range [...]string{`@list`, `@set`} {
+			if list, ok := v[k]; ok {
+				return jsonldValues(node, ctx, list)
+			}
+		}
+		return []Value{{Node: node, Item: jsonldItem(node, ctx, v)}}
+	default:
+		return nil
+	}
+}
+
+// with returns the context, updated by a (local) `@context` value
+func (c jsonldContext) with(v any) jsonldContext {
+	switch v := v.(type) {
+	case nil:
+		return jsonldContext{}
+	case string:
+		if v = strings.TrimSpace(v); v != `` && !strings.HasSuffix(v, `/`) && !strings.HasSuffix(v, `#`) {
+			v += `/`
+		}
+		c.vocab = v
+	case []any:
+		for _, v := range v {
+			c = c.with(v)
+		}
+	case map[string]any:
+		terms := maps.Clone(c.terms)
+		if terms == nil {
+			terms = make(map[string]string)
+		}
+		for k, v := range v {
+			if k == `@vocab` {
+				if s, ok := v.(string); ok {
+					c.vocab = s
+				}
+				continue
+			}
+			if strings.HasPrefix(k, `@`) {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				terms[k] = v
+			case map[string]any:
+				if s, ok := v[`@id`].(string); ok {
+					terms[k] = s
+				}
+			}
+		}
+		c.terms = terms
+	}
+	return c
+}
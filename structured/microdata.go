@@ -0,0 +1,170 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package structured
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"sort"
+	"strings"
+)
+
+// microdata holds the state for resolving itemref attributes, indexing the document (of the node being searched)
+// on first use
+type microdata struct {
+	node  htmlutil.Node
+	ids   map[string]htmlutil.Node
+	order map[*html.Node]int
+}
+
+// Microdata returns the top-level items (elements with an `itemscope` but no `itemprop` attribute) in the sub-tree, in
+// tree order, where the properties of each item are found as per the HTML standard, including those of elements
+// referenced by `itemref` (which may be anywhere in the document), and the value of each property is the nested
+// item, or the relevant attribute (e.g. `content` for meta elements, `src` for img elements, `href` for a elements),
+// or the text content of the element
+func Microdata(node htmlutil.Node) []*Item {
+	m := microdata{node: node}
+	var items []*Item
+	for n := range node.All(htmlutil.HasAttr(``, `itemscope`)) {
+		if _, ok := n.GetAttr(``, `itemprop`); ok {
+			continue
+		}
+		items = append(items, m.item(n, make(map[*html.Node]bool)))
+	}
+	return items
+}
+
+// item builds the item for an element with an itemscope, where parents are the elements of the items currently
+// being built, which are skipped (as properties) to avoid cycles
+func (m *microdata) item(node htmlutil.Node, parents map[*html.Node]bool) *Item {
+	parents[node.Data] = true
+	defer delete(parents, node.Data)
+
+	item := &Item{
+		Format: FormatMicrodata,
+		Node:   node,
+		ID:     strings.TrimSpace(node.GetAttrVal(``, `itemid`)),
+		Types:  strings.Fields(node.GetAttrVal(``, `itemtype`)),
+	}
+	for _, prop := range m.properties(node) {
+		value := Value{Node: prop}
+		if _, ok := prop.GetAttr(``, `itemscope`); ok {
+			if parents[prop.Data] {
+				continue
+			}
+			value.Item = m.item(prop, parents)
+		} else {
+			value.Text = microdataValue(prop)
+		}
+		for _, name := range strings.Fields(prop.GetAttrVal(``, `itemprop`)) {
+			item.add(name, value)
+		}
+	}
+	return item
+}
+
+// properties returns the property elements of an item, in tree order, as per the HTML standard
+func (m *microdata) properties(root htmlutil.Node) []htmlutil.Node {
+	var (
+		results []htmlutil.Node
+		memory  = map[*html.Node]bool{root.Data: true}
+		pending []htmlutil.Node
+	)
+	for child := range root.ChildrenSeq() {
+		pending = append(pending, child)
+	}
+	for _, id := range strings.Fields(root.GetAttrVal(``, `itemref`)) {
+		if node, ok := m.byID(id); ok {
+			pending = append(pending, node)
+		}
+	}
+	for len(pending) != 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if current.Type() != html.ElementNode || memory[current.Data] {
+			continue
+		}
+		memory[current.Data] = true
+		if _, ok := current.GetAttr(``, `itemscope`); !ok {
+			for child := range current.ChildrenSeq() {
+				pending = append(pending, child)
+			}
+		}
+		if len(strings.Fields(current.GetAttrVal(``, `itemprop`))) != 0 {
+			results = append(results, current)
+		}
+	}
+	m.index()
+	sort.SliceStable(results, func(i, j int) bool {
+		return m.order[results[i].Data] < m.order[results[j].Data]
+	})
+	return results
+}
+
+// byID returns the first element in the document with the given id
+func (m *microdata) byID(id string) (htmlutil.Node, bool) {
+	m.index()
+	node, ok := m.ids[id]
+	return node, ok
+}
+
+func (m *microdata) index() {
+	if m.order != nil {
+		return
+	}
+	root := m.node
+	for parent := root.Parent(); parent.Data != nil; parent = parent.Parent() {
+		root = parent
+	}
+	m.ids = make(map[string]htmlutil.Node)
+	m.order = make(map[*html.Node]int)
+	for node := range root.All(func(htmlutil.Node) bool { return true }) {
+		m.order[node.Data] = len(m.order)
+		if id, ok := node.GetAttr(``, `id`); ok && node.Type() == html.ElementNode {
+			if _, ok := m.ids[id.Val]; !ok {
+				m.ids[id.Val] = node
+			}
+		}
+	}
+}
+
+// microdataValue returns the value of a property element that isn't an item
+func microdataValue(node htmlutil.Node) string {
+	attr := ``
+	if node.Data.Namespace == `` {
+		switch node.Tag() {
+		case `meta`:
+			attr = `content`
+		case `audio`, `embed`, `iframe`, `img`, `source`, `track`, `video`:
+			attr = `src`
+		case `a`, `area`, `link`:
+			attr = `href`
+		case `object`:
+			attr = `data`
+		case `data`, `meter`:
+			attr = `value`
+		case `time`:
+			if _, ok := node.GetAttr(``, `datetime`); ok {
+				attr = `datetime`
+			}
+		}
+	}
+	if attr == `` {
+		return strings.TrimSpace(node.OuterText())
+	}
+	return strings.TrimSpace(node.GetAttrVal(``, attr))
+}
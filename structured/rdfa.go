@@ -0,0 +1,130 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package structured
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"maps"
+	"strings"
+)
+
+// rdfaContext is the vocabulary and prefix mappings in scope for an element
+type rdfaContext struct {
+	vocab    string
+	prefixes map[string]string
+}
+
+// rdfaPrefixes are the (commonly used) prefixes of the RDFa initial context
+var rdfaPrefixes = map[string]string{
+	`dc`:      `http://purl.org/dc/terms/`,
+	`dcterms`: `http://purl.org/dc/terms/`,
+	`foaf`:    `http://xmlns.com/foaf/0.1/`,
+	`og`:      `http://ogp.me/ns#`,
+	`rdf`:     `http://www.w3.org/1999/02/22-rdf-syntax-ns#`,
+	`rdfs`:    `http://www.w3.org/2000/01/rdf-schema#`,
+	`schema`:  `http://schema.org/`,
+	`xsd`:     `http://www.w3.org/2001/XMLSchema#`,
+}
+
+// RDFa returns the top-level items (elements with a `typeof` but no `property` attribute) in the sub-tree, in tree
+// order, as per RDFa Lite (the `vocab`, `typeof`, `property`, `resource` and `prefix` attributes), where the
+// properties of each item are the `property` attributes of descendants, stopping at any nested `typeof`, and the value
+// of each property is the nested item (if it has a `typeof`), or the `content`, `resource`, `href`, `src` or
+// `datetime` attribute, or the text content of the element
+func RDFa(node htmlutil.Node) []*Item {
+	var items []*Item
+	for n := range node.All(htmlutil.HasAttr(``, `typeof`)) {
+		if _, ok := n.GetAttr(``, `property`); ok {
+			continue
+		}
+		var ancestors []htmlutil.Node
+		for parent := range n.Ancestors() {
+			ancestors = append(ancestors, parent)
+		}
+		ctx := rdfaContext{prefixes: rdfaPrefixes}
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			ctx = ctx.with(ancestors[i])
+		}
+		items = append(items, rdfaItem(n, ctx.with(n)))
+	}
+	return items
+}
+
+func rdfaItem(node htmlutil.Node, ctx rdfaContext) *Item {
+	item := &Item{
+		Format: FormatRDFa,
+		Node:   node,
+		ID:     strings.TrimSpace(node.GetAttrVal(``, `resource`)),
+	}
+	for _, v := range strings.Fields(node.GetAttrVal(``, `typeof`)) {
+		item.Types = append(item.Types, expand(ctx.vocab, ctx.prefixes, v))
+	}
+	rdfaProperties(item, node, ctx)
+	return item
+}
+
+func rdfaProperties(item *Item, parent htmlutil.Node, ctx rdfaContext) {
+	for child := range parent.ChildrenSeq(htmlutil.Type(html.ElementNode)) {
+		ctx := ctx.with(child)
+		_, isItem := child.GetAttr(``, `typeof`)
+		if names := strings.Fields(child.GetAttrVal(``, `property`)); len(names) != 0 {
+			value := Value{Node: child}
+			if isItem {
+				value.Item = rdfaItem(child, ctx)
+			} else {
+				value.Text = rdfaValue(child)
+			}
+			for _, name := range names {
+				item.add(name, value)
+			}
+		}
+		if !isItem {
+			rdfaProperties(item, child, ctx)
+		}
+	}
+}
+
+func rdfaValue(node htmlutil.Node) string {
+	for _, key := range [...]string{`content`, `resource`, `href`, `src`} {
+		if v, ok := node.GetAttr(``, key); ok {
+			return strings.TrimSpace(v.Val)
+		}
+	}
+	if v, ok := node.GetAttr(``, `datetime`); ok && node.Tag() == `time` {
+		return strings.TrimSpace(v.Val)
+	}
+	return strings.TrimSpace(node.OuterText())
+}
+
+// with returns the context, updated by the vocab and prefix attributes of node (if any)
+func (c rdfaContext) with(node htmlutil.Node) rdfaContext {
+	if v, ok := node.GetAttr(``, `vocab`); ok {
+		c.vocab = strings.TrimSpace(v.Val)
+	}
+	if v, ok := node.GetAttr(``, `prefix`); ok {
+		c.prefixes = maps.Clone(c.prefixes)
+		fields := strings.Fields(v.Val)
+		for i := 0; i+1 < len(fields); i++ {
+			if prefix, ok := strings.CutSuffix(fields[i], `:`); ok && prefix != `` {
+				c.prefixes[prefix] = fields[i+1]
+				i++
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,158 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package structured extracts structured data (typically schema.org) from a `htmlutil.Node` tree, supporting JSON-LD
+// (including `@graph`), Microdata (including `itemref`) and RDFa Lite, each of which is converted to a common item
+// model.
+//
+// # Item model
+//
+//   - an item has zero or more types, an optional id, and properties, each of which has one or more values
+//   - a value is either text, or a nested item
+//   - types are absolute IRIs where possible, e.g. "https://schema.org/Product", expanded using the vocabulary of the
+//     source syntax (the JSON-LD `@context`, or the RDFa `vocab` and `prefix` attributes), note that Microdata types
+//     must already be absolute
+//   - property names are as written in the source, e.g. "name" or "og:title", and JSON-LD keywords (e.g. "@id") are
+//     not properties
+//   - text values have leading and trailing whitespace trimmed, and urls are not resolved (see `htmlutil.Node.Links`)
+//
+// JSON-LD support is limited to (string or object) contexts defining a vocabulary, prefixes and terms, remote
+// contexts are never fetched, though a string context (e.g. "https://schema.org") is treated as a vocabulary.
+package structured
+
+import (
+	"github.com/joeycumines/go-htmlutil"
+	"strings"
+)
+
+const (
+	// FormatJSONLD indicates an item from a `<script type="application/ld+json">` element
+	FormatJSONLD Format = iota + 1
+	// FormatMicrodata indicates an item from an element with an `itemscope` attribute
+	FormatMicrodata
+	// FormatRDFa indicates an item from an element with a `typeof` attribute
+	FormatRDFa
+)
+
+type (
+	// Format is the syntax an item was extracted from
+	Format int
+
+	// Item is a structured data item, see the package comment
+	Item struct {
+		// Format is the syntax the item was extracted from
+		Format Format
+		// Node is the element that defined the item, which is the script element, for JSON-LD
+		Node htmlutil.Node
+		// ID is the `@id`, `itemid`, or `resource` of the item, if any
+		ID string
+		// Types are the (expanded) types of the item
+		Types []string
+		// Properties maps each property name to its values, in order
+		Properties map[string][]Value
+	}
+
+	// Value is a property value, where exactly one of Text or Item is meaningful, Item being non-nil for a nested item
+	Value struct {
+		// Node is the element that defined the value, which is the script element, for JSON-LD
+		Node htmlutil.Node
+		// Text is the value, if it isn't an item
+		Text string
+		// Item is the nested item, if any
+		Item *Item
+	}
+)
+
+// Extract returns every top-level item, from JSON-LD, then Microdata, then RDFa, see the functions of the same names,
+// note that the items are returned even if an error occurred (due to invalid JSON-LD)
+func Extract(node htmlutil.Node) ([]*Item, error) {
+	items, err := JSONLD(node)
+	items = append(items, Microdata(node)...)
+	items = append(items, RDFa(node)...)
+	return items, err
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSONLD:
+		return `json-ld`
+	case FormatMicrodata:
+		return `microdata`
+	case FormatRDFa:
+		return `rdfa`
+	default:
+		return `unknown`
+	}
+}
+
+// HasType returns true if the item has the given type, which may be either an absolute IRI, or a local name (e.g.
+// "Product"), matching the part of any type after the last "/" or "#"
+func (i *Item) HasType(t string) bool {
+	for _, v := range i.Types {
+		if v == t || v[strings.LastIndexAny(v, `/#`)+1:] == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the first value of the property that isn't an item, or an empty string
+func (i *Item) Text(property string) string {
+	for _, v := range i.Properties[property] {
+		if v.Item == nil {
+			return v.Text
+		}
+	}
+	return ``
+}
+
+// Items returns the values of the property that are items
+func (i *Item) Items(property string) []*Item {
+	var items []*Item
+	for _, v := range i.Properties[property] {
+		if v.Item != nil {
+			items = append(items, v.Item)
+		}
+	}
+	return items
+}
+
+func (i *Item) add(property string, value Value) {
+	if i.Properties == nil {
+		i.Properties = make(map[string][]Value)
+	}
+	i.Properties[property] = append(i.Properties[property], value)
+}
+
+// expand converts a term, compact IRI (e.g. "schema:Product"), or absolute IRI, to an absolute IRI, where possible
+func expand(vocab string, prefixes map[string]string, s string) string {
+	if strings.Contains(s, `://`) {
+		return s
+	}
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		if iri, ok := prefixes[s[:i]]; ok {
+			return iri + s[i+1:]
+		}
+		return s
+	}
+	if iri, ok := prefixes[s]; ok {
+		return iri
+	}
+	if vocab != `` {
+		return vocab + s
+	}
+	return s
+}
@@ -0,0 +1,280 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package structured
+
+import (
+	"github.com/go-test/deep"
+	"github.com/joeycumines/go-htmlutil"
+	"strings"
+	"testing"
+)
+
+func parse(s string) htmlutil.Node {
+	v, err := htmlutil.Parse(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// simplify converts an item to a comparable value, including the tag of the element for each item and value
+func simplify(item *Item) map[string]any {
+	m := map[string]any{
+		`@format`: item.Format.String(),
+		`@node`:   item.Node.Tag(),
+	}
+	if item.ID != `` {
+		m[`@id`] = item.ID
+	}
+	if len(item.Types) != 0 {
+		m[`@type`] = item.Types
+	}
+	for name, values := range item.Properties {
+		var result []any
+		for _, v := range values {
+			if v.Item != nil {
+				result = append(result, simplify(v.Item))
+			} else {
+				result = append(result, v.Node.Tag()+`: `+v.Text)
+			}
+		}
+		m[name] = result
+	}
+	return m
+}
+
+func simplifyAll(items []*Item) []map[string]any {
+	var result []map[string]any
+	for _, item := range items {
+		result = append(result, simplify(item))
+	}
+	return result
+}
+
+func TestJSONLD(t *testing.T) {
+	const input = `<html><head>
+<script type="application/ld+json">
+{
+	"@context": "https://schema.org",
+	"@type": "Product",
+	"@id": " #product ",
+	"name": " Widget ",
+	"sku": 123,
+	"isFamilyFriendly": true,
+	"gtin": null,
+	"image": ["a.png", ["b.png"]],
+	"description": {"@value": "desc", "@language": "en"},
+	"offers": {"@type": ["Offer", "schema:Demand"], "price": 9.90, "@context": {"schema": "http://schema.org/"}}
+}
+</script>
+<script type="Application/LD+JSON; charset=utf-8">{
+	"@context": {"@vocab": "http://schema.org/", "ex": "http://example.com/ns#"},
+	"@graph": [
+		{"@type": "Article", "headline": "H", "keywords": {"@list": ["a", "b"]}},
+		{"@type": "ex:Thing", "@context": null, "@reverse": {"x": "y"}}
+	]
+}</script>
+<script type="application/ld+json">[{"@type": "https://example.com/Absolute"}, "ignored"]</script>
+<script type="application/ld+json">{invalid</script>
+<script type="application/json">{"@type": "NotLD"}</script>
+</head></html>`
+	items, err := JSONLD(parse(input))
+	if err == nil || !strings.HasPrefix(err.Error(), `structured.JSONLD script[3]: invalid character`) {
+		t.Error(err)
+	}
+	if diff := deep.Equal(simplifyAll(items), []map[string]any{
+		{
+			`@format`:          `json-ld`,
+			`@node`:            `script`,
+			`@id`:              `#product`,
+			`@type`:            []string{`https://schema.org/Product`},
+			`name`:             []any{`script: Widget`},
+			`sku`:              []any{`script: 123`},
+			`isFamilyFriendly`: []any{`script: true`},
+			`image`:            []any{`script: a.png`, `script: b.png`},
+			`description`:      []any{`script: desc`},
+			`offers`: []any{map[string]any{
+				`@format`: `json-ld`,
+				`@node`:   `script`,
+				`@type`:   []string{`https://schema.org/Offer`, `http://schema.org/Demand`},
+				`price`:   []any{`script: 9.90`},
+			}},
+		},
+		{
+			`@format`:  `json-ld`,
+			`@node`:    `script`,
+			`@type`:    []string{`http://schema.org/Article`},
+			`headline`: []any{`script: H`},
+			`keywords`: []any{`script: a`, `script: b`},
+		},
+		{
+			`@format`: `json-ld`,
+			`@node`:   `script`,
+			`@type`:   []string{`ex:Thing`},
+		},
+		{
+			`@format`: `json-ld`,
+			`@node`:   `script`,
+			`@type`:   []string{`https://example.com/Absolute`},
+		},
+	}); diff != nil {
+		t.Error(diff)
+	}
+	if !items[0].HasType(`Product`) || !items[0].HasType(`https://schema.org/Product`) || items[0].HasType(`Offer`) {
+		t.Error(items[0].Types)
+	}
+	if v := items[0].Items(`offers`); len(v) != 1 || !v[0].HasType(`Demand`) || v[0].Text(`price`) != `9.90` {
+		t.Error(v)
+	}
+	if v := items[0].Text(`offers`); v != `` {
+		t.Error(v)
+	}
+}
+
+func TestMicrodata(t *testing.T) {
+	const input = `<html><body>
+<div itemscope itemtype="https://schema.org/Product https://schema.org/Thing" itemid="urn:1" itemref="ref missing">
+	<span itemprop="name brand">  Widget  </span>
+	<img itemprop="image" src=" a.png ">
+	<a itemprop="url" href="/widget">link</a>
+	<meta itemprop="sku" content="123">
+	<time itemprop="releaseDate" datetime="2020-01-01">January</time>
+	<time itemprop="other">text</time>
+	<data itemprop="gtin" value="0001">one</data>
+	<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+		<span itemprop="price">9.90</span>
+	</div>
+	<div itemprop="">ignored</div>
+</div>
+<p id="ref"><span itemprop="color">red</span></p>
+<div itemscope id="loop" itemref="loop-ref"><div id="loop-ref" itemprop="self" itemscope itemref="loop-ref"><b itemprop="x">x</b></div></div>
+</body></html>`
+	items := Microdata(parse(input))
+	if diff := deep.Equal(simplifyAll(items), []map[string]any{
+		{
+			`@format`:     `microdata`,
+			`@node`:       `div`,
+			`@id`:         `urn:1`,
+			`@type`:       []string{`https://schema.org/Product`, `https://schema.org/Thing`},
+			`name`:        []any{`span: Widget`},
+			`brand`:       []any{`span: Widget`},
+			`image`:       []any{`img: a.png`},
+			`url`:         []any{`a: /widget`},
+			`sku`:         []any{`meta: 123`},
+			`releaseDate`: []any{`time: 2020-01-01`},
+			`other`:       []any{`time: text`},
+			`gtin`:        []any{`data: 0001`},
+			`offers`: []any{map[string]any{
+				`@format`: `microdata`,
+				`@node`:   `div`,
+				`@type`:   []string{`https://schema.org/Offer`},
+				`price`:   []any{`span: 9.90`},
+			}},
+			`color`: []any{`span: red`},
+		},
+		{
+			`@format`: `microdata`,
+			`@node`:   `div`,
+			`self`: []any{map[string]any{
+				`@format`: `microdata`,
+				`@node`:   `div`,
+				`x`:       []any{`b: x`},
+			}},
+		},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRDFa(t *testing.T) {
+	const input = `<html prefix="ex: http://example.com/ns#"><body>
+<div vocab="https://schema.org/" typeof="Product ex:Widget" resource="#product">
+	<span property="name">Widget</span>
+	<a property="url" href="/widget">link</a>
+	<img property="image" src="a.png">
+	<meta property="sku" content="123">
+	<span property="og:title dc:title">T</span>
+	<time property="releaseDate" datetime="2020-01-01">January</time>
+	<div><span property="color">red</span></div>
+	<div property="offers" typeof="Offer">
+		<span property="price">9.90</span>
+	</div>
+	<div typeof="Thing"><span property="name">separate</span></div>
+</div>
+<p typeof="https://example.org/Absolute Unknown"></p>
+</body></html>`
+	items := RDFa(parse(input))
+	if diff := deep.Equal(simplifyAll(items), []map[string]any{
+		{
+			`@format`:     `rdfa`,
+			`@node`:       `div`,
+			`@id`:         `#product`,
+			`@type`:       []string{`https://schema.org/Product`, `http://example.com/ns#Widget`},
+			`name`:        []any{`span: Widget`},
+			`url`:         []any{`a: /widget`},
+			`image`:       []any{`img: a.png`},
+			`sku`:         []any{`meta: 123`},
+			`og:title`:    []any{`span: T`},
+			`dc:title`:    []any{`span: T`},
+			`releaseDate`: []any{`time: 2020-01-01`},
+			`color`:       []any{`span: red`},
+			`offers`: []any{map[string]any{
+				`@format`: `rdfa`,
+				`@node`:   `div`,
+				`@type`:   []string{`https://schema.org/Offer`},
+				`price`:   []any{`span: 9.90`},
+			}},
+		},
+		{
+			`@format`: `rdfa`,
+			`@node`:   `div`,
+			`@type`:   []string{`https://schema.org/Thing`},
+			`name`:    []any{`span: separate`},
+		},
+		{
+			`@format`: `rdfa`,
+			`@node`:   `p`,
+			`@type`:   []string{`https://example.org/Absolute`, `Unknown`},
+		},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	items, err := Extract(parse(`<script type="application/ld+json">{"@type": "A"}</script><div itemscope itemtype="B"></div><div typeof="C"></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var formats, types []string
+	for _, item := range items {
+		formats = append(formats, item.Format.String())
+		types = append(types, item.Types...)
+	}
+	if diff := deep.Equal(formats, []string{`json-ld`, `microdata`, `rdfa`}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(types, []string{`A`, `B`, `C`}); diff != nil {
+		t.Error(diff)
+	}
+	if items, err := Extract(htmlutil.Node{}); items != nil || err != nil {
+		t.Error(items, err)
+	}
+	if v := Format(0).String(); v != `unknown` {
+		t.Error(v)
+	}
+}
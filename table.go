@@ -0,0 +1,295 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"errors"
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Table models a html table element as a rectangular grid of cells, with any `rowspan` and `colspan` attributes
+	// resolved (the cell node being repeated for each grid position it spans), where positions not covered by any
+	// cell contain a node with a nil `Data` field, see `NewTable`
+	Table struct {
+		// Node is the table element
+		Node Node
+
+		cells   [][]tableCell
+		headers int
+		names   []string
+	}
+
+	tableCell struct {
+		node Node
+		// span is true for positions covered by a cell that originated in a previous row or column
+		span bool
+	}
+
+	tableRow struct {
+		node   Node
+		header bool
+		// group identifies the row group (thead, tbody, tfoot, or the table itself), for clamping rowspan
+		group *html.Node
+	}
+)
+
+// NewTable builds a `Table` from a table element, using the rows that are direct children of the table, or of its
+// thead, tbody and tfoot sections (nested tables are ignored), and cells that are td or th elements, where the rows of
+// tfoot sections always follow the other rows (a tfoot may precede the tbody in the source).
+//
+// Header rows are any rows within a thead section, or if there are none, any leading rows consisting entirely of th
+// cells. The name of each column is derived from the words (see the `OuterWords` method) of the header cells in that
+// column, joined by a single space (repeated cells, e.g. from a colspan, are only included once).
+func NewTable(node Node) (*Table, error) {
+	if node.Tag() != `table` || node.Data.Namespace != `` {
+		return nil, errors.New("htmlutil.NewTable not a table element")
+	}
+
+	t := Table{Node: node}
+
+	var rows []tableRow
+	{
+		addRows := func(rows []tableRow, parent Node, header bool) []tableRow {
+			for child := parent.FirstChild(); child.Data != nil; child = child.NextSibling() {
+				if child.Tag() == `tr` {
+					rows = append(rows, tableRow{node: child, header: header, group: parent.Data})
+				}
+			}
+			return rows
+		}
+		var foot []tableRow
+		hasHead := false
+		for child := node.FirstChild(); child.Data != nil; child = child.NextSibling() {
+			switch child.Tag() {
+			case `tr`:
+				rows = append(rows, tableRow{node: child, group: node.Data})
+			case `thead`:
+				hasHead = true
+				rows = addRows(rows, child, true)
+			case `tbody`:
+				rows = addRows(rows, child, false)
+			case `tfoot`:
+				foot = addRows(foot, child, false)
+			}
+		}
+		if !hasHead {
+			for i := range rows {
+				if !tableHeaderRow(rows[i].node) {
+					break
+				}
+				rows[i].header = true
+			}
+		}
+		// header rows always come first, and footer rows (which may precede the body in the source) last
+		var header, body []tableRow
+		for _, row := range rows {
+			if row.header {
+				header = append(header, row)
+			} else {
+				body = append(body, row)
+			}
+		}
+		t.headers = len(header)
+		rows = append(append(header, body...), foot...)
+	}
+
+	// the number of rows remaining in each row group, for clamping rowspan
+	remaining := make([]int, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		remaining[i] = 1
+		if i+1 < len(rows) && rows[i+1].group == rows[i].group && rows[i+1].header == rows[i].header {
+			remaining[i] += remaining[i+1]
+		}
+	}
+
+	width := 0
+	t.cells = make([][]tableCell, len(rows))
+	for i, row := range rows {
+		col := 0
+		for cell := row.node.FirstChild(); cell.Data != nil; cell = cell.NextSibling() {
+			if tag := cell.Tag(); tag != `td` && tag != `th` {
+				continue
+			}
+			for col < len(t.cells[i]) && t.cells[i][col].node.Data != nil {
+				col++
+			}
+			colspan := tableSpan(cell, `colspan`, 1, 1000)
+			rowspan := tableSpan(cell, `rowspan`, 0, 65534)
+			if rowspan == 0 || rowspan > remaining[i] {
+				rowspan = remaining[i]
+			}
+			for r := i; r < i+rowspan; r++ {
+				for len(t.cells[r]) < col+colspan {
+					t.cells[r] = append(t.cells[r], tableCell{})
+				}
+				for c := col; c < col+colspan; c++ {
+					t.cells[r][c] = tableCell{node: cell, span: r != i || c != col}
+				}
+			}
+			col += colspan
+		}
+		if len(t.cells[i]) > width {
+			width = len(t.cells[i])
+		}
+	}
+	for i := range t.cells {
+		for len(t.cells[i]) < width {
+			t.cells[i] = append(t.cells[i], tableCell{})
+		}
+	}
+
+	t.names = make([]string, width)
+	for c := range t.names {
+		var names []string
+		for r := 0; r < t.headers; r++ {
+			cell := t.cells[r][c]
+			if cell.node.Data == nil || (r != 0 && cell.node.Data == t.cells[r-1][c].node.Data) {
+				continue
+			}
+			if name := cell.node.OuterWords(); name != `` {
+				names = append(names, name)
+			}
+		}
+		t.names[c] = strings.Join(names, ` `)
+	}
+
+	return &t, nil
+}
+
+func tableHeaderRow(row Node) bool {
+	ok := false
+	for cell := row.FirstChild(); cell.Data != nil; cell = cell.NextSibling() {
+		switch cell.Tag() {
+		case `th`:
+			ok = true
+		case `td`:
+			return false
+		}
+	}
+	return ok
+}
+
+func tableSpan(cell Node, key string, min int, max int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(cell.GetAttrVal(``, key)))
+	if err != nil || v < 0 {
+		return 1
+	}
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func tableRows(cells [][]tableCell) [][]Node {
+	rows := make([][]Node, len(cells))
+	for i, row := range cells {
+		rows[i] = make([]Node, len(row))
+		for j, cell := range row {
+			rows[i][j] = cell.node
+		}
+	}
+	return rows
+}
+
+// Width returns the number of columns
+func (t *Table) Width() int {
+	return len(t.names)
+}
+
+// Header returns the name of each column (see `NewTable`), which will be empty strings if there were no header rows
+func (t *Table) Header() []string {
+	return append([]string(nil), t.names...)
+}
+
+// HeaderRows returns the grid of cells for the header rows
+func (t *Table) HeaderRows() [][]Node {
+	return tableRows(t.cells[:t.headers])
+}
+
+// Rows returns the grid of cells for the rows that aren't header rows
+func (t *Table) Rows() [][]Node {
+	return tableRows(t.cells[t.headers:])
+}
+
+// Column returns the cells (excluding header rows) for the first column with the given name, or nil if there is no
+// such column
+func (t *Table) Column(name string) []Node {
+	for c, v := range t.names {
+		if v != name {
+			continue
+		}
+		column := make([]Node, 0, len(t.cells)-t.headers)
+		for _, row := range t.cells[t.headers:] {
+			column = append(column, row[c].node)
+		}
+		return column
+	}
+	return nil
+}
+
+// Records returns a map for each row (excluding header rows), from column name to the words of the cell (see the
+// `OuterWords` method), where columns without a name are keyed by their (zero based) index, and duplicate names
+// (e.g. from a header cell with a colspan) are made unique by suffixing each column after the first, e.g. "Price",
+// "Price_2", "Price_3"
+func (t *Table) Records() []map[string]string {
+	keys := tableKeys(t.names)
+	records := make([]map[string]string, 0, len(t.cells)-t.headers)
+	for _, row := range t.cells[t.headers:] {
+		record := make(map[string]string, len(row))
+		for c, cell := range row {
+			record[keys[c]] = cell.node.OuterWords()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// tableKeys maps column names to unique keys, as described by the `Table.Records` method
+func tableKeys(names []string) []string {
+	keys := make([]string, len(names))
+	// reserved is every name (or index), so that suffixed keys can't collide with a later column
+	reserved := make(map[string]struct{}, len(names))
+	for c, key := range names {
+		if key == `` {
+			key = strconv.Itoa(c)
+		}
+		keys[c] = key
+		reserved[key] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(names))
+	for c, key := range keys {
+		if _, ok := seen[key]; ok {
+			for i := 2; ; i++ {
+				v := key + `_` + strconv.Itoa(i)
+				if _, ok := reserved[v]; !ok {
+					key = v
+					break
+				}
+			}
+			reserved[key] = struct{}{}
+		}
+		seen[key] = struct{}{}
+		keys[c] = key
+	}
+	return keys
+}
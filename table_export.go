@@ -0,0 +1,158 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// TableHeader configures how the header (column names) is determined when exporting a table, see `TableOptions`
+type TableHeader int
+
+const (
+	// TableHeaderDetect uses the header rows detected by `NewTable`, if any
+	TableHeaderDetect TableHeader = iota
+	// TableHeaderInfer is like TableHeaderDetect, but will fall back to using the first row, if no header rows were
+	// detected
+	TableHeaderInfer
+	// TableHeaderNone treats every row (including any detected header rows) as data
+	TableHeaderNone
+)
+
+// TableOptions configures `WriteTableCSV` and `WriteTableJSONLines`, where the zero value writes the detected header
+// (if any), repeats the content of cells for every position they span, and uses the `OuterWords` method
+type TableOptions struct {
+	// Header configures how the header is determined
+	Header TableHeader
+	// OmitSpans will leave every position covered by a cell that originated in a previous row or column empty
+	OmitSpans bool
+	// OuterText will use the `OuterText` method for the content of cells, instead of `OuterWords`
+	OuterText bool
+}
+
+// WriteTableCSV writes a table element to w using `encoding/csv`, one row at a time, starting with the header (see
+// `TableOptions`), if any, where every record has the same number of fields
+func WriteTableCSV(w io.Writer, node Node, opts TableOptions) error {
+	writer := csv.NewWriter(w)
+	if err := writeTable(node, opts, func(header []string, row []tableCell) error {
+		if header != nil {
+			return writer.Write(header)
+		}
+		return writer.Write(opts.values(row))
+	}); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteTableJSONLines writes a table element to w as JSON Lines (newline delimited JSON), one row at a time, where
+// each row is an object keyed by column name (see the `Table.Records` method), with keys in column order, or an array
+// of strings if there is no header (see `TableOptions`), note that omitted spans are excluded from objects entirely
+func WriteTableJSONLines(w io.Writer, node Node, opts TableOptions) error {
+	var keys []string
+	return writeTable(node, opts, func(header []string, row []tableCell) error {
+		if header != nil {
+			keys = tableKeys(header)
+			return nil
+		}
+		var b []byte
+		if keys == nil {
+			v, err := json.Marshal(opts.values(row))
+			if err != nil {
+				return err
+			}
+			b = v
+		} else {
+			b = append(b, '{')
+			for c, cell := range row {
+				if opts.OmitSpans && cell.span {
+					continue
+				}
+				if len(b) != 1 {
+					b = append(b, ',')
+				}
+				key, err := json.Marshal(keys[c])
+				if err != nil {
+					return err
+				}
+				val, err := json.Marshal(opts.value(cell))
+				if err != nil {
+					return err
+				}
+				b = append(append(append(b, key...), ':'), val...)
+			}
+			b = append(b, '}')
+		}
+		_, err := w.Write(append(b, '\n'))
+		return err
+	})
+}
+
+// writeTable calls fn with the header (if any), then with each row, stopping on error
+func writeTable(node Node, opts TableOptions, fn func(header []string, row []tableCell) error) error {
+	t, err := NewTable(node)
+	if err != nil {
+		return err
+	}
+
+	var header []string
+	rows := t.cells
+	switch {
+	case opts.Header == TableHeaderNone:
+	case t.headers != 0:
+		header, rows = t.names, rows[t.headers:]
+	case opts.Header == TableHeaderInfer && len(rows) != 0:
+		header = make([]string, len(rows[0]))
+		for c, cell := range rows[0] {
+			header[c] = cell.node.OuterWords()
+		}
+		rows = rows[1:]
+	}
+
+	if header != nil {
+		if err := fn(header, nil); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if err := fn(nil, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o TableOptions) value(cell tableCell) string {
+	if o.OmitSpans && cell.span {
+		return ``
+	}
+	if o.OuterText {
+		return cell.node.OuterText()
+	}
+	return cell.node.OuterWords()
+}
+
+func (o TableOptions) values(row []tableCell) []string {
+	values := make([]string, len(row))
+	for c, cell := range row {
+		values[c] = o.value(cell)
+	}
+	return values
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteTable(t *testing.T) {
+	const (
+		withHeader = `<table>
+	<thead><tr><th>Name</th><th colspan="2">Price</th></tr></thead>
+	<tr><td rowspan="2">a  "one"</td><td>1</td><td>2</td></tr>
+	<tr><td colspan="2">3,
+	4</td></tr>
+</table>`
+		withoutHeader = `<table><tr><td>Name</td><td colspan="2">Price</td></tr><tr><td>a</td><td>1</td><td>2</td></tr></table>`
+	)
+	type TestCase struct {
+		Input string
+		Opts  TableOptions
+		CSV   string
+		JSONL string
+	}
+	for i, testCase := range []TestCase{
+		{
+			Input: withHeader,
+			CSV:   "Name,Price,Price\n\"a \"\"one\"\"\",1,2\n\"a \"\"one\"\"\",\"3, 4\",\"3, 4\"\n",
+			JSONL: `{"Name":"a \"one\"","Price":"1","Price_2":"2"}` + "\n" + `{"Name":"a \"one\"","Price":"3, 4","Price_2":"3, 4"}` + "\n",
+		},
+		{
+			Input: withHeader,
+			Opts:  TableOptions{OmitSpans: true, OuterText: true},
+			CSV:   "Name,Price,Price\n\"a  \"\"one\"\"\",1,2\n,\"3,\n\t4\",\n",
+			JSONL: `{"Name":"a  \"one\"","Price":"1","Price_2":"2"}` + "\n" + `{"Price":"3,\n\t4"}` + "\n",
+		},
+		{
+			Input: withHeader,
+			Opts:  TableOptions{Header: TableHeaderNone, OmitSpans: true},
+			CSV:   "Name,Price,\n\"a \"\"one\"\"\",1,2\n,\"3, 4\",\n",
+			JSONL: `["Name","Price",""]` + "\n" + `["a \"one\"","1","2"]` + "\n" + `["","3, 4",""]` + "\n",
+		},
+		{
+			Input: withoutHeader,
+			CSV:   "Name,Price,Price\na,1,2\n",
+			JSONL: `["Name","Price","Price"]` + "\n" + `["a","1","2"]` + "\n",
+		},
+		{
+			Input: withoutHeader,
+			Opts:  TableOptions{Header: TableHeaderInfer},
+			CSV:   "Name,Price,Price\na,1,2\n",
+			JSONL: `{"Name":"a","Price":"1","Price_2":"2"}` + "\n",
+		},
+		{
+			Input: `<table></table>`,
+			Opts:  TableOptions{Header: TableHeaderInfer},
+		},
+	} {
+		name := fmt.Sprintf("WriteTable_#%d", i+1)
+		node := parse(testCase.Input, Tag(`table`))
+		var b bytes.Buffer
+		if err := WriteTableCSV(&b, node, testCase.Opts); err != nil {
+			t.Error(name, err)
+		} else if v := b.String(); v != testCase.CSV {
+			t.Errorf("%s unexpected csv:\n%s", name, v)
+		}
+		b.Reset()
+		if err := WriteTableJSONLines(&b, node, testCase.Opts); err != nil {
+			t.Error(name, err)
+		} else if v := b.String(); v != testCase.JSONL {
+			t.Errorf("%s unexpected json lines:\n%s", name, v)
+		}
+	}
+}
+
+type tableErrWriter struct {
+	n int
+}
+
+func (w *tableErrWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New(`some error`)
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestWriteTable_errors(t *testing.T) {
+	if err := WriteTableCSV(&bytes.Buffer{}, Node{}, TableOptions{}); err == nil || err.Error() != `htmlutil.NewTable not a table element` {
+		t.Error(err)
+	}
+	if err := WriteTableJSONLines(&bytes.Buffer{}, Node{}, TableOptions{}); err == nil || err.Error() != `htmlutil.NewTable not a table element` {
+		t.Error(err)
+	}
+	node := parse(`<table><tr><th>a</th></tr><tr><td>`+strings.Repeat(`x`, 5000)+`</td></tr><tr><td>2</td></tr></table>`, Tag(`table`))
+	if err := WriteTableCSV(&tableErrWriter{}, node, TableOptions{}); err == nil || err.Error() != `some error` {
+		t.Error(err)
+	}
+	if err := WriteTableJSONLines(&tableErrWriter{n: 1}, node, TableOptions{}); err == nil || err.Error() != `some error` {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"github.com/go-test/deep"
+	"golang.org/x/net/html"
+	"testing"
+)
+
+func tableWords(rows [][]Node) [][]string {
+	result := make([][]string, len(rows))
+	for i, row := range rows {
+		result[i] = make([]string, len(row))
+		for j, cell := range row {
+			result[i][j] = cell.OuterWords()
+			if cell.Data == nil {
+				result[i][j] = `<nil>`
+			}
+		}
+	}
+	return result
+}
+
+func TestNewTable(t *testing.T) {
+	table, err := NewTable(parse(`<table>
+	<tbody><tr><td>a1</td><td rowspan="2">b1 b2</td><td>c1</td></tr>
+	<tr><td colspan="1">a2</td><td>c2</td></tr></tbody>
+	<thead>
+		<tr><th rowspan="2">Name</th><th colspan="2">Price</th></tr>
+		<tr><th>Min</th><th> Max </th></tr>
+	</thead>
+	<tfoot><tr><td colspan="2">a3 b3</td><td rowspan="5">c3</td></tr></tfoot>
+	<tbody><tr><td>a4<table><tr><td>nested</td></tr></table></td></tr></tbody>
+</table>`, Tag(`table`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := table.Width(); v != 3 {
+		t.Error(v)
+	}
+	if diff := deep.Equal(table.Header(), []string{`Name`, `Price Min`, `Price Max`}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(tableWords(table.HeaderRows()), [][]string{
+		{`Name`, `Price`, `Price`},
+		{`Name`, `Min`, `Max`},
+	}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(tableWords(table.Rows()), [][]string{
+		{`a1`, `b1 b2`, `c1`},
+		{`a2`, `b1 b2`, `c2`},
+		{`a4 nested`, `<nil>`, `<nil>`},
+		{`a3 b3`, `a3 b3`, `c3`},
+	}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(tableWords([][]Node{table.Column(`Price Max`)}), [][]string{{`c1`, `c2`, `<nil>`, `c3`}}); diff != nil {
+		t.Error(diff)
+	}
+	if v := table.Column(`Price`); v != nil {
+		t.Error(v)
+	}
+	if diff := deep.Equal(table.Records(), []map[string]string{
+		{`Name`: `a1`, `Price Min`: `b1 b2`, `Price Max`: `c1`},
+		{`Name`: `a2`, `Price Min`: `b1 b2`, `Price Max`: `c2`},
+		{`Name`: `a4 nested`, `Price Min`: ``, `Price Max`: ``},
+		{`Name`: `a3 b3`, `Price Min`: `a3 b3`, `Price Max`: `c3`},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNewTable_thHeader(t *testing.T) {
+	table, err := NewTable(parse(`<table>
+	<tr><th>A</th><th>A</th><th></th></tr>
+	<tr><th>1</th><td colspan="0">2</td><td rowspan="-1">3</td></tr>
+	<tr><th>4</th><td rowspan="0">5</td><td>6</td><td>7</td></tr>
+	<tr><td>8</td></tr>
+</table>`, Tag(`table`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(table.Header(), []string{`A`, `A`, ``, ``}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(tableWords(table.Rows()), [][]string{
+		{`1`, `2`, `3`, `<nil>`},
+		{`4`, `5`, `6`, `7`},
+		{`8`, `5`, `<nil>`, `<nil>`},
+	}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(table.Records(), []map[string]string{
+		{`A`: `1`, `A_2`: `2`, `2`: `3`, `3`: ``},
+		{`A`: `4`, `A_2`: `5`, `2`: `6`, `3`: `7`},
+		{`A`: `8`, `A_2`: `5`, `2`: ``, `3`: ``},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNewTable_tfoot(t *testing.T) {
+	table, err := NewTable(parse(`<table>
+	<tfoot><tr><th>Total</th><td>3</td></tr></tfoot>
+	<tr><th>Name</th><th>Count</th></tr>
+	<tbody><tr><td>a</td><td rowspan="3">1</td></tr><tr><td>b</td></tr></tbody>
+</table>`, Tag(`table`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(table.Header(), []string{`Name`, `Count`}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(table.Records(), []map[string]string{
+		{`Name`: `a`, `Count`: `1`},
+		{`Name`: `b`, `Count`: `1`},
+		{`Name`: `Total`, `Count`: `3`},
+	}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTableKeys(t *testing.T) {
+	if diff := deep.Equal(tableKeys([]string{`a`, `a`, `a_2`, ``, `3`, `a`}), []string{`a`, `a_3`, `a_2`, `3`, `3_2`, `a_4`}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNewTable_empty(t *testing.T) {
+	table, err := NewTable(parse(`<table></table>`, Tag(`table`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Width() != 0 || table.Header() != nil || len(table.Rows()) != 0 || len(table.Records()) != 0 {
+		t.Error(table)
+	}
+	for _, node := range []Node{{}, parse(`<div></div>`, Tag(`div`)), {Data: &html.Node{Type: html.ElementNode, Data: `table`, Namespace: `svg`}}} {
+		if table, err := NewTable(node); table != nil || err == nil || err.Error() != `htmlutil.NewTable not a table element` {
+			t.Error(table, err)
+		}
+	}
+}
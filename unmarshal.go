@@ -0,0 +1,284 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	unmarshaler struct {
+		selectors map[string][]func(node Node) bool
+	}
+
+	unmarshalTag struct {
+		selector string
+		attr     string
+		text     string
+		layout   string
+	}
+)
+
+var (
+	unmarshalNodeType          = reflect.TypeFor[Node]()
+	unmarshalTimeType          = reflect.TypeFor[time.Time]()
+	unmarshalTextUnmarshalType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Unmarshal populates v, which must be a non-nil pointer, from node, using struct tags to resolve the values of
+// fields, e.g. `htmlutil:"selector=.price,attr=data-value"`, where fields without an htmlutil tag (or with a tag of
+// "-") are ignored.
+//
+// Tag options (comma separated, all optional)
+//
+//   - selector: a CSS selector (see `Compile`), resolved relative to the node of the parent struct (which may itself
+//     match), using `FindNode`, or `FilterNodes` for slice fields, or the parent's node if omitted
+//   - attr: the name of an attribute to use as the value, instead of the text
+//   - text: one of "words" (`OuterWords`, the default), "text" (`OuterText`), or "html" (`OuterHTML`)
+//   - layout: the `time.Parse` layout for `time.Time` fields (defaults to `time.RFC3339`)
+//
+// Supported field types are strings, numbers, bools, `time.Time`, any `encoding.TextUnmarshaler`, `Node`, nested
+// structs (populated from the resolved node), pointers to any of these (allocated only if the value is present), and
+// slices of any of these (which require a selector). Fields are left unmodified if the node (or attribute) could not
+// be found, and empty values are also ignored, except for bools, which are true if present, unless the value is
+// parseable by `strconv.ParseBool`. Any error returned will include the path to the field.
+func Unmarshal(node Node, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("htmlutil.Unmarshal non-nil pointer required")
+	}
+	if node.Data == nil {
+		return nil
+	}
+	_, err := (&unmarshaler{}).value(node, unmarshalTag{}, rv.Elem(), rv.Elem().Type().String())
+	return err
+}
+
+func parseUnmarshalTag(s string) (tag unmarshalTag, err error) {
+	if s == `` {
+		return tag, nil
+	}
+	// selectors may contain commas, so segments that don't start with a known key belong to the previous one
+	var parts []string
+	for _, part := range strings.Split(s, `,`) {
+		key, _, _ := strings.Cut(part, `=`)
+		switch strings.TrimSpace(key) {
+		case `selector`, `attr`, `text`, `layout`:
+			parts = append(parts, part)
+			continue
+		}
+		if len(parts) == 0 {
+			return tag, fmt.Errorf("invalid tag option %q", part)
+		}
+		parts[len(parts)-1] += `,` + part
+	}
+	for _, part := range parts {
+		key, val, ok := strings.Cut(part, `=`)
+		if !ok {
+			return tag, fmt.Errorf("invalid tag option %q", part)
+		}
+		val = strings.TrimSpace(val)
+		switch strings.TrimSpace(key) {
+		case `selector`:
+			tag.selector = val
+		case `attr`:
+			tag.attr = val
+		case `text`:
+			switch val {
+			case `words`, `text`, `html`:
+			default:
+				return tag, fmt.Errorf("invalid text option %q", val)
+			}
+			tag.text = val
+		case `layout`:
+			tag.layout = val
+		}
+	}
+	return tag, nil
+}
+
+func (d *unmarshaler) compile(selector string) ([]func(node Node) bool, error) {
+	if filters, ok := d.selectors[selector]; ok {
+		return filters, nil
+	}
+	filters, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	if d.selectors == nil {
+		d.selectors = make(map[string][]func(node Node) bool)
+	}
+	d.selectors[selector] = filters
+	return filters, nil
+}
+
+func (d *unmarshaler) fields(node Node, rv reflect.Value, path string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		s, ok := field.Tag.Lookup(`htmlutil`)
+		if !ok || s == `-` || !field.IsExported() {
+			continue
+		}
+		path := path + `.` + field.Name
+		tag, err := parseUnmarshalTag(s)
+		if err != nil {
+			return fmt.Errorf("htmlutil.Unmarshal %s: %w", path, err)
+		}
+		var filters []func(node Node) bool
+		if tag.selector != `` {
+			if filters, err = d.compile(tag.selector); err != nil {
+				return fmt.Errorf("htmlutil.Unmarshal %s: %w", path, err)
+			}
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Slice && !unmarshalScalar(fv.Type()) {
+			if filters == nil {
+				return fmt.Errorf("htmlutil.Unmarshal %s: selector required for slice", path)
+			}
+			nodes := node.FilterNodes(filters...)
+			slice := reflect.MakeSlice(fv.Type(), len(nodes), len(nodes))
+			for j, node := range nodes {
+				if _, err := d.value(node, tag, slice.Index(j), fmt.Sprintf("%s[%d]", path, j)); err != nil {
+					return err
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+		node := node
+		if filters != nil {
+			var ok bool
+			if node, ok = node.FindNode(filters...); !ok {
+				continue
+			}
+		}
+		if _, err := d.value(node, tag, fv, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalScalar returns true if t should be populated from a single value, even if it is a slice
+func unmarshalScalar(t reflect.Type) bool {
+	return t == unmarshalNodeType ||
+		t == unmarshalTimeType ||
+		reflect.PointerTo(t).Implements(unmarshalTextUnmarshalType)
+}
+
+// value populates rv from the (already resolved) node, returning true if it was set
+func (d *unmarshaler) value(node Node, tag unmarshalTag, rv reflect.Value, path string) (bool, error) {
+	rt := rv.Type()
+
+	if rt.Kind() == reflect.Pointer {
+		elem := reflect.New(rt.Elem())
+		ok, err := d.value(node, tag, elem.Elem(), path)
+		if ok {
+			rv.Set(elem)
+		}
+		return ok, err
+	}
+
+	if rt == unmarshalNodeType {
+		rv.Set(reflect.ValueOf(node))
+		return true, nil
+	}
+
+	if rt.Kind() == reflect.Struct && rt != unmarshalTimeType && !reflect.PointerTo(rt).Implements(unmarshalTextUnmarshalType) {
+		return true, d.fields(node, rv, path)
+	}
+
+	s, ok := d.raw(node, tag)
+
+	if rt.Kind() == reflect.Bool {
+		if !ok {
+			return false, nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		rv.SetBool(b || err != nil)
+		return true, nil
+	}
+
+	if rt.Kind() != reflect.String {
+		s = strings.TrimSpace(s)
+	}
+	if !ok || s == `` {
+		return false, nil
+	}
+
+	var err error
+	if rt == unmarshalTimeType && tag.layout != `` {
+		var t time.Time
+		if t, err = time.Parse(tag.layout, s); err == nil {
+			rv.Set(reflect.ValueOf(t))
+		}
+	} else if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		err = u.UnmarshalText([]byte(s))
+	} else {
+		switch rt.Kind() {
+		case reflect.String:
+			rv.SetString(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var v int64
+			if v, err = strconv.ParseInt(s, 10, rt.Bits()); err == nil {
+				rv.SetInt(v)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			var v uint64
+			if v, err = strconv.ParseUint(s, 10, rt.Bits()); err == nil {
+				rv.SetUint(v)
+			}
+		case reflect.Float32, reflect.Float64:
+			var v float64
+			if v, err = strconv.ParseFloat(s, rt.Bits()); err == nil {
+				rv.SetFloat(v)
+			}
+		default:
+			err = fmt.Errorf("unsupported type %s", rt)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("htmlutil.Unmarshal %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// raw returns the string value of node, as configured by tag, or false if the node or attribute doesn't exist
+func (d *unmarshaler) raw(node Node, tag unmarshalTag) (string, bool) {
+	if node.Data == nil {
+		return ``, false
+	}
+	if tag.attr != `` {
+		attr, ok := node.GetAttr(``, tag.attr)
+		return attr.Val, ok
+	}
+	switch tag.text {
+	case `text`:
+		return node.OuterText(), true
+	case `html`:
+		return node.OuterHTML(), true
+	default:
+		return node.OuterWords(), true
+	}
+}
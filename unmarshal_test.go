@@ -0,0 +1,216 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package htmlutil
+
+import (
+	"errors"
+	"github.com/go-test/deep"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type (
+	unmarshalProduct struct {
+		Name     string             `htmlutil:"selector=h2"`
+		Price    float64            `htmlutil:"selector=.price,attr=data-value"`
+		Currency *string            `htmlutil:"selector=.price,attr=data-currency"`
+		Discount *int               `htmlutil:"selector=.discount"`
+		Stock    uint8              `htmlutil:"selector=.stock"`
+		InStock  bool               `htmlutil:"selector=.in-stock"`
+		Featured bool               `htmlutil:"attr=data-featured"`
+		Sale     bool               `htmlutil:"selector=.sale"`
+		Added    time.Time          `htmlutil:"selector=time,attr=datetime"`
+		Updated  time.Time          `htmlutil:"selector=.updated,layout=2006-01-02"`
+		Tags     []string           `htmlutil:"selector=.tags li, .tags a"`
+		Ratings  []int              `htmlutil:"selector=.rating,attr=data-stars"`
+		Seller   unmarshalSeller    `htmlutil:"selector=.seller"`
+		Related  []*unmarshalSeller `htmlutil:"selector=.related"`
+		Raw      string             `htmlutil:"selector=.desc,text=text"`
+		HTML     string             `htmlutil:"selector=.desc b,text=html"`
+		Node     Node               `htmlutil:"selector=h2"`
+		Missing  string             `htmlutil:"selector=.missing"`
+		Server   netip.Addr         `htmlutil:"selector=.server"`
+		Ignored  string             `htmlutil:"-"`
+		Untagged string
+		private  string `htmlutil:"selector=h2"`
+	}
+
+	unmarshalSeller struct {
+		Name string `htmlutil:"selector=a"`
+		URL  string `htmlutil:"selector=a,attr=href"`
+	}
+)
+
+func TestUnmarshal(t *testing.T) {
+	const input = `<div class="product" data-featured="true">
+	<h2> Some  Product </h2>
+	<span class="price" data-value="12.50" data-currency="AUD">$12.50</span>
+	<span class="discount">0</span>
+	<span class="stock"> 7 </span>
+	<span class="in-stock">Yes!</span>
+	<span class="sale">false</span>
+	<time datetime="2019-08-20T10:00:00Z">last week</time>
+	<span class="updated">2019-08-21</span>
+	<ul class="tags"><li>one</li><li>two</li></ul>
+	<p class="tags"><a href="#">three</a></p>
+	<span class="rating" data-stars="4"></span><span class="rating" data-stars="5"></span>
+	<div class="seller"><a href="/seller/1">Seller One</a></div>
+	<div class="related"><a href="/seller/2">Seller Two</a></div>
+	<div class="related"></div>
+	<p class="desc">some <b>bold</b>
+	text</p>
+	<span class="server">127.0.0.1</span>
+</div>`
+	var (
+		actual   = unmarshalProduct{Ignored: `ignored`, Untagged: `untagged`}
+		currency = `AUD`
+		discount = 0
+	)
+	if err := Unmarshal(parse(input, Class(`product`)), &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.Node.Tag() != `h2` {
+		t.Error(actual.Node)
+	}
+	actual.Node = Node{}
+	if diff := deep.Equal(actual, unmarshalProduct{
+		Name:     `Some Product`,
+		Price:    12.5,
+		Currency: &currency,
+		Discount: &discount,
+		Stock:    7,
+		InStock:  true,
+		Featured: true,
+		Sale:     false,
+		Added:    time.Date(2019, 8, 20, 10, 0, 0, 0, time.UTC),
+		Updated:  time.Date(2019, 8, 21, 0, 0, 0, 0, time.UTC),
+		Tags:     []string{`one`, `two`, `three`},
+		Ratings:  []int{4, 5},
+		Seller:   unmarshalSeller{Name: `Seller One`, URL: `/seller/1`},
+		Related:  []*unmarshalSeller{{Name: `Seller Two`, URL: `/seller/2`}, {}},
+		Raw:      "some bold\n\ttext",
+		HTML:     `<b>bold</b>`,
+		Server:   netip.MustParseAddr(`127.0.0.1`),
+		Ignored:  `ignored`,
+		Untagged: `untagged`,
+	}); diff != nil {
+		t.Error(diff)
+	}
+	if actual.private != `` {
+		t.Error(actual.private)
+	}
+}
+
+func TestUnmarshal_scalar(t *testing.T) {
+	var actual *string
+	if err := Unmarshal(parse(`<p>some <b>words</b></p>`, Tag(`p`)), &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual == nil || *actual != `some words` {
+		t.Error(actual)
+	}
+	var empty string
+	if err := Unmarshal(Node{}, &empty); err != nil || empty != `` {
+		t.Error(err, empty)
+	}
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	node := parse(`<p class="n">abc</p><ul><li>1</li><li>x</li></ul>`)
+	var selectorError *SelectorError
+	for i, testCase := range []struct {
+		V   any
+		Err string
+		Is  func(err error) bool
+	}{
+		{
+			V:   struct{}{},
+			Err: `htmlutil.Unmarshal non-nil pointer required`,
+		},
+		{
+			V:   (*struct{})(nil),
+			Err: `htmlutil.Unmarshal non-nil pointer required`,
+		},
+		{
+			V: &struct {
+				N int `htmlutil:"selector=.n"`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N int "htmlutil:\"selector=.n\"" }.N: strconv.ParseInt: parsing "abc": invalid syntax`,
+			Is: func(err error) bool {
+				return errors.Is(err, strconv.ErrSyntax)
+			},
+		},
+		{
+			V: &struct {
+				N []uint `htmlutil:"selector=li"`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N []uint "htmlutil:\"selector=li\"" }.N[1]: strconv.ParseUint: parsing "x": invalid syntax`,
+		},
+		{
+			V: &struct {
+				N []string `htmlutil:""`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N []string "htmlutil:\"\"" }.N: selector required for slice`,
+		},
+		{
+			V: &struct {
+				N string `htmlutil:"selector=p::before"`
+			}{},
+			Is: func(err error) bool {
+				return errors.As(err, &selectorError)
+			},
+		},
+		{
+			V: &struct {
+				N string `htmlutil:"bad=p"`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N string "htmlutil:\"bad=p\"" }.N: invalid tag option "bad=p"`,
+		},
+		{
+			V: &struct {
+				N string `htmlutil:"text=inner"`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N string "htmlutil:\"text=inner\"" }.N: invalid text option "inner"`,
+		},
+		{
+			V: &struct {
+				N time.Time `htmlutil:"selector=.n,layout=2006"`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N time.Time "htmlutil:\"selector=.n,layout=2006\"" }.N: parsing time "abc" as "2006": cannot parse "abc" as "2006"`,
+		},
+		{
+			V: &struct {
+				N complex64 `htmlutil:"selector=.n"`
+			}{},
+			Err: `htmlutil.Unmarshal struct { N complex64 "htmlutil:\"selector=.n\"" }.N: unsupported type complex64`,
+		},
+	} {
+		err := Unmarshal(node, testCase.V)
+		if err == nil {
+			t.Error(i, `expected an error`)
+			continue
+		}
+		if testCase.Err != `` && err.Error() != testCase.Err {
+			t.Error(i, err)
+		}
+		if testCase.Is != nil && !testCase.Is(err) {
+			t.Error(i, err)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package xpath
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"math"
+	"sort"
+	"strings"
+)
+
+type (
+	// expr is a node in the AST, evaluating to one of nodeSet, string, float64 or bool
+	expr interface {
+		eval(c context) (any, error)
+	}
+
+	// xnode is a node in the XPath data model, where attr is the index of an attribute of the element n, or -1
+	xnode struct {
+		n     *html.Node
+		attr  int
+		depth int
+	}
+
+	nodeSet []xnode
+
+	context struct {
+		node xnode
+		pos  int
+		size int
+		ev   *evaluator
+	}
+
+	evaluator struct {
+		node  htmlutil.Node
+		root  xnode
+		vars  map[string]any
+		order map[*html.Node]int
+	}
+
+	binaryExpr struct {
+		op          tokenKind
+		left, right expr
+	}
+
+	negateExpr struct {
+		expr expr
+	}
+
+	literalExpr struct {
+		value string
+	}
+
+	numberExpr struct {
+		value float64
+	}
+
+	variableExpr struct {
+		name string
+	}
+
+	filterExpr struct {
+		primary    expr
+		predicates []expr
+	}
+
+	pathExpr struct {
+		// filter is the (optional) expression the steps are relative to
+		filter   expr
+		absolute bool
+		steps    []step
+	}
+)
+
+func newEvaluator(node htmlutil.Node, variables map[string]any) (*evaluator, error) {
+	ev := &evaluator{
+		node: node,
+		vars: make(map[string]any, len(variables)),
+	}
+	for k, v := range variables {
+		switch x := v.(type) {
+		case []htmlutil.Node:
+			var ns nodeSet
+			for _, n := range x {
+				if n.Data != nil {
+					ns = append(ns, xnode{n: n.Data, attr: -1, depth: n.Depth})
+				}
+			}
+			ev.vars[k] = ns
+		case string, float64, bool:
+			ev.vars[k] = x
+		case int:
+			ev.vars[k] = float64(x)
+		case int64:
+			ev.vars[k] = float64(x)
+		case float32:
+			ev.vars[k] = float64(x)
+		default:
+			return nil, fmt.Errorf("xpath.Expr.EvaluateWith unsupported variable type %T for $%s", v, k)
+		}
+	}
+	if node.Data != nil {
+		ev.root = xnode{n: node.Data, attr: -1, depth: node.Depth}
+		for ev.root.n.Parent != nil {
+			ev.root.n = ev.root.n.Parent
+			ev.root.depth--
+		}
+	}
+	return ev, nil
+}
+
+func (ev *evaluator) evaluate(e expr) (any, error) {
+	c := context{pos: 1, size: 1, ev: ev}
+	if ev.node.Data == nil {
+		c.size = 0
+	} else {
+		c.node = xnode{n: ev.node.Data, attr: -1, depth: ev.node.Depth}
+	}
+	return e.eval(c)
+}
+
+// nodes converts a node-set to the public representation
+func (ev *evaluator) nodes(ns nodeSet) []htmlutil.Node {
+	if len(ns) == 0 {
+		return nil
+	}
+	match := ev.node
+	result := make([]htmlutil.Node, len(ns))
+	for i, x := range ns {
+		result[i] = htmlutil.Node{Data: x.n, Depth: x.depth, Match: &match}
+		if x.attr >= 0 {
+			result[i].Data = &html.Node{
+				Type:   html.TextNode,
+				Data:   x.n.Attr[x.attr].Val,
+				Parent: x.n,
+			}
+		}
+	}
+	return result
+}
+
+// documentOrder returns a key that may be used to sort nodes in document order, where attributes of an element sort
+// after the element but before it's children
+func (ev *evaluator) documentOrder(x xnode) int {
+	if ev.order == nil {
+		ev.order = make(map[*html.Node]int)
+		var (
+			i    int
+			walk func(n *html.Node)
+		)
+		walk = func(n *html.Node) {
+			ev.order[n] = i
+			i += 1 + len(n.Attr)
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(ev.root.n)
+	}
+	return ev.order[x.n] + 1 + x.attr
+}
+
+// sort orders ns in document order, removing any duplicates
+func (ev *evaluator) sort(ns nodeSet) nodeSet {
+	if len(ns) < 2 {
+		return ns
+	}
+	sort.SliceStable(ns, func(i, j int) bool {
+		return ev.documentOrder(ns[i]) < ev.documentOrder(ns[j])
+	})
+	result := ns[:1]
+	for _, x := range ns[1:] {
+		if last := result[len(result)-1]; last.n != x.n || last.attr != x.attr {
+			result = append(result, x)
+		}
+	}
+	return result
+}
+
+func (e *binaryExpr) eval(c context) (any, error) {
+	left, err := e.left.eval(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case tokOr, tokAnd:
+		if toBoolean(left) == (e.op == tokOr) {
+			return e.op == tokOr, nil
+		}
+		right, err := e.right.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		return toBoolean(right), nil
+	}
+
+	right, err := e.right.eval(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case tokPipe:
+		l, lok := left.(nodeSet)
+		r, rok := right.(nodeSet)
+		if !lok || !rok {
+			return nil, errors.New("xpath.Expr.Evaluate union of non node-set")
+		}
+		return c.ev.sort(append(append(nodeSet(nil), l...), r...)), nil
+	case tokEq, tokNeq, tokLt, tokLte, tokGt, tokGte:
+		return compare(e.op, left, right), nil
+	}
+
+	l, r := toNumber(left), toNumber(right)
+	switch e.op {
+	case tokPlus:
+		return l + r, nil
+	case tokMinus:
+		return l - r, nil
+	case tokMultiply:
+		return l * r, nil
+	case tokDiv:
+		return l / r, nil
+	default:
+		return math.Mod(l, r), nil
+	}
+}
+
+func (e *negateExpr) eval(c context) (any, error) {
+	v, err := e.expr.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return -toNumber(v), nil
+}
+
+func (e *literalExpr) eval(c context) (any, error) {
+	return e.value, nil
+}
+
+func (e *numberExpr) eval(c context) (any, error) {
+	return e.value, nil
+}
+
+func (e *variableExpr) eval(c context) (any, error) {
+	if v, ok := c.ev.vars[e.name]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("xpath.Expr.Evaluate undefined variable $%s", e.name)
+}
+
+func (e *filterExpr) eval(c context) (any, error) {
+	v, err := e.primary.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	ns, ok := v.(nodeSet)
+	if !ok {
+		return nil, errors.New("xpath.Expr.Evaluate predicate applied to non node-set")
+	}
+	for _, predicate := range e.predicates {
+		if ns, err = applyPredicate(c.ev, ns, predicate); err != nil {
+			return nil, err
+		}
+	}
+	return ns, nil
+}
+
+func (e *pathExpr) eval(c context) (any, error) {
+	var ns nodeSet
+	switch {
+	case e.filter != nil:
+		v, err := e.filter.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if ns, ok = v.(nodeSet); !ok {
+			return nil, errors.New("xpath.Expr.Evaluate path applied to non node-set")
+		}
+	case c.size == 0:
+		// no context node
+	case e.absolute:
+		ns = nodeSet{c.ev.root}
+	default:
+		ns = nodeSet{c.node}
+	}
+	for _, s := range e.steps {
+		var (
+			result nodeSet
+			err    error
+		)
+		for _, x := range ns {
+			var selected nodeSet
+			s.axis.walk(x, func(x xnode) {
+				if s.test.matches(x, s.axis) {
+					selected = append(selected, x)
+				}
+			})
+			for _, predicate := range s.predicates {
+				if selected, err = applyPredicate(c.ev, selected, predicate); err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, selected...)
+		}
+		ns = c.ev.sort(result)
+	}
+	return ns, nil
+}
+
+// applyPredicate filters ns (which must be in the order of the relevant axis) by predicate
+func applyPredicate(ev *evaluator, ns nodeSet, predicate expr) (nodeSet, error) {
+	var result nodeSet
+	for i, x := range ns {
+		v, err := predicate.eval(context{node: x, pos: i + 1, size: len(ns), ev: ev})
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := v.(float64); ok {
+			if n == float64(i+1) {
+				result = append(result, x)
+			}
+		} else if toBoolean(v) {
+			result = append(result, x)
+		}
+	}
+	return result, nil
+}
+
+func (t nodeTest) matches(x xnode, a axis) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return x.attr < 0 && (x.n.Type == html.TextNode || x.n.Type == html.RawNode)
+	case testComment:
+		return x.attr < 0 && x.n.Type == html.CommentNode
+	case testProcessingInstruction:
+		return false
+	}
+	if a == axisAttribute {
+		if x.attr < 0 {
+			return false
+		}
+		attr := x.n.Attr[x.attr]
+		if attr.Namespace != t.prefix {
+			return false
+		}
+		if t.local == `*` {
+			return true
+		}
+		if attr.Namespace == `` {
+			return strings.EqualFold(attr.Key, t.local)
+		}
+		return attr.Key == t.local
+	}
+	if x.attr >= 0 || x.n.Type != html.ElementNode {
+		return false
+	}
+	if t.prefix != `` && x.n.Namespace != t.prefix {
+		return false
+	}
+	return t.local == `*` || x.n.Data == t.local
+}
+
+// walk calls fn with each node on the axis from x, in axis order (reverse document order for reverse axes)
+func (a axis) walk(x xnode, fn func(x xnode)) {
+	switch a {
+	case axisSelf:
+		fn(x)
+	case axisChild:
+		if x.attr < 0 {
+			walkChildren(x, fn)
+		}
+	case axisDescendantOrSelf:
+		fn(x)
+		fallthrough
+	case axisDescendant:
+		if x.attr < 0 {
+			walkDescendants(x, fn)
+		}
+	case axisParent:
+		if p, ok := parent(x); ok {
+			fn(p)
+		}
+	case axisAncestorOrSelf:
+		fn(x)
+		fallthrough
+	case axisAncestor:
+		for p, ok := parent(x); ok; p, ok = parent(p) {
+			fn(p)
+		}
+	case axisFollowingSibling:
+		if x.attr < 0 {
+			for n := x.n.NextSibling; n != nil; n = n.NextSibling {
+				if n.Type != html.DoctypeNode {
+					fn(xnode{n: n, attr: -1, depth: x.depth})
+				}
+			}
+		}
+	case axisPrecedingSibling:
+		if x.attr < 0 {
+			for n := x.n.PrevSibling; n != nil; n = n.PrevSibling {
+				if n.Type != html.DoctypeNode {
+					fn(xnode{n: n, attr: -1, depth: x.depth})
+				}
+			}
+		}
+	case axisFollowing:
+		if x.attr >= 0 {
+			x = xnode{n: x.n, attr: -1, depth: x.depth - 1}
+			walkDescendants(x, fn)
+		}
+		for ; x.n != nil; x = (xnode{n: x.n.Parent, attr: -1, depth: x.depth - 1}) {
+			for n := x.n.NextSibling; n != nil; n = n.NextSibling {
+				if n.Type != html.DoctypeNode {
+					s := xnode{n: n, attr: -1, depth: x.depth}
+					fn(s)
+					walkDescendants(s, fn)
+				}
+			}
+		}
+	case axisPreceding:
+		if x.attr >= 0 {
+			x = xnode{n: x.n, attr: -1, depth: x.depth - 1}
+		}
+		for ; x.n != nil; x = (xnode{n: x.n.Parent, attr: -1, depth: x.depth - 1}) {
+			for n := x.n.PrevSibling; n != nil; n = n.PrevSibling {
+				if n.Type != html.DoctypeNode {
+					s := xnode{n: n, attr: -1, depth: x.depth}
+					walkDescendantsReverse(s, fn)
+					fn(s)
+				}
+			}
+		}
+	case axisAttribute:
+		if x.attr < 0 && x.n.Type == html.ElementNode {
+			for i := range x.n.Attr {
+				fn(xnode{n: x.n, attr: i, depth: x.depth + 1})
+			}
+		}
+	}
+}
+
+func parent(x xnode) (xnode, bool) {
+	if x.attr >= 0 {
+		return xnode{n: x.n, attr: -1, depth: x.depth - 1}, true
+	}
+	if x.n.Parent == nil {
+		return xnode{}, false
+	}
+	return xnode{n: x.n.Parent, attr: -1, depth: x.depth - 1}, true
+}
+
+func walkChildren(x xnode, fn func(x xnode)) {
+	for n := x.n.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type != html.DoctypeNode {
+			fn(xnode{n: n, attr: -1, depth: x.depth + 1})
+		}
+	}
+}
+
+func walkDescendants(x xnode, fn func(x xnode)) {
+	walkChildren(x, func(x xnode) {
+		fn(x)
+		walkDescendants(x, fn)
+	})
+}
+
+func walkDescendantsReverse(x xnode, fn func(x xnode)) {
+	for n := x.n.LastChild; n != nil; n = n.PrevSibling {
+		if n.Type != html.DoctypeNode {
+			c := xnode{n: n, attr: -1, depth: x.depth + 1}
+			walkDescendantsReverse(c, fn)
+			fn(c)
+		}
+	}
+}
+
+// compare implements the comparison operators as per section 3.4 of the spec
+func compare(op tokenKind, left, right any) bool {
+	if l, ok := left.(nodeSet); ok {
+		if r, ok := right.(nodeSet); ok {
+			for _, x := range l {
+				lv := stringValue(x)
+				for _, y := range r {
+					if compareAtomic(op, lv, stringValue(y)) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		if b, ok := right.(bool); ok {
+			return compareAtomic(op, len(l) != 0, b)
+		}
+		for _, x := range l {
+			var v any = stringValue(x)
+			if _, ok := right.(float64); ok {
+				v = toNumber(v)
+			}
+			if compareAtomic(op, v, right) {
+				return true
+			}
+		}
+		return false
+	}
+	if _, ok := right.(nodeSet); ok {
+		return compare(reverseOp(op), right, left)
+	}
+	return compareAtomic(op, left, right)
+}
+
+func reverseOp(op tokenKind) tokenKind {
+	switch op {
+	case tokLt:
+		return tokGt
+	case tokLte:
+		return tokGte
+	case tokGt:
+		return tokLt
+	case tokGte:
+		return tokLte
+	}
+	return op
+}
+
+func compareAtomic(op tokenKind, left, right any) bool {
+	switch op {
+	case tokEq, tokNeq:
+		var equal bool
+		_, lb := left.(bool)
+		_, rb := right.(bool)
+		_, ln := left.(float64)
+		_, rn := right.(float64)
+		switch {
+		case lb || rb:
+			equal = toBoolean(left) == toBoolean(right)
+		case ln || rn:
+			equal = toNumber(left) == toNumber(right)
+		default:
+			equal = toString(left) == toString(right)
+		}
+		if op == tokEq {
+			return equal
+		}
+		return !equal
+	}
+	l, r := toNumber(left), toNumber(right)
+	switch op {
+	case tokLt:
+		return l < r
+	case tokLte:
+		return l <= r
+	case tokGt:
+		return l > r
+	default:
+		return l >= r
+	}
+}
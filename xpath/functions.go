@@ -0,0 +1,401 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package xpath
+
+import (
+	"fmt"
+	"github.com/joeycumines/go-htmlutil"
+	"golang.org/x/net/html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type (
+	function struct {
+		// min and max are the bounds for the number of arguments, where max is -1 for no limit
+		min, max int
+		call     func(c context, args []any) (any, error)
+	}
+
+	functionCall struct {
+		name string
+		fn   function
+		args []expr
+	}
+)
+
+// functions is the core function library, see section 4 of the spec
+var functions = map[string]function{
+	// node set functions
+	`last`: {0, 0, func(c context, args []any) (any, error) {
+		return float64(c.size), nil
+	}},
+	`position`: {0, 0, func(c context, args []any) (any, error) {
+		return float64(c.pos), nil
+	}},
+	`count`: {1, 1, func(c context, args []any) (any, error) {
+		ns, err := nodeSetArg(`count`, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return float64(len(ns)), nil
+	}},
+	`id`: {1, 1, func(c context, args []any) (any, error) {
+		var ids []string
+		if ns, ok := args[0].(nodeSet); ok {
+			for _, x := range ns {
+				ids = append(ids, strings.FieldsFunc(stringValue(x), isSpace)...)
+			}
+		} else {
+			ids = strings.FieldsFunc(toString(args[0]), isSpace)
+		}
+		var result nodeSet
+		if c.ev.root.n == nil || len(ids) == 0 {
+			return result, nil
+		}
+		match := func(x xnode) {
+			if x.n.Type != html.ElementNode {
+				return
+			}
+			id := htmlutil.Node{Data: x.n}.GetAttrVal(``, `id`)
+			for _, v := range ids {
+				if v == id {
+					result = append(result, x)
+					return
+				}
+			}
+		}
+		// the root may be an element, e.g. a detached tree
+		match(c.ev.root)
+		walkDescendants(c.ev.root, match)
+		return result, nil
+	}},
+	`local-name`: {0, 1, nameFunction(`local-name`, func(x xnode) string {
+		if x.attr >= 0 {
+			return x.n.Attr[x.attr].Key
+		}
+		if x.n.Type == html.ElementNode {
+			return x.n.Data
+		}
+		return ``
+	})},
+	`namespace-uri`: {0, 1, nameFunction(`namespace-uri`, func(x xnode) string {
+		if x.attr >= 0 {
+			return namespaceURIs[x.n.Attr[x.attr].Namespace]
+		}
+		if x.n.Type == html.ElementNode {
+			if x.n.Namespace == `` {
+				return namespaceURIs[`html`]
+			}
+			return namespaceURIs[x.n.Namespace]
+		}
+		return ``
+	})},
+	`name`: {0, 1, nameFunction(`name`, func(x xnode) string {
+		var namespace, local string
+		if x.attr >= 0 {
+			namespace, local = x.n.Attr[x.attr].Namespace, x.n.Attr[x.attr].Key
+		} else if x.n.Type == html.ElementNode {
+			namespace, local = x.n.Namespace, x.n.Data
+		}
+		if namespace != `` {
+			return namespace + `:` + local
+		}
+		return local
+	})},
+
+	// string functions
+	`string`: {0, 1, func(c context, args []any) (any, error) {
+		return toString(contextArg(c, args)), nil
+	}},
+	`concat`: {2, -1, func(c context, args []any) (any, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(toString(arg))
+		}
+		return b.String(), nil
+	}},
+	`starts-with`: {2, 2, func(c context, args []any) (any, error) {
+		return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+	}},
+	`contains`: {2, 2, func(c context, args []any) (any, error) {
+		return strings.Contains(toString(args[0]), toString(args[1])), nil
+	}},
+	`substring-before`: {2, 2, func(c context, args []any) (any, error) {
+		s, sep := toString(args[0]), toString(args[1])
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[:i], nil
+		}
+		return ``, nil
+	}},
+	`substring-after`: {2, 2, func(c context, args []any) (any, error) {
+		s, sep := toString(args[0]), toString(args[1])
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[i+len(sep):], nil
+		}
+		return ``, nil
+	}},
+	`substring`: {2, 3, func(c context, args []any) (any, error) {
+		var (
+			s     = []rune(toString(args[0]))
+			start = round(toNumber(args[1]))
+			end   = math.Inf(1)
+			b     strings.Builder
+		)
+		if len(args) == 3 {
+			end = start + round(toNumber(args[2]))
+		}
+		for i, r := range s {
+			if p := float64(i + 1); p >= start && p < end {
+				b.WriteRune(r)
+			}
+		}
+		return b.String(), nil
+	}},
+	`string-length`: {0, 1, func(c context, args []any) (any, error) {
+		return float64(len([]rune(toString(contextArg(c, args))))), nil
+	}},
+	`normalize-space`: {0, 1, func(c context, args []any) (any, error) {
+		return strings.Join(strings.FieldsFunc(toString(contextArg(c, args)), isSpace), ` `), nil
+	}},
+	`translate`: {3, 3, func(c context, args []any) (any, error) {
+		var (
+			from    = []rune(toString(args[1]))
+			to      = []rune(toString(args[2]))
+			mapping = make(map[rune]int, len(from))
+			b       strings.Builder
+		)
+		for i, r := range from {
+			if _, ok := mapping[r]; !ok {
+				mapping[r] = i
+			}
+		}
+		for _, r := range toString(args[0]) {
+			if i, ok := mapping[r]; !ok {
+				b.WriteRune(r)
+			} else if i < len(to) {
+				b.WriteRune(to[i])
+			}
+		}
+		return b.String(), nil
+	}},
+
+	// boolean functions
+	`boolean`: {1, 1, func(c context, args []any) (any, error) {
+		return toBoolean(args[0]), nil
+	}},
+	`not`: {1, 1, func(c context, args []any) (any, error) {
+		return !toBoolean(args[0]), nil
+	}},
+	`true`: {0, 0, func(c context, args []any) (any, error) {
+		return true, nil
+	}},
+	`false`: {0, 0, func(c context, args []any) (any, error) {
+		return false, nil
+	}},
+	`lang`: {1, 1, func(c context, args []any) (any, error) {
+		if c.size == 0 {
+			return false, nil
+		}
+		lang := strings.ToLower(toString(args[0]))
+		for x, ok := c.node, true; ok; x, ok = parent(x) {
+			if x.attr >= 0 || x.n.Type != html.ElementNode {
+				continue
+			}
+			node := htmlutil.Node{Data: x.n}
+			attr, ok := node.GetAttr(`xml`, `lang`)
+			if !ok {
+				attr, ok = node.GetAttr(``, `lang`)
+			}
+			if ok {
+				v := strings.ToLower(attr.Val)
+				return v == lang || strings.HasPrefix(v, lang+`-`), nil
+			}
+		}
+		return false, nil
+	}},
+
+	// number functions
+	`number`: {0, 1, func(c context, args []any) (any, error) {
+		return toNumber(contextArg(c, args)), nil
+	}},
+	`sum`: {1, 1, func(c context, args []any) (any, error) {
+		ns, err := nodeSetArg(`sum`, args[0])
+		if err != nil {
+			return nil, err
+		}
+		var v float64
+		for _, x := range ns {
+			v += toNumber(stringValue(x))
+		}
+		return v, nil
+	}},
+	`floor`: {1, 1, func(c context, args []any) (any, error) {
+		return math.Floor(toNumber(args[0])), nil
+	}},
+	`ceiling`: {1, 1, func(c context, args []any) (any, error) {
+		return math.Ceil(toNumber(args[0])), nil
+	}},
+	`round`: {1, 1, func(c context, args []any) (any, error) {
+		return round(toNumber(args[0])), nil
+	}},
+}
+
+var namespaceURIs = map[string]string{
+	`html`:  `http://www.w3.org/1999/xhtml`,
+	`svg`:   `http://www.w3.org/2000/svg`,
+	`math`:  `http://www.w3.org/1998/Math/MathML`,
+	`xlink`: `http://www.w3.org/1999/xlink`,
+	`xml`:   `http://www.w3.org/XML/1998/namespace`,
+	`xmlns`: `http://www.w3.org/2000/xmlns/`,
+}
+
+func (f *functionCall) eval(c context) (any, error) {
+	args := make([]any, len(f.args))
+	for i, arg := range f.args {
+		v, err := arg.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return f.fn.call(c, args)
+}
+
+// contextArg returns the first argument, or a node-set containing only the context node if there were no arguments
+func contextArg(c context, args []any) any {
+	if len(args) != 0 {
+		return args[0]
+	}
+	if c.size == 0 {
+		return nodeSet(nil)
+	}
+	return nodeSet{c.node}
+}
+
+func nodeSetArg(name string, arg any) (nodeSet, error) {
+	ns, ok := arg.(nodeSet)
+	if !ok {
+		return nil, fmt.Errorf("xpath.Expr.Evaluate function %s requires a node-set", name)
+	}
+	return ns, nil
+}
+
+// nameFunction implements the functions that return a name of the first node (in document order) of a node-set
+func nameFunction(name string, fn func(x xnode) string) func(c context, args []any) (any, error) {
+	return func(c context, args []any) (any, error) {
+		ns, err := nodeSetArg(name, contextArg(c, args))
+		if err != nil {
+			return nil, err
+		}
+		if len(ns) == 0 {
+			return ``, nil
+		}
+		return fn(ns[0]), nil
+	}
+}
+
+func stringValue(x xnode) string {
+	if x.attr >= 0 {
+		return x.n.Attr[x.attr].Val
+	}
+	switch x.n.Type {
+	case html.DocumentNode, html.ElementNode:
+		return htmlutil.Node{Data: x.n}.OuterText()
+	default:
+		return x.n.Data
+	}
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case nodeSet:
+		if len(v) == 0 {
+			return ``
+		}
+		return stringValue(v[0])
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return `NaN`
+		case math.IsInf(v, 1):
+			return `Infinity`
+		case math.IsInf(v, -1):
+			return `-Infinity`
+		case v == 0:
+			return `0`
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return `true`
+		}
+		return `false`
+	default:
+		return v.(string)
+	}
+}
+
+func toNumber(v any) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimFunc(v, isSpace)
+		digits := strings.TrimPrefix(s, `-`)
+		if digits == `` || digits == `.` || strings.IndexFunc(digits, func(r rune) bool { return !isDigit(r) && r != '.' }) != -1 || strings.Count(digits, `.`) > 1 {
+			return math.NaN()
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return n
+	default:
+		return toNumber(toString(v))
+	}
+}
+
+func toBoolean(v any) bool {
+	switch v := v.(type) {
+	case nodeSet:
+		return len(v) != 0
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ``
+	default:
+		return v.(bool)
+	}
+}
+
+// round implements the XPath round function, which rounds half towards positive infinity
+func round(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	if v < 0 && v >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(v + 0.5)
+}
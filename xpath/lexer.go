@@ -0,0 +1,409 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package xpath
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokDot
+	tokDotDot
+	tokAt
+	tokComma
+	tokColonColon
+	tokSlash
+	tokSlashSlash
+	tokPipe
+	tokPlus
+	tokMinus
+	tokEq
+	tokNeq
+	tokLt
+	tokLte
+	tokGt
+	tokGte
+	tokMultiply
+	tokAnd
+	tokOr
+	tokMod
+	tokDiv
+	tokNameTest
+	tokNodeType
+	tokFunctionName
+	tokAxisName
+	tokLiteral
+	tokNumber
+	tokVariable
+)
+
+type (
+	tokenKind int
+
+	token struct {
+		kind tokenKind
+		// value is the literal value, or the local part of a name (which may be `*` for a name test)
+		value  string
+		prefix string
+		number float64
+		pos    int
+	}
+
+	lexer struct {
+		input  []rune
+		pos    int
+		tokens []token
+	}
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:        `end of expression`,
+	tokLParen:     `"("`,
+	tokRParen:     `")"`,
+	tokLBracket:   `"["`,
+	tokRBracket:   `"]"`,
+	tokDot:        `"."`,
+	tokDotDot:     `".."`,
+	tokAt:         `"@"`,
+	tokComma:      `","`,
+	tokColonColon: `"::"`,
+	tokSlash:      `"/"`,
+	tokSlashSlash: `"//"`,
+	tokPipe:       `"|"`,
+	tokPlus:       `"+"`,
+	tokMinus:      `"-"`,
+	tokEq:         `"="`,
+	tokNeq:        `"!="`,
+	tokLt:         `"<"`,
+	tokLte:        `"<="`,
+	tokGt:         `">"`,
+	tokGte:        `">="`,
+	tokMultiply:   `"*"`,
+	tokAnd:        `"and"`,
+	tokOr:         `"or"`,
+	tokMod:        `"mod"`,
+	tokDiv:        `"div"`,
+}
+
+func (t token) String() string {
+	if v, ok := tokenNames[t.kind]; ok {
+		return v
+	}
+	switch t.kind {
+	case tokLiteral:
+		return strconv.Quote(t.value)
+	case tokNumber:
+		return strconv.FormatFloat(t.number, 'f', -1, 64)
+	case tokVariable:
+		return `"$` + t.name() + `"`
+	default:
+		return strconv.Quote(t.name())
+	}
+}
+
+func (t token) name() string {
+	if t.prefix != `` {
+		return t.prefix + `:` + t.value
+	}
+	return t.value
+}
+
+// tokenize splits input into tokens, applying the lexical disambiguation rules from section 3.7 of the spec
+func tokenize(input string) ([]token, *ExprError) {
+	l := lexer{input: []rune(input)}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		l.tokens = append(l.tokens, tok)
+		if tok.kind == tokEOF {
+			return l.tokens, nil
+		}
+	}
+}
+
+func (l *lexer) errorf(pos int, format string, args ...any) *ExprError {
+	return &ExprError{
+		Column: pos + 1,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (l *lexer) peekAt(pos int) rune {
+	if pos < len(l.input) {
+		return l.input[pos]
+	}
+	return 0
+}
+
+// operatorContext returns true if the previous token means that a `*` or NCName is NOT an operator
+func (l *lexer) operatorContext() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+	switch l.tokens[len(l.tokens)-1].kind {
+	case tokAt, tokColonColon, tokLParen, tokLBracket, tokComma,
+		tokAnd, tokOr, tokMod, tokDiv, tokMultiply, tokSlash, tokSlashSlash, tokPipe,
+		tokPlus, tokMinus, tokEq, tokNeq, tokLt, tokLte, tokGt, tokGte:
+		return true
+	}
+	return false
+}
+
+// nextNonSpace returns the position of the next non-whitespace character at or after pos
+func (l *lexer) nextNonSpace(pos int) int {
+	for pos < len(l.input) && isSpace(l.input[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func (l *lexer) next() (token, *ExprError) {
+	l.pos = l.nextNonSpace(l.pos)
+
+	tok := token{pos: l.pos}
+
+	if l.pos >= len(l.input) {
+		tok.kind = tokEOF
+		return tok, nil
+	}
+
+	r := l.input[l.pos]
+	simple := func(kind tokenKind, width int) (token, *ExprError) {
+		tok.kind = kind
+		l.pos += width
+		return tok, nil
+	}
+
+	switch r {
+	case '(':
+		return simple(tokLParen, 1)
+	case ')':
+		return simple(tokRParen, 1)
+	case '[':
+		return simple(tokLBracket, 1)
+	case ']':
+		return simple(tokRBracket, 1)
+	case '@':
+		return simple(tokAt, 1)
+	case ',':
+		return simple(tokComma, 1)
+	case '|':
+		return simple(tokPipe, 1)
+	case '+':
+		return simple(tokPlus, 1)
+	case '-':
+		return simple(tokMinus, 1)
+	case '=':
+		return simple(tokEq, 1)
+	case '!':
+		if l.peekAt(l.pos+1) == '=' {
+			return simple(tokNeq, 2)
+		}
+		return tok, l.errorf(l.pos, "unexpected %q", r)
+	case '<':
+		if l.peekAt(l.pos+1) == '=' {
+			return simple(tokLte, 2)
+		}
+		return simple(tokLt, 1)
+	case '>':
+		if l.peekAt(l.pos+1) == '=' {
+			return simple(tokGte, 2)
+		}
+		return simple(tokGt, 1)
+	case '/':
+		if l.peekAt(l.pos+1) == '/' {
+			return simple(tokSlashSlash, 2)
+		}
+		return simple(tokSlash, 1)
+	case ':':
+		if l.peekAt(l.pos+1) == ':' {
+			return simple(tokColonColon, 2)
+		}
+		return tok, l.errorf(l.pos, "unexpected %q", r)
+	case '.':
+		if l.peekAt(l.pos+1) == '.' {
+			return simple(tokDotDot, 2)
+		}
+		if isDigit(l.peekAt(l.pos + 1)) {
+			return l.number()
+		}
+		return simple(tokDot, 1)
+	case '"', '\'':
+		end := l.pos + 1
+		for end < len(l.input) && l.input[end] != r {
+			end++
+		}
+		if end >= len(l.input) {
+			return tok, l.errorf(l.pos, "unterminated literal")
+		}
+		tok.kind = tokLiteral
+		tok.value = string(l.input[l.pos+1 : end])
+		l.pos = end + 1
+		return tok, nil
+	case '$':
+		l.pos++
+		prefix, local, err := l.qname(false)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind = tokVariable
+		tok.prefix, tok.value = prefix, local
+		return tok, nil
+	case '*':
+		if !l.operatorContext() {
+			return simple(tokMultiply, 1)
+		}
+		tok.kind = tokNameTest
+		tok.value = `*`
+		l.pos++
+		return tok, nil
+	}
+
+	if isDigit(r) {
+		return l.number()
+	}
+
+	if !isNameStart(r) {
+		return tok, l.errorf(l.pos, "unexpected %q", r)
+	}
+
+	if !l.operatorContext() {
+		name := l.ncname()
+		switch name {
+		case `and`:
+			tok.kind = tokAnd
+		case `or`:
+			tok.kind = tokOr
+		case `mod`:
+			tok.kind = tokMod
+		case `div`:
+			tok.kind = tokDiv
+		default:
+			return tok, l.errorf(tok.pos, "expected operator but found %q", name)
+		}
+		return tok, nil
+	}
+
+	// check for an axis name, which will be followed by '::'
+	start := l.pos
+	name := l.ncname()
+	if after := l.nextNonSpace(l.pos); l.peekAt(after) == ':' && l.peekAt(after+1) == ':' {
+		tok.kind = tokAxisName
+		tok.value = name
+		return tok, nil
+	}
+	l.pos = start
+
+	prefix, local, err := l.qname(true)
+	if err != nil {
+		return tok, err
+	}
+	tok.prefix, tok.value = prefix, local
+
+	if local != `*` && l.peekAt(l.nextNonSpace(l.pos)) == '(' {
+		tok.kind = tokFunctionName
+		if prefix == `` {
+			switch local {
+			case `comment`, `text`, `processing-instruction`, `node`:
+				tok.kind = tokNodeType
+			}
+		}
+		return tok, nil
+	}
+
+	tok.kind = tokNameTest
+	return tok, nil
+}
+
+func (l *lexer) number() (token, *ExprError) {
+	tok := token{kind: tokNumber, pos: l.pos}
+	end := l.pos
+	for isDigit(l.peekAt(end)) {
+		end++
+	}
+	if l.peekAt(end) == '.' {
+		end++
+		for isDigit(l.peekAt(end)) {
+			end++
+		}
+	}
+	v, err := strconv.ParseFloat(string(l.input[l.pos:end]), 64)
+	if err != nil {
+		return tok, l.errorf(l.pos, "invalid number")
+	}
+	tok.number = v
+	l.pos = end
+	return tok, nil
+}
+
+func (l *lexer) ncname() string {
+	start := l.pos
+	for l.pos < len(l.input) && (l.pos == start && isNameStart(l.input[l.pos]) || l.pos != start && isNameChar(l.input[l.pos])) {
+		l.pos++
+	}
+	return string(l.input[start:l.pos])
+}
+
+// qname parses a QName, or if wildcard is true, a NameTest (allowing `prefix:*`)
+func (l *lexer) qname(wildcard bool) (prefix string, local string, err *ExprError) {
+	if !isNameStart(l.peekAt(l.pos)) {
+		return ``, ``, l.errorf(l.pos, "expected name")
+	}
+	local = l.ncname()
+	if l.peekAt(l.pos) == ':' && l.peekAt(l.pos+1) != ':' {
+		switch next := l.peekAt(l.pos + 1); {
+		case wildcard && next == '*':
+			prefix, local = local, `*`
+			l.pos += 2
+		case isNameStart(next):
+			l.pos++
+			prefix, local = local, l.ncname()
+		default:
+			return ``, ``, l.errorf(l.pos, "unexpected %q", ':')
+		}
+	}
+	return prefix, local, nil
+}
+
+func isSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\r', '\n':
+		return true
+	}
+	return false
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || isDigit(r) || r == '.' || r == '-' || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc) || r == 0xB7
+}
@@ -0,0 +1,430 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package xpath
+
+import (
+	"fmt"
+)
+
+const (
+	axisAncestor axis = iota
+	axisAncestorOrSelf
+	axisAttribute
+	axisChild
+	axisDescendant
+	axisDescendantOrSelf
+	axisFollowing
+	axisFollowingSibling
+	axisNamespace
+	axisParent
+	axisPreceding
+	axisPrecedingSibling
+	axisSelf
+)
+
+const (
+	testName nodeTestKind = iota
+	testNode
+	testText
+	testComment
+	testProcessingInstruction
+)
+
+type (
+	axis int
+
+	nodeTestKind int
+
+	nodeTest struct {
+		kind nodeTestKind
+		// prefix and local are set for name tests, where local may be `*`
+		prefix string
+		local  string
+	}
+
+	step struct {
+		axis       axis
+		test       nodeTest
+		predicates []expr
+	}
+
+	parser struct {
+		tokens []token
+		pos    int
+	}
+)
+
+var axes = map[string]axis{
+	`ancestor`:           axisAncestor,
+	`ancestor-or-self`:   axisAncestorOrSelf,
+	`attribute`:          axisAttribute,
+	`child`:              axisChild,
+	`descendant`:         axisDescendant,
+	`descendant-or-self`: axisDescendantOrSelf,
+	`following`:          axisFollowing,
+	`following-sibling`:  axisFollowingSibling,
+	`namespace`:          axisNamespace,
+	`parent`:             axisParent,
+	`preceding`:          axisPreceding,
+	`preceding-sibling`:  axisPrecedingSibling,
+	`self`:               axisSelf,
+}
+
+// reverse returns true for the axes that are in reverse document order, for the purposes of predicate positions
+func (a axis) reverse() bool {
+	switch a {
+	case axisAncestor, axisAncestorOrSelf, axisPreceding, axisPrecedingSibling:
+		return true
+	}
+	return false
+}
+
+func newParser(input string) (*parser, *ExprError) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) *ExprError {
+	return &ExprError{
+		Column: tok.pos + 1,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekKind(offset int) tokenKind {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset].kind
+	}
+	return tokEOF
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) unexpected() *ExprError {
+	tok := p.peek()
+	if tok.kind == tokEOF {
+		return p.errorf(tok, "unexpected end of expression")
+	}
+	return p.errorf(tok, "unexpected %s", tok)
+}
+
+func (p *parser) expect(kind tokenKind) *ExprError {
+	if p.peek().kind != kind {
+		tok := p.peek()
+		return p.errorf(tok, "expected %s but found %s", tokenNames[kind], tok)
+	}
+	p.advance()
+	return nil
+}
+
+func (p *parser) parse() (expr, *ExprError) {
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return e, nil
+}
+
+// parseBinary parses a left-associative sequence of operands separated by any of the given operators
+func (p *parser) parseBinary(operand func() (expr, *ExprError), operators ...tokenKind) (expr, *ExprError) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op tokenKind
+		for _, kind := range operators {
+			if p.peek().kind == kind {
+				op = kind
+			}
+		}
+		if op == tokEOF {
+			return left, nil
+		}
+		p.advance()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseOr() (expr, *ExprError) {
+	return p.parseBinary(p.parseAnd, tokOr)
+}
+
+func (p *parser) parseAnd() (expr, *ExprError) {
+	return p.parseBinary(p.parseEquality, tokAnd)
+}
+
+func (p *parser) parseEquality() (expr, *ExprError) {
+	return p.parseBinary(p.parseRelational, tokEq, tokNeq)
+}
+
+func (p *parser) parseRelational() (expr, *ExprError) {
+	return p.parseBinary(p.parseAdditive, tokLt, tokLte, tokGt, tokGte)
+}
+
+func (p *parser) parseAdditive() (expr, *ExprError) {
+	return p.parseBinary(p.parseMultiplicative, tokPlus, tokMinus)
+}
+
+func (p *parser) parseMultiplicative() (expr, *ExprError) {
+	return p.parseBinary(p.parseUnary, tokMultiply, tokDiv, tokMod)
+}
+
+func (p *parser) parseUnary() (expr, *ExprError) {
+	if p.peek().kind == tokMinus {
+		p.advance()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{e}, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *parser) parseUnion() (expr, *ExprError) {
+	return p.parseBinary(p.parsePath, tokPipe)
+}
+
+func (p *parser) parsePath() (expr, *ExprError) {
+	switch p.peek().kind {
+	case tokVariable, tokLParen, tokLiteral, tokNumber, tokFunctionName:
+	default:
+		return p.parseLocationPath()
+	}
+
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	if len(predicates) != 0 {
+		primary = &filterExpr{primary: primary, predicates: predicates}
+	}
+
+	path := &pathExpr{filter: primary}
+	switch p.peek().kind {
+	case tokSlash:
+		p.advance()
+	case tokSlashSlash:
+		p.advance()
+		path.steps = append(path.steps, descendantOrSelfStep())
+	default:
+		return primary, nil
+	}
+	if path.steps, err = p.parseRelativeLocationPath(path.steps); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *parser) parseLocationPath() (expr, *ExprError) {
+	path := &pathExpr{}
+	var err *ExprError
+	switch p.peek().kind {
+	case tokSlash:
+		p.advance()
+		path.absolute = true
+		// the root on it's own is a valid path
+		switch p.peek().kind {
+		case tokDot, tokDotDot, tokAt, tokAxisName, tokNameTest, tokNodeType:
+		default:
+			return path, nil
+		}
+	case tokSlashSlash:
+		p.advance()
+		path.absolute = true
+		path.steps = append(path.steps, descendantOrSelfStep())
+	}
+	if path.steps, err = p.parseRelativeLocationPath(path.steps); err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+func (p *parser) parseRelativeLocationPath(steps []step) ([]step, *ExprError) {
+	for {
+		s, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+		switch p.peek().kind {
+		case tokSlash:
+			p.advance()
+		case tokSlashSlash:
+			p.advance()
+			steps = append(steps, descendantOrSelfStep())
+		default:
+			return steps, nil
+		}
+	}
+}
+
+func (p *parser) parseStep() (step, *ExprError) {
+	switch p.peek().kind {
+	case tokDot:
+		p.advance()
+		return step{axis: axisSelf, test: nodeTest{kind: testNode}}, nil
+	case tokDotDot:
+		p.advance()
+		return step{axis: axisParent, test: nodeTest{kind: testNode}}, nil
+	}
+
+	s := step{axis: axisChild}
+
+	switch tok := p.peek(); tok.kind {
+	case tokAt:
+		p.advance()
+		s.axis = axisAttribute
+	case tokAxisName:
+		p.advance()
+		a, ok := axes[tok.value]
+		if !ok {
+			return s, p.errorf(tok, "unknown axis %s", tok)
+		}
+		s.axis = a
+		if err := p.expect(tokColonColon); err != nil {
+			return s, err
+		}
+	}
+
+	switch tok := p.peek(); tok.kind {
+	case tokNameTest:
+		p.advance()
+		s.test = nodeTest{kind: testName, prefix: tok.prefix, local: tok.value}
+	case tokNodeType:
+		p.advance()
+		if err := p.expect(tokLParen); err != nil {
+			return s, err
+		}
+		switch tok.value {
+		case `node`:
+			s.test.kind = testNode
+		case `text`:
+			s.test.kind = testText
+		case `comment`:
+			s.test.kind = testComment
+		default:
+			s.test.kind = testProcessingInstruction
+			if p.peek().kind == tokLiteral {
+				s.test.local = p.advance().value
+			}
+		}
+		if err := p.expect(tokRParen); err != nil {
+			return s, err
+		}
+	default:
+		return s, p.unexpected()
+	}
+
+	var err *ExprError
+	s.predicates, err = p.parsePredicates()
+	return s, err
+}
+
+func (p *parser) parsePredicates() ([]expr, *ExprError) {
+	var predicates []expr
+	for p.peek().kind == tokLBracket {
+		p.advance()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRBracket); err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, e)
+	}
+	return predicates, nil
+}
+
+func (p *parser) parsePrimary() (expr, *ExprError) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokVariable:
+		return &variableExpr{name: tok.name()}, nil
+	case tokLiteral:
+		return &literalExpr{value: tok.value}, nil
+	case tokNumber:
+		return &numberExpr{value: tok.number}, nil
+	case tokLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	// function call
+	fn, ok := functions[tok.name()]
+	if !ok {
+		return nil, p.errorf(tok, "unknown function %s", tok)
+	}
+	if err := p.expect(tokLParen); err != nil {
+		return nil, err
+	}
+	call := &functionCall{name: tok.name(), fn: fn}
+	for p.peek().kind != tokRParen {
+		if len(call.args) != 0 {
+			if err := p.expect(tokComma); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.advance()
+	if len(call.args) < fn.min || (fn.max >= 0 && len(call.args) > fn.max) {
+		return nil, p.errorf(tok, "wrong number of arguments (%d) for function %s", len(call.args), tok)
+	}
+	return call, nil
+}
+
+func descendantOrSelfStep() step {
+	return step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}}
+}
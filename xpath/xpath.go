@@ -0,0 +1,134 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package xpath implements an XPath 1.0 engine that evaluates against a `htmlutil.Node`, returning node-sets as
+// `[]htmlutil.Node` with a `Depth` relative to the context node, and a `Match` of the context node, so that results
+// may be refined further using the filter methods of the htmlutil package.
+//
+// # Data model
+//
+//   - the root node is the top-most ancestor of the context node (normally a `html.DocumentNode`)
+//   - element, text and comment nodes map directly, doctype nodes are not part of the tree
+//   - attribute nodes are returned as detached `html.TextNode` values (holding the attribute value), with a `Parent`
+//     of the owning element, and a `Depth` one greater than that element
+//   - the namespace axis is always empty, and processing instructions never match (html has none)
+//   - an unprefixed name test matches elements in any namespace (e.g. both `div` and `svg`), but only attributes without
+//     a namespace, while a prefixed name test (e.g. `svg:rect` or `xlink:href`) matches the namespace of the underlying
+//     `html.Node` or `html.Attribute` exactly
+//   - the `lang` function considers both `lang` and `xml:lang` attributes
+//
+// Evaluation results are one of `[]htmlutil.Node`, `string`, `float64` or `bool`.
+package xpath
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joeycumines/go-htmlutil"
+)
+
+type (
+	// Expr is a compiled XPath 1.0 expression, safe for concurrent use
+	Expr struct {
+		source string
+		root   expr
+	}
+
+	// ExprError is the error returned by Compile for an expression that could not be parsed, where Column is the
+	// (1-based) position, in runes, of the offending token
+	ExprError struct {
+		Expr   string
+		Column int
+		Reason string
+	}
+)
+
+// Compile parses an XPath 1.0 expression, note that functions are resolved (and their arity checked) at this point,
+// while variables are resolved during evaluation
+func Compile(expression string) (*Expr, error) {
+	p, err := newParser(expression)
+	if err == nil {
+		var root expr
+		if root, err = p.parse(); err == nil {
+			return &Expr{source: expression, root: root}, nil
+		}
+	}
+	err.Expr = expression
+	return nil, err
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed
+func MustCompile(expression string) *Expr {
+	e, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Select compiles and evaluates expression using node as the context, returning an error if the expression could
+// not be compiled or did not evaluate to a node-set
+func Select(node htmlutil.Node, expression string) ([]htmlutil.Node, error) {
+	e, err := Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return e.Select(node)
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Evaluate evaluates the expression using node as the context, returning one of `[]htmlutil.Node`, `string`,
+// `float64` or `bool`, note that an empty node (nil `Data`) will evaluate to an empty node-set for any location path
+func (e *Expr) Evaluate(node htmlutil.Node) (any, error) {
+	return e.EvaluateWith(node, nil)
+}
+
+// EvaluateWith is like Evaluate but allows variables to be provided, each of which must be one of
+// `[]htmlutil.Node`, `string`, `float64` or `bool` (other numeric types will be converted to `float64`)
+func (e *Expr) EvaluateWith(node htmlutil.Node, variables map[string]any) (any, error) {
+	ev, err := newEvaluator(node, variables)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ev.evaluate(e.root)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := v.(nodeSet); ok {
+		return ev.nodes(v), nil
+	}
+	return v, nil
+}
+
+// Select evaluates the expression using node as the context, returning an error if the result was not a node-set
+func (e *Expr) Select(node htmlutil.Node) ([]htmlutil.Node, error) {
+	v, err := e.Evaluate(node)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]htmlutil.Node)
+	if !ok {
+		return nil, errors.New("xpath.Expr.Select result is not a node-set")
+	}
+	return nodes, nil
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("xpath.Compile %s at column %d: %q", e.Reason, e.Column, e.Expr)
+}
@@ -0,0 +1,301 @@
+/*
+   Copyright 2019 Joseph Cumines
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package xpath
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/joeycumines/go-htmlutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+const testDocument = `<!DOCTYPE html><html lang="en"><head><title>T</title></head><body>
+<div id="a" class="x"><p>one</p><p>two</p><!--c--><p lang="fr">three</p></div>
+<div id="b"><span>4</span><span>5.5</span><a href="/x" xlink:href="ignored">link</a></div>
+<svg><rect width="2"/></svg>
+</body></html>`
+
+func parse(s string) htmlutil.Node {
+	v, err := htmlutil.Parse(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestExpr_Evaluate(t *testing.T) {
+	type TestCase struct {
+		Expr   string
+		Output any
+	}
+	testCases := []TestCase{
+		{`//p`, []string{`<p>one</p>`, `<p>two</p>`, `<p lang="fr">three</p>`}},
+		{`//p[2]`, []string{`<p>two</p>`}},
+		{`(//p)[last()]`, []string{`<p lang="fr">three</p>`}},
+		{`//div[@id='b']/span[. > 5]`, []string{`<span>5.5</span>`}},
+		{`//p[3]/preceding-sibling::*[1]`, []string{`<p>two</p>`}},
+		{`//p[3]/preceding-sibling::node()[1]`, []string{`<!--c-->`}},
+		{`//p[1]/following::span`, []string{`<span>4</span>`, `<span>5.5</span>`}},
+		{`//span[1]/preceding::p[position() < 3]`, []string{`<p>two</p>`, `<p lang="fr">three</p>`}},
+		{`//p[1]/ancestor::*[2]/@*`, []string(nil)},
+		{`name(//p[1]/ancestor::*[2])`, `body`},
+		{`//p[1]/ancestor-or-self::div`, []string{`<div id="a" class="x"><p>one</p><p>two</p><!--c--><p lang="fr">three</p></div>`}},
+		{`//a/@href`, []string{`/x`}},
+		{`//a/attribute::*`, []string{`/x`, `ignored`}},
+		{`//rect/@width/..`, []string{`<rect width="2"></rect>`}},
+		{`//svg:rect | //p[1]`, []string{`<p>one</p>`, `<rect width="2"></rect>`}},
+		{`id('b a')/@id`, []string{`a`, `b`}},
+		{`//p[lang('fr')]/text()`, []string{`three`}},
+		{`//p[not(lang('en'))]`, []string{`<p lang="fr">three</p>`}},
+		{`//comment()`, []string{`<!--c-->`}},
+		{`//div[@id="a"]/self::node()/child::p[last()-1]`, []string{`<p>two</p>`}},
+		{`//div/descendant::*[self::p or self::a][position() mod 2 = 0]`, []string{`<p>two</p>`}},
+		{`/descendant-or-self::title/parent::head`, []string{`<head><title>T</title></head>`}},
+		{`//namespace::*`, []string(nil)},
+		{`//processing-instruction('x')`, []string(nil)},
+		{`count(//p)`, 3.0},
+		{`sum(//span)`, 9.5},
+		{`sum(//span) div 2 - -1`, 5.75},
+		{`7 mod 3 * 2`, 2.0},
+		{`string(//span[2])`, `5.5`},
+		{`string(1 div 0)`, `Infinity`},
+		{`string(-1 div 0)`, `-Infinity`},
+		{`string(0 div 0)`, `NaN`},
+		{`string(-0)`, `0`},
+		{`string(1.50)`, `1.5`},
+		{`number(' 12 ')`, 12.0},
+		{`concat('a', 1, true())`, `a1true`},
+		{`starts-with('abc', 'ab') and contains('abc', 'bc')`, true},
+		{`substring-before('1999/04/01', '/')`, `1999`},
+		{`substring-after('1999/04/01', '/')`, `04/01`},
+		{`substring('12345', 1.5, 2.6)`, `234`},
+		{`substring('12345', 0, 3)`, `12`},
+		{`substring('12345', 0 div 0, 3)`, ``},
+		{`substring('12345', -42, 1 div 0)`, `12345`},
+		{`string-length(normalize-space('  a   b  '))`, 3.0},
+		{`translate('--aaa--', 'abc-', 'ABC')`, `AAA`},
+		{`boolean('') or false()`, false},
+		{`floor(-1.5) + ceiling(1.2) + round(2.5) + round(-2.5)`, 1.0},
+		{`local-name(//svg/*)`, `rect`},
+		{`name(//a/@*[2])`, `xlink:href`},
+		{`namespace-uri(//svg/*)`, `http://www.w3.org/2000/svg`},
+		{`namespace-uri(//p[1])`, `http://www.w3.org/1999/xhtml`},
+		{`//span = 4`, true},
+		{`//span != 4`, true},
+		{`//span > 5`, true},
+		{`5 < //span`, true},
+		{`//span = '4'`, true},
+		{`//span = //p`, false},
+		{`//span = true()`, true},
+		{`//nothing = false()`, true},
+		{`1 = '1'`, true},
+		{`true() = 'x'`, true},
+		{`'a' != 'b'`, true},
+		{`'2' < '10'`, true},
+		{`-(1 + 2)`, -3.0},
+		{`last()`, 1.0},
+		{`position()`, 1.0},
+		{`string()`, "T\nonetwothree\n45.5link\n\n"},
+	}
+	root := parse(testDocument)
+	for i, testCase := range testCases {
+		name := fmt.Sprintf("Evaluate_#%d", i+1)
+		v, err := MustCompile(testCase.Expr).Evaluate(root)
+		if err != nil {
+			t.Fatal(name, testCase.Expr, err)
+		}
+		if nodes, ok := v.([]htmlutil.Node); ok {
+			var output []string
+			for _, node := range nodes {
+				output = append(output, node.OuterHTML())
+			}
+			v = output
+		}
+		if diff := deep.Equal(
+			v,
+			testCase.Output,
+		); diff != nil {
+			t.Error(strings.Join(append([]string{name + " " + testCase.Expr + " output diff:"}, diff...), "    \n"))
+		}
+	}
+}
+
+func TestExpr_Select_depth(t *testing.T) {
+	root := parse(testDocument)
+	div := root.GetNode(func(node htmlutil.Node) bool {
+		return node.GetAttrVal(``, `id`) == `a`
+	})
+	if div.Depth != 3 {
+		t.Fatal(div.Depth)
+	}
+	nodes, err := Select(div, `p[3]/text() | .. | /html | ./@class`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type Result struct {
+		Depth  int
+		Offset int
+		Tag    string
+		Text   string
+	}
+	var results []Result
+	for _, node := range nodes {
+		if node.Match == nil || node.Match.Data != div.Data {
+			t.Error(node.Match)
+		}
+		results = append(results, Result{node.Depth, node.Offset(), node.Tag(), node.OuterText()})
+	}
+	if diff := deep.Equal(
+		results,
+		[]Result{
+			{1, -2, `html`, "T\nonetwothree\n45.5link\n\n"},
+			{2, -1, `body`, "\nonetwothree\n45.5link\n\n"},
+			{4, 1, ``, `x`},
+			{5, 2, ``, `three`},
+		},
+	); diff != nil {
+		t.Error(strings.Join(append([]string{"results diff:"}, diff...), "    \n"))
+	}
+	if v := nodes[2].Parent(); v.Data != div.Data || v.Depth != div.Depth {
+		t.Error(v)
+	}
+	// results should work as filter roots
+	if v := nodes[1].FilterNodes(func(node htmlutil.Node) bool {
+		return node.Tag() == `span` && node.Offset() == 2
+	}); len(v) != 2 || v[0].Depth != 4 {
+		t.Error(v)
+	}
+}
+
+func TestExpr_EvaluateWith(t *testing.T) {
+	root := parse(testDocument)
+	spans, err := Select(root, `//span`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := MustCompile(`concat($s, ':', $n + $i, ':', count($spans), ':', $b)`).EvaluateWith(root, map[string]any{
+		`s`:     `v`,
+		`n`:     1.5,
+		`i`:     1,
+		`spans`: spans,
+		`b`:     true,
+	})
+	if err != nil || v != `v:2.5:2:true` {
+		t.Error(v, err)
+	}
+	if _, err := MustCompile(`$x`).Evaluate(root); err == nil || err.Error() != `xpath.Expr.Evaluate undefined variable $x` {
+		t.Error(err)
+	}
+	if _, err := MustCompile(`1`).EvaluateWith(root, map[string]any{`x`: struct{}{}}); err == nil || err.Error() != `xpath.Expr.EvaluateWith unsupported variable type struct {} for $x` {
+		t.Error(err)
+	}
+}
+
+func TestExpr_Evaluate_errors(t *testing.T) {
+	root := parse(testDocument)
+	for _, testCase := range []struct{ Expr, Error string }{
+		{`count(1)`, `xpath.Expr.Evaluate function count requires a node-set`},
+		{`1 | //p`, `xpath.Expr.Evaluate union of non node-set`},
+		{`'a'[1]`, `xpath.Expr.Evaluate predicate applied to non node-set`},
+		{`concat('a', 'b')/p`, `xpath.Expr.Evaluate path applied to non node-set`},
+	} {
+		if _, err := MustCompile(testCase.Expr).Evaluate(root); err == nil || err.Error() != testCase.Error {
+			t.Error(testCase.Expr, err)
+		}
+	}
+	if _, err := MustCompile(`1`).Select(root); err == nil || err.Error() != `xpath.Expr.Select result is not a node-set` {
+		t.Error(err)
+	}
+}
+
+func TestExpr_Evaluate_empty(t *testing.T) {
+	for expression, expected := range map[string]any{
+		`//p`:        []htmlutil.Node(nil),
+		`string()`:   ``,
+		`lang('en')`: false,
+		`last()`:     0.0,
+	} {
+		if v, err := MustCompile(expression).Evaluate(htmlutil.Node{}); err != nil || fmt.Sprint(v) != fmt.Sprint(expected) {
+			t.Error(expression, v, err)
+		}
+	}
+}
+
+func TestSelect_idDetached(t *testing.T) {
+	div := parse(`<div id="a"><p id="b">x</p></div>`).GetNode(htmlutil.Tag(`div`)).Remove()
+	nodes, err := Select(div.GetNode(htmlutil.Tag(`p`)), `id('a b')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].Data != div.Data || nodes[0].Depth != 0 || nodes[1].Tag() != `p` {
+		t.Error(nodes)
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	for _, testCase := range []struct {
+		Expr   string
+		Column int
+		Error  string
+	}{
+		{``, 1, `xpath.Compile unexpected end of expression at column 1: ""`},
+		{`//p[`, 5, `xpath.Compile unexpected end of expression at column 5: "//p["`},
+		{`//p[1`, 6, `xpath.Compile expected "]" but found end of expression at column 6: "//p[1"`},
+		{`foo(1)`, 1, `xpath.Compile unknown function "foo" at column 1: "foo(1)"`},
+		{`count()`, 1, `xpath.Compile wrong number of arguments (0) for function "count" at column 1: "count()"`},
+		{`bad::p`, 1, `xpath.Compile unknown axis "bad" at column 1: "bad::p"`},
+		{`p foo`, 3, `xpath.Compile expected operator but found "foo" at column 3: "p foo"`},
+		{`'abc`, 1, `xpath.Compile unterminated literal at column 1: "'abc"`},
+		{`a ! b`, 3, `xpath.Compile unexpected '!' at column 3: "a ! b"`},
+		{`1 2`, 3, `xpath.Compile unexpected 2 at column 3: "1 2"`},
+	} {
+		_, err := Compile(testCase.Expr)
+		e, ok := err.(*ExprError)
+		if !ok {
+			t.Fatal(testCase.Expr, err)
+		}
+		if e.Column != testCase.Column {
+			t.Error(testCase.Expr, e.Column)
+		}
+		if v := e.Error(); v != testCase.Error {
+			t.Error(testCase.Expr, v)
+		}
+	}
+}
+
+func TestMustCompile_panic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic")
+		}
+	}()
+	MustCompile(`/[`)
+}
+
+func TestRound(t *testing.T) {
+	if v := round(-0.5); v != 0 || !math.Signbit(v) {
+		t.Error(v)
+	}
+	if v := round(math.NaN()); !math.IsNaN(v) {
+		t.Error(v)
+	}
+	if v := MustCompile(`.`).String(); v != `.` {
+		t.Error(v)
+	}
+}